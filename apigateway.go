@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// APIGatewayRequest is an API Gateway REST proxy request with its body unmarshalled into T
+type APIGatewayRequest[T interface{}] struct {
+	Body                  T
+	HTTPMethod            string
+	Path                  string
+	Headers               map[string]string
+	PathParameters        map[string]string
+	QueryStringParameters map[string]string
+	RequestContext        events.APIGatewayProxyRequestContext
+}
+
+// APIGatewayResponse is marshalled into an API Gateway proxy response. StatusCode defaults to 200
+type APIGatewayResponse[U interface{}] struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       U
+}
+
+type APIGatewayRequestProcessor[T interface{}, U interface{}] func(ctx context.Context, request APIGatewayRequest[T]) (APIGatewayResponse[U], error)
+
+type APIGatewayHandler = Handler[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse]
+
+type apiGatewayErrorBody struct {
+	Message string `json:"message"`
+}
+
+// GetAPIGatewayHandler returns a lambda handler for API Gateway REST proxy integrations that unmarshals the request body
+// into T and marshals the returned body into a JSON proxy response. An empty request body leaves T as its zero value.
+// A body that can't be unmarshalled gets a 400 response, and an error from processRequest is logged and gets a 500
+// response, so that API Gateway always receives a proxy response rather than a lambda error
+func GetAPIGatewayHandler[T interface{}, U interface{}](processRequest APIGatewayRequestProcessor[T, U]) Handler[events.APIGatewayProxyRequest, events.APIGatewayProxyResponse] {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		//Reuse the invocation's stages (set up by WithLogger), so they reach the failure log and the watchdog
+		if ctx.Value(stagesKey) == nil {
+			ctx = ContextWithStages(ctx)
+		}
+
+		request := APIGatewayRequest[T]{
+			HTTPMethod:            event.HTTPMethod,
+			Path:                  event.Path,
+			Headers:               event.Headers,
+			PathParameters:        event.PathParameters,
+			QueryStringParameters: event.QueryStringParameters,
+			RequestContext:        event.RequestContext,
+		}
+		err := unmarshalAPIGatewayBody(event, &request.Body)
+		if err != nil {
+			err = StageErr(ctx, "unmarshal body", err)
+			GetLogger(ctx).Warn("invalid api gateway request body", "errStr", err.Error(), "path", event.Path, "stages", getStagesLogValue(ctx))
+			return apiGatewayJSONResponse(http.StatusBadRequest, nil, apiGatewayErrorBody{Message: "invalid request body"})
+		}
+		AddStage(ctx, "unmarshal body")
+
+		response, err := processRequest(ctx, request)
+		err = withCancelCause(ctx, err)
+		if IsDeadlineExceeded(ctx, err) {
+			err = flagDeadlineExceeded(ctx, err)
+		}
+		if err != nil {
+			GetLogger(ctx).Error("api gateway request processing failed", "errStr", err.Error(), "path", event.Path, "errObj", err, "stages", getStagesLogValue(ctx))
+			return apiGatewayJSONResponse(http.StatusInternalServerError, nil, apiGatewayErrorBody{Message: "internal server error"})
+		}
+
+		statusCode := response.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		return apiGatewayJSONResponse(statusCode, response.Headers, response.Body)
+	}
+}
+
+func unmarshalAPIGatewayBody(event events.APIGatewayProxyRequest, v interface{}) error {
+	if event.Body == "" {
+		return nil
+	}
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return err
+		}
+		body = decoded
+	}
+	return json.Unmarshal(body, v)
+}
+
+func apiGatewayJSONResponse(statusCode int, headers map[string]string, body interface{}) (events.APIGatewayProxyResponse, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, err
+	}
+	responseHeaders := map[string]string{"Content-Type": "application/json"}
+	for k, v := range headers {
+		responseHeaders[k] = v
+	}
+	return events.APIGatewayProxyResponse{StatusCode: statusCode, Headers: responseHeaders, Body: string(b)}, nil
+}
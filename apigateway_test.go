@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAPIGatewayHandler(t *testing.T) {
+
+	testcases := []struct {
+		name           string
+		processRequest APIGatewayRequestProcessor[inputEvent, outputEvent]
+		event          events.APIGatewayProxyRequest
+		checkResult    func(t *testing.T, response events.APIGatewayProxyResponse)
+	}{
+		{
+			name: "Body bound and response marshalled",
+			processRequest: func(ctx context.Context, request APIGatewayRequest[inputEvent]) (APIGatewayResponse[outputEvent], error) {
+				assert.Equal(t, 1, request.Body.Foo)
+				assert.Equal(t, "42", request.PathParameters["id"])
+				return APIGatewayResponse[outputEvent]{StatusCode: 201, Headers: map[string]string{"Location": "/items/42"}, Body: outputEvent{Bar: 2}}, nil
+			},
+			event: events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: `{"Foo":1}`, PathParameters: map[string]string{"id": "42"}},
+			checkResult: func(t *testing.T, response events.APIGatewayProxyResponse) {
+				assert.Equal(t, 201, response.StatusCode)
+				assert.Equal(t, map[string]string{"Content-Type": "application/json", "Location": "/items/42"}, response.Headers)
+				assert.JSONEq(t, `{"Bar":2}`, response.Body)
+			},
+		},
+		{
+			name: "Base64 body and default status code",
+			processRequest: func(ctx context.Context, request APIGatewayRequest[inputEvent]) (APIGatewayResponse[outputEvent], error) {
+				assert.Equal(t, 1, request.Body.Foo)
+				return APIGatewayResponse[outputEvent]{}, nil
+			},
+			event: events.APIGatewayProxyRequest{Body: "eyJGb28iOjF9", IsBase64Encoded: true},
+			checkResult: func(t *testing.T, response events.APIGatewayProxyResponse) {
+				assert.Equal(t, 200, response.StatusCode)
+			},
+		},
+		{
+			name: "Invalid body",
+			processRequest: func(ctx context.Context, request APIGatewayRequest[inputEvent]) (APIGatewayResponse[outputEvent], error) {
+				t.Error("should not be called")
+				return APIGatewayResponse[outputEvent]{}, nil
+			},
+			event: events.APIGatewayProxyRequest{Body: "not json"},
+			checkResult: func(t *testing.T, response events.APIGatewayProxyResponse) {
+				assert.Equal(t, 400, response.StatusCode)
+				assert.JSONEq(t, `{"message":"invalid request body"}`, response.Body)
+			},
+		},
+		{
+			name: "Processing fails",
+			processRequest: func(ctx context.Context, request APIGatewayRequest[inputEvent]) (APIGatewayResponse[outputEvent], error) {
+				return APIGatewayResponse[outputEvent]{}, errors.New("something bad happened")
+			},
+			event: events.APIGatewayProxyRequest{},
+			checkResult: func(t *testing.T, response events.APIGatewayProxyResponse) {
+				assert.Equal(t, 500, response.StatusCode)
+				assert.JSONEq(t, `{"message":"internal server error"}`, response.Body)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := GetAPIGatewayHandler(tc.processRequest)
+			response, err := handler(context.Background(), tc.event)
+			assert.Nil(t, err)
+			tc.checkResult(t, response)
+		})
+	}
+}
+
+func TestGetAPIGatewayHandlerInvocationStages(t *testing.T) {
+	handler := GetAPIGatewayHandler(func(ctx context.Context, request APIGatewayRequest[inputEvent]) (APIGatewayResponse[outputEvent], error) {
+		AddStage(ctx, "load item")
+		return APIGatewayResponse[outputEvent]{}, nil
+	})
+
+	ctx := ContextWithStages(context.Background())
+	_, err := handler(ctx, events.APIGatewayProxyRequest{Body: `{"Foo":1}`})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"unmarshal body", "load item"}, getStageDescriptions(ctx))
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// AppSyncResolverEvent is the event sent by AppSync to a direct lambda resolver
+type AppSyncResolverEvent struct {
+	Arguments json.RawMessage    `json:"arguments"`
+	Identity  *AppSyncIdentity   `json:"identity"`
+	Source    json.RawMessage    `json:"source"`
+	Request   AppSyncRequestInfo `json:"request"`
+	Info      AppSyncInfo        `json:"info"`
+	Prev      json.RawMessage    `json:"prev"`
+	Stash     json.RawMessage    `json:"stash"`
+}
+
+// AppSyncIdentity is the caller identity of an AppSync request. Which fields are set depends on the authorization type:
+// Cognito user pools and OIDC set Sub, Issuer and Claims, IAM sets AccountID and UserARN, and lambda authorizers set
+// ResolverContext. Identity is nil for API key authorization
+type AppSyncIdentity struct {
+	Sub                         string                 `json:"sub,omitempty"`
+	Issuer                      string                 `json:"issuer,omitempty"`
+	Username                    string                 `json:"username,omitempty"`
+	Claims                      map[string]interface{} `json:"claims,omitempty"`
+	Groups                      []string               `json:"groups,omitempty"`
+	SourceIP                    []string               `json:"sourceIp,omitempty"`
+	DefaultAuthStrategy         string                 `json:"defaultAuthStrategy,omitempty"`
+	AccountID                   string                 `json:"accountId,omitempty"`
+	UserARN                     string                 `json:"userArn,omitempty"`
+	CognitoIdentityPoolID       string                 `json:"cognitoIdentityPoolId,omitempty"`
+	CognitoIdentityID           string                 `json:"cognitoIdentityId,omitempty"`
+	CognitoIdentityAuthType     string                 `json:"cognitoIdentityAuthType,omitempty"`
+	CognitoIdentityAuthProvider string                 `json:"cognitoIdentityAuthProvider,omitempty"`
+	ResolverContext             map[string]interface{} `json:"resolverContext,omitempty"`
+}
+
+// AppSyncRequestInfo contains the HTTP request details of an AppSync request
+type AppSyncRequestInfo struct {
+	Headers    map[string]string `json:"headers"`
+	DomainName string            `json:"domainName"`
+}
+
+// AppSyncInfo describes the GraphQL field being resolved
+type AppSyncInfo struct {
+	FieldName           string                 `json:"fieldName"`
+	ParentTypeName      string                 `json:"parentTypeName"`
+	Variables           map[string]interface{} `json:"variables"`
+	SelectionSetList    []string               `json:"selectionSetList"`
+	SelectionSetGraphQL string                 `json:"selectionSetGraphQL"`
+}
+
+// AppSyncRequest is an AppSync resolver event with its arguments unmarshalled into T
+type AppSyncRequest[T interface{}] struct {
+	Arguments T
+	Identity  *AppSyncIdentity
+	Source    json.RawMessage
+	Request   AppSyncRequestInfo
+	Info      AppSyncInfo
+}
+
+// AppSyncError is a GraphQL error returned to the client. Return one (or wrap one) from an AppSyncRequestProcessor to
+// control the error type and message the client sees
+type AppSyncError struct {
+	ErrorType string      `json:"errorType"`
+	Message   string      `json:"errorMessage"`
+	ErrorInfo interface{} `json:"errorInfo,omitempty"`
+}
+
+func (e *AppSyncError) Error() string {
+	return e.ErrorType + ": " + e.Message
+}
+
+// AppSyncResult is the payload returned to AppSync. Exactly one of Data and Error is set
+type AppSyncResult[U interface{}] struct {
+	Data  *U            `json:"data,omitempty"`
+	Error *AppSyncError `json:"error,omitempty"`
+}
+
+type AppSyncRequestProcessor[T interface{}, U interface{}] func(ctx context.Context, request AppSyncRequest[T]) (U, error)
+
+// GetAppSyncHandler returns a lambda handler for AppSync lambda resolvers that unmarshals the resolver arguments into T.
+// The result is returned as data, or as a structured error if processRequest fails. Errors that aren't an AppSyncError
+// are logged and returned to the client as an InternalError, so that internal details aren't leaked. The resolver's
+// response mapping template should raise the error, e.g.
+//
+//	#if($ctx.result.error)
+//	  $util.error($ctx.result.error.errorMessage, $ctx.result.error.errorType, null, $ctx.result.error.errorInfo)
+//	#end
+//	$util.toJson($ctx.result.data)
+func GetAppSyncHandler[T interface{}, U interface{}](processRequest AppSyncRequestProcessor[T, U]) Handler[AppSyncResolverEvent, AppSyncResult[U]] {
+	return func(ctx context.Context, event AppSyncResolverEvent) (AppSyncResult[U], error) {
+		ctx = ContextWithStages(ctx)
+
+		request := AppSyncRequest[T]{
+			Identity: event.Identity,
+			Source:   event.Source,
+			Request:  event.Request,
+			Info:     event.Info,
+		}
+		if len(event.Arguments) > 0 {
+			err := json.Unmarshal(event.Arguments, &request.Arguments)
+			if err != nil {
+				err = StageErr(ctx, "unmarshal arguments", err)
+				GetLogger(ctx).Warn("invalid appsync arguments", "errStr", err.Error(), "field", event.Info.FieldName, "stages", getStagesLogValue(ctx))
+				return AppSyncResult[U]{Error: &AppSyncError{ErrorType: "BadRequest", Message: "invalid arguments"}}, nil
+			}
+		}
+		AddStage(ctx, "unmarshal arguments")
+
+		data, err := processRequest(ctx, request)
+		err = withCancelCause(ctx, err)
+		if IsDeadlineExceeded(ctx, err) {
+			err = flagDeadlineExceeded(ctx, err)
+		}
+		if err != nil {
+			var appSyncErr *AppSyncError
+			if errors.As(err, &appSyncErr) {
+				GetLogger(ctx).Warn("appsync resolver returned error", "errStr", err.Error(), "field", event.Info.FieldName, "stages", getStagesLogValue(ctx))
+				return AppSyncResult[U]{Error: appSyncErr}, nil
+			}
+			GetLogger(ctx).Error("appsync resolver processing failed", "errStr", err.Error(), "field", event.Info.FieldName, "errObj", err, "stages", getStagesLogValue(ctx))
+			return AppSyncResult[U]{Error: &AppSyncError{ErrorType: "InternalError", Message: "internal server error"}}, nil
+		}
+		return AppSyncResult[U]{Data: &data}, nil
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAppSyncHandler(t *testing.T) {
+
+	event := `{
+		"arguments": {"Foo": 1},
+		"identity": {"sub": "user-1", "claims": {"email": "a@example.com"}, "groups": ["admin"]},
+		"source": null,
+		"request": {"headers": {"x-api-key": "key"}},
+		"info": {"fieldName": "getItem", "parentTypeName": "Query", "selectionSetList": ["Bar"]}
+	}`
+
+	testcases := []struct {
+		name           string
+		processRequest AppSyncRequestProcessor[inputEvent, outputEvent]
+		event          string
+		checkResult    func(t *testing.T, result string)
+	}{
+		{
+			name: "Arguments, identity and info bound",
+			processRequest: func(ctx context.Context, request AppSyncRequest[inputEvent]) (outputEvent, error) {
+				assert.Equal(t, 1, request.Arguments.Foo)
+				assert.Equal(t, "user-1", request.Identity.Sub)
+				assert.Equal(t, []string{"admin"}, request.Identity.Groups)
+				assert.Equal(t, "getItem", request.Info.FieldName)
+				assert.Equal(t, "Query", request.Info.ParentTypeName)
+				assert.Equal(t, "key", request.Request.Headers["x-api-key"])
+				return outputEvent{Bar: 2}, nil
+			},
+			event: event,
+			checkResult: func(t *testing.T, result string) {
+				assert.JSONEq(t, `{"data":{"Bar":2}}`, result)
+			},
+		},
+		{
+			name: "Invalid arguments",
+			processRequest: func(ctx context.Context, request AppSyncRequest[inputEvent]) (outputEvent, error) {
+				t.Error("should not be called")
+				return outputEvent{}, nil
+			},
+			event: `{"arguments": {"Foo": "one"}, "info": {"fieldName": "getItem"}}`,
+			checkResult: func(t *testing.T, result string) {
+				assert.JSONEq(t, `{"error":{"errorType":"BadRequest","errorMessage":"invalid arguments"}}`, result)
+			},
+		},
+		{
+			name: "GraphQL error returned",
+			processRequest: func(ctx context.Context, request AppSyncRequest[inputEvent]) (outputEvent, error) {
+				return outputEvent{}, fmt.Errorf("lookup: %w", &AppSyncError{ErrorType: "NotFound", Message: "item not found", ErrorInfo: map[string]string{"id": "42"}})
+			},
+			event: event,
+			checkResult: func(t *testing.T, result string) {
+				assert.JSONEq(t, `{"error":{"errorType":"NotFound","errorMessage":"item not found","errorInfo":{"id":"42"}}}`, result)
+			},
+		},
+		{
+			name: "Processing fails",
+			processRequest: func(ctx context.Context, request AppSyncRequest[inputEvent]) (outputEvent, error) {
+				return outputEvent{}, errors.New("something bad happened")
+			},
+			event: event,
+			checkResult: func(t *testing.T, result string) {
+				assert.JSONEq(t, `{"error":{"errorType":"InternalError","errorMessage":"internal server error"}}`, result)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var resolverEvent AppSyncResolverEvent
+			assert.NoError(t, json.Unmarshal([]byte(tc.event), &resolverEvent))
+
+			handler := GetAppSyncHandler(tc.processRequest)
+			result, err := handler(context.Background(), resolverEvent)
+			assert.Nil(t, err)
+			b, err := json.Marshal(result)
+			assert.NoError(t, err)
+			tc.checkResult(t, string(b))
+		})
+	}
+}
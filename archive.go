@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3 limits user-defined metadata to 2KB in total
+const maxArchivedErrorLength = 1024
+
+// S3PutObjectAPI is the subset of the S3 client used to upload objects
+type S3PutObjectAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// WithFailedEventArchive writes the input event to S3 whenever the handler returns an error, so that exact failures
+// can be replayed later (CloudWatch truncates large log lines). The handler takes the raw payload and decodes it into
+// T, so that the archived event is byte for byte what was invoked (including fields that T doesn't declare). Objects are
+// written under <prefix>failed-events/<date>/<request ID>.json with the error message in the object metadata. S3
+// metadata must be ASCII, so other characters in the message are percent-encoded (it can be decoded with
+// url.PathUnescape)
+func WithFailedEventArchive[T interface{}, U interface{}](client S3PutObjectAPI, bucket string, prefix string, handlerFunc Handler[T, U]) Handler[json.RawMessage, U] {
+	return func(ctx context.Context, payload json.RawMessage) (U, error) {
+		var response U
+		var event T
+		err := json.Unmarshal(payload, &event)
+		if err == nil {
+			response, err = handlerFunc(ctx, event)
+		}
+		if err != nil {
+			archiveErr := archiveFailedEvent(ctx, client, bucket, prefix, payload, err)
+			if archiveErr != nil {
+				GetLogger(ctx).Error("failed to archive input event", "error", archiveErr.Error())
+			}
+		}
+		return response, err
+	}
+}
+
+func archiveFailedEvent(ctx context.Context, client S3PutObjectAPI, bucket string, prefix string, payload []byte, handlerErr error) error {
+	requestID := getRequestID(ctx)
+	errMessage := escapeMetadataValue(handlerErr.Error(), maxArchivedErrorLength)
+
+	key := fmt.Sprintf("%sfailed-events/%s/%s.json", prefix, GetClock(ctx).Now().UTC().Format("2006-01-02"), requestID)
+	//The invocation may have failed because the deadline was reached, so don't use the handler's context
+	ctx, cancel := withReportTimeout(ctx)
+	defer cancel()
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(payload),
+		ContentType: aws.String("application/json"),
+		Metadata:    map[string]string{"request-id": requestID, "error": errMessage},
+	})
+	if err != nil {
+		return err
+	}
+	GetLogger(ctx).Info("archived failed input event", "bucket", bucket, "key", key)
+	return nil
+}
+
+// escapeMetadataValue percent-encodes the characters of s that aren't printable ASCII (and '%'), keeping whole
+// characters up to max bytes of output
+func escapeMetadataValue(s string, max int) string {
+	var b strings.Builder
+	for _, r := range s {
+		escaped := string(r)
+		if r < ' ' || r > '~' || r == '%' {
+			escaped = url.PathEscape(escaped)
+		}
+		if b.Len()+len(escaped) > max {
+			break
+		}
+		b.WriteString(escaped)
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFailedEventArchive(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		err         error
+		checkResult func(t *testing.T, client *mockS3PutClient)
+	}{
+		{
+			name: "Failed event archived",
+			err:  errors.New("something bad happened"),
+			checkResult: func(t *testing.T, client *mockS3PutClient) {
+				assert.Equal(t, "archive-bucket", aws.ToString(client.input.Bucket))
+				assert.Regexp(t, `^my-function/failed-events/\d{4}-\d{2}-\d{2}/request-1\.json$`, aws.ToString(client.input.Key))
+				assert.Equal(t, "something bad happened", client.input.Metadata["error"])
+				//The raw payload is archived, rather than the decoded event
+				assert.Equal(t, `{"Foo": 7, "Unknown": [1, 2]}`, client.body)
+				//The upload has to finish within the deadline margin, as the invocation may be about to time out
+				assert.WithinDuration(t, time.Now().Add(deadlineMargin), client.deadline, time.Second)
+			},
+		},
+		{
+			name: "Non-ASCII error escaped",
+			err:  errors.New("café 100%\nfailed"),
+			checkResult: func(t *testing.T, client *mockS3PutClient) {
+				assert.Equal(t, "caf%C3%A9 100%25%0Afailed", client.input.Metadata["error"])
+			},
+		},
+		{
+			name: "Long error truncated without splitting an escape",
+			err:  errors.New(strings.Repeat("é", maxArchivedErrorLength)),
+			checkResult: func(t *testing.T, client *mockS3PutClient) {
+				message := client.input.Metadata["error"]
+				assert.Len(t, message, maxArchivedErrorLength-maxArchivedErrorLength%6)
+				decoded, err := url.PathUnescape(message)
+				assert.NoError(t, err)
+				assert.True(t, utf8.ValidString(decoded))
+			},
+		},
+		{
+			name: "Successful event not archived",
+			checkResult: func(t *testing.T, client *mockS3PutClient) {
+				assert.Nil(t, client.input)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockS3PutClient{}
+			h := WithFailedEventArchive(client, "archive-bucket", "my-function/", func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				return outputEvent{}, tc.err
+			})
+			ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "request-1"})
+			_, err := h(ctx, json.RawMessage(`{"Foo": 7, "Unknown": [1, 2]}`))
+			assert.Equal(t, tc.err, err)
+			tc.checkResult(t, client)
+		})
+	}
+}
+
+func TestWithFailedEventArchiveInvalidPayload(t *testing.T) {
+	client := &mockS3PutClient{}
+	h := WithFailedEventArchive(client, "archive-bucket", "", func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		t.Fatal("handler should not be called")
+		return outputEvent{}, nil
+	})
+	_, err := h(context.Background(), json.RawMessage(`{"Foo": "seven"}`))
+	assert.Error(t, err)
+	assert.Equal(t, `{"Foo": "seven"}`, client.body)
+}
+
+type mockS3PutClient struct {
+	input    *s3.PutObjectInput
+	body     string
+	deadline time.Time
+}
+
+func (m *mockS3PutClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	m.input = params
+	m.deadline, _ = ctx.Deadline()
+	b, _ := io.ReadAll(params.Body)
+	m.body = string(b)
+	return &s3.PutObjectOutput{}, nil
+}
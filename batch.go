@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// BatchResult is a machine-readable summary of a batch processed by a direct invocation (e.g. from Step Functions),
+// allowing the caller to handle partial success rather than a single error
+type BatchResult[U interface{}] struct {
+	Succeeded  []U              `json:"succeeded"`
+	Failed     []BatchItemError `json:"failed"`
+	DurationMs int64            `json:"durationMs"`
+}
+
+// BatchItemError identifies an item in a batch that failed and the reason it failed. Code is the error's HandlerError
+// code (if it has one), and Retryable says whether the caller can expect the item to succeed if it is retried as-is.
+// Errors are retryable unless they're marked as permanent with NonRetryable, as an unclassified error (e.g. from the
+// network) is often transient
+type BatchItemError struct {
+	ID        string `json:"id"`
+	Code      string `json:"code,omitempty"`
+	Retryable bool   `json:"retryable"`
+	Reason    string `json:"reason"`
+}
+
+// BatchResultBuilder collects the outcomes of processing a batch. It is safe for concurrent use
+type BatchResultBuilder[U interface{}] struct {
+	mu     sync.Mutex
+	clock  Clock
+	start  time.Time
+	result BatchResult[U]
+}
+
+// NewBatchResultBuilder returns a builder, with the batch duration measured from now
+func NewBatchResultBuilder[U interface{}]() *BatchResultBuilder[U] {
+	return newBatchResultBuilder[U](realClock{})
+}
+
+// newBatchResultBuilder returns a builder that measures the batch duration with clock
+func newBatchResultBuilder[U interface{}](clock Clock) *BatchResultBuilder[U] {
+	return &BatchResultBuilder[U]{
+		clock:  clock,
+		start:  clock.Now(),
+		result: BatchResult[U]{Succeeded: []U{}, Failed: []BatchItemError{}},
+	}
+}
+
+func (b *BatchResultBuilder[U]) AddSuccess(result U) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.result.Succeeded = append(b.result.Succeeded, result)
+}
+
+func (b *BatchResultBuilder[U]) AddFailure(id string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.result.Failed = append(b.result.Failed, BatchItemError{ID: id, Code: GetErrorCode(err), Retryable: !IsNonRetryable(err), Reason: err.Error()})
+}
+
+// Build returns the result, with the duration measured up to now
+func (b *BatchResultBuilder[U]) Build() BatchResult[U] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := b.result
+	result.Succeeded = append([]U{}, b.result.Succeeded...)
+	result.Failed = append([]BatchItemError{}, b.result.Failed...)
+	result.DurationMs = b.clock.Now().Sub(b.start).Milliseconds()
+	return result
+}
+
+type BatchItemProcessor[T interface{}, U interface{}] func(ctx context.Context, item T) (U, error)
+
+// GetBatchHandler returns a lambda handler for direct invocations (e.g. from a Step Functions task) with a batch of
+// items. The items are processed in parallel using the provided processItem function, and the result lists the
+// successes and the structured errors of the failed items (identified by getID) so that the caller can retry only the
+// failed, retryable items. Items that haven't finished before the deadline margin are reported as retryable
+// DeadlineExceeded failures, and an item that panics is reported as a non-retryable failure. The handler doesn't return an error when
+// items fail
+func GetBatchHandler[T interface{}, U interface{}](getID func(item T) string, processItem BatchItemProcessor[T, U]) Handler[[]T, BatchResult[U]] {
+	return func(ctx context.Context, items []T) (BatchResult[U], error) {
+		builder := newBatchResultBuilder[U](GetClock(ctx))
+		outcomes, err := processBatchOutcomes(ctx, items, batchSource[T]{
+			name: "batch item",
+			logAttrs: func(item T) []any {
+				return []any{"id", getID(item)}
+			},
+		}, processItem)
+		if err != nil {
+			return BatchResult[U]{}, err
+		}
+
+		for i, outcome := range outcomes {
+			if outcome.err != nil {
+				builder.AddFailure(getID(items[i]), outcome.err)
+			} else {
+				builder.AddSuccess(outcome.value)
+			}
+		}
+		return builder.Build(), nil
+	}
+}
+
+// IsRetryable returns true if err is transient: an error in its chain has a Retryable method that returns true (such as
+// RetryAfterError), or it was caused by running out of time or by WithConcurrencyLimit
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) && retryable.Retryable() {
+		return true
+	}
+	switch GetErrorCode(err) {
+	case ErrorCodeDeadlineExceeded, ErrorCodeConcurrencyLimitExceeded:
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// NonRetryable wraps err to mark it as permanent: retrying the same input is expected to fail in the same way (e.g. a
+// malformed message). See IsNonRetryable
+func NonRetryable(err error) error {
+	return &nonRetryableError{err: err}
+}
+
+type nonRetryableError struct {
+	err error
+}
+
+func (e *nonRetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nonRetryableError) Unwrap() error {
+	return e.err
+}
+
+func (e *nonRetryableError) Retryable() bool {
+	return false
+}
+
+// IsNonRetryable returns true if the first error in err's chain with a Retryable method (such as an error wrapped with
+// NonRetryable) returns false. Errors that don't say either way are neither retryable nor non-retryable
+func IsNonRetryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+	return errors.As(err, &retryable) && !retryable.Retryable()
+}
+
+// validateItemIdentifiers deduplicates the identifiers of failed batch items and removes any that don't belong to the
+// batch. Lambda treats an unknown identifier as a failure of the whole batch, so these are logged rather than returned
+func validateItemIdentifiers(ctx context.Context, failed []string, batch []string) []string {
+	inBatch := make(map[string]bool, len(batch))
+	for _, id := range batch {
+		inBatch[id] = true
+	}
+
+	logger := GetLogger(ctx)
+	seen := make(map[string]bool, len(failed))
+	valid := make([]string, 0, len(failed))
+	for _, id := range failed {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if !inBatch[id] {
+			logger.Error("dropping batch item failure with identifier not in batch", "itemIdentifier", id)
+			continue
+		}
+		if id == "" {
+			logger.Warn("batch item failure has an empty identifier - the whole batch will be retried")
+		}
+		valid = append(valid, id)
+	}
+	return valid
+}
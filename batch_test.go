@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateItemIdentifiers(t *testing.T) {
+
+	testcases := []struct {
+		name     string
+		failed   []string
+		expected []string
+	}{
+		{
+			name:     "Valid identifiers unchanged",
+			failed:   []string{"a", "c"},
+			expected: []string{"a", "c"},
+		},
+		{
+			name:     "Duplicates removed",
+			failed:   []string{"a", "b", "a"},
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "Unknown identifiers removed",
+			failed:   []string{"a", "z"},
+			expected: []string{"a"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := validateItemIdentifiers(context.Background(), tc.failed, []string{"a", "b", "c"})
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestBatchResultBuilder(t *testing.T) {
+	builder := NewBatchResultBuilder[int]()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				builder.AddSuccess(i)
+			} else {
+				builder.AddFailure("item-1", errors.New("something bad happened"))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result := builder.Build()
+	assert.ElementsMatch(t, []int{0, 2}, result.Succeeded)
+	assert.Len(t, result.Failed, 2)
+	assert.Equal(t, BatchItemError{ID: "item-1", Retryable: true, Reason: "something bad happened"}, result.Failed[0])
+}
+
+func TestGetBatchHandler(t *testing.T) {
+	h := GetBatchHandler(func(item inputEvent) string {
+		return strconv.Itoa(item.Foo)
+	}, func(ctx context.Context, item inputEvent) (outputEvent, error) {
+		switch item.Foo {
+		case 2:
+			return outputEvent{}, NonRetryable(NewHandlerError("ValidationError", errors.New("foo must be odd")))
+		case 3:
+			return outputEvent{}, &RetryAfterError{StatusCode: 429}
+		case 4:
+			return outputEvent{}, errors.New("connection reset by peer")
+		}
+		return outputEvent{Bar: item.Foo}, nil
+	})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, []inputEvent{{Foo: 1}, {Foo: 2}, {Foo: 3}, {Foo: 4}})
+	assert.Nil(t, err)
+	assert.Equal(t, []outputEvent{{Bar: 1}}, result.Succeeded)
+	assert.ElementsMatch(t, []BatchItemError{
+		{ID: "2", Code: "ValidationError", Retryable: false, Reason: "foo must be odd"},
+		{ID: "3", Retryable: true, Reason: (&RetryAfterError{StatusCode: 429}).Error()},
+		//Errors that aren't marked as non-retryable may be transient
+		{ID: "4", Retryable: true, Reason: "connection reset by peer"},
+	}, result.Failed)
+}
+
+func TestGetBatchHandlerTimeoutAndPanic(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(10*time.Second))
+	defer cancel()
+	ctx = ContextWithClock(ctx, clock)
+	//Process the items one at a time, so the others have finished before the last one times out
+	ctx = context.WithValue(ctx, maxWorkersKey, 1)
+
+	h := GetBatchHandler(func(item inputEvent) string {
+		return strconv.Itoa(item.Foo)
+	}, func(ctx context.Context, item inputEvent) (outputEvent, error) {
+		switch item.Foo {
+		case 2:
+			panic("something bad happened")
+		case 3:
+			clock.Advance(10 * time.Second)
+			<-ctx.Done()
+			return outputEvent{}, nil
+		}
+		return outputEvent{Bar: item.Foo}, nil
+	})
+
+	result, err := h(ctx, []inputEvent{{Foo: 1}, {Foo: 2}, {Foo: 3}})
+	assert.Nil(t, err)
+	assert.Equal(t, []outputEvent{{Bar: 1}}, result.Succeeded)
+	assert.Equal(t, []BatchItemError{
+		{ID: "2", Retryable: false, Reason: "panic: something bad happened"},
+		{ID: "3", Code: ErrorCodeDeadlineExceeded, Retryable: true, Reason: ErrDeadlineMarginReached.Error()},
+	}, result.Failed)
+	//The duration is measured with the context's clock
+	assert.Equal(t, int64(10_000), result.DurationMs)
+}
+
+func TestIsRetryable(t *testing.T) {
+
+	testcases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Nil", err: nil, expected: false},
+		{name: "Plain error", err: errors.New("something bad happened"), expected: false},
+		{name: "Retry after", err: fmt.Errorf("call: %w", &RetryAfterError{StatusCode: 503}), expected: true},
+		{name: "Deadline exceeded", err: context.DeadlineExceeded, expected: true},
+		{name: "Concurrency limit", err: NewHandlerError(ErrorCodeConcurrencyLimitExceeded, ErrConcurrencyLimitExceeded), expected: true},
+		{name: "Other code", err: NewHandlerError("ValidationError", errors.New("bad")), expected: false},
+		{name: "Non-retryable", err: NonRetryable(&RetryAfterError{StatusCode: 503}), expected: false},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsRetryable(tc.err))
+		})
+	}
+}
+
+func TestIsNonRetryable(t *testing.T) {
+	testcases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Nil", err: nil, expected: false},
+		{name: "Plain error", err: errors.New("something bad happened"), expected: false},
+		{name: "Retry after", err: &RetryAfterError{StatusCode: 503}, expected: false},
+		{name: "Non-retryable", err: fmt.Errorf("parse: %w", NonRetryable(errors.New("bad"))), expected: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsNonRetryable(tc.err))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+)
+
+const taskTokenKey = "taskToken"
+
+const (
+	// maxTaskFailureError and maxTaskFailureCause are the longest error and cause accepted by SendTaskFailure
+	maxTaskFailureError = 256
+	maxTaskFailureCause = 32768
+	// taskFailedErrorCode is the error reported for failures without a HandlerError code
+	taskFailedErrorCode = "TaskFailed"
+)
+
+// ErrNoTaskToken is returned by the task callback helpers if the context has no task token
+var ErrNoTaskToken = errors.New("context has no step functions task token")
+
+// SFNTaskCallbackAPI is the subset of the Step Functions client used to report the outcome of callback tasks
+type SFNTaskCallbackAPI interface {
+	SendTaskSuccess(ctx context.Context, params *sfn.SendTaskSuccessInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskSuccessOutput, error)
+	SendTaskFailure(ctx context.Context, params *sfn.SendTaskFailureInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskFailureOutput, error)
+	SendTaskHeartbeat(ctx context.Context, params *sfn.SendTaskHeartbeatInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskHeartbeatOutput, error)
+}
+
+// TaskTokenEvent is the payload of a callback task (.waitForTaskToken), e.g. with the parameters
+// {"taskToken.$": "$$.Task.Token", "input.$": "$"}
+type TaskTokenEvent[T interface{}] struct {
+	TaskToken string `json:"taskToken"`
+	Input     T      `json:"input"`
+}
+
+type CallbackTaskProcessor[T interface{}, U interface{}] func(ctx context.Context, input T) (U, error)
+
+type taskCallback struct {
+	client SFNTaskCallbackAPI
+	token  string
+	mu     sync.Mutex
+	sent   bool
+}
+
+// ContextWithTaskToken attaches a task token to the context, for use by SendTaskHeartbeat, SendTaskSuccess and
+// SendTaskFailure
+func ContextWithTaskToken(ctx context.Context, client SFNTaskCallbackAPI, token string) context.Context {
+	return context.WithValue(ctx, taskTokenKey, &taskCallback{client: client, token: token})
+}
+
+func getTaskCallback(ctx context.Context) (*taskCallback, error) {
+	callback, ok := ctx.Value(taskTokenKey).(*taskCallback)
+	if !ok {
+		return nil, ErrNoTaskToken
+	}
+	return callback, nil
+}
+
+// SendTaskHeartbeat reports that the callback task on the context is still running, so that it doesn't hit its
+// HeartbeatSeconds timeout
+func SendTaskHeartbeat(ctx context.Context) error {
+	callback, err := getTaskCallback(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = callback.client.SendTaskHeartbeat(ctx, &sfn.SendTaskHeartbeatInput{TaskToken: aws.String(callback.token)})
+	if err != nil {
+		GetLogger(ctx).Warn("failed to send task heartbeat", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+// SendTaskSuccess completes the callback task on the context with output marshalled as JSON
+func SendTaskSuccess(ctx context.Context, output interface{}) error {
+	callback, err := getTaskCallback(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(output)
+	if err != nil {
+		return StageErr(ctx, "marshal task output", err)
+	}
+	_, err = callback.client.SendTaskSuccess(ctx, &sfn.SendTaskSuccessInput{TaskToken: aws.String(callback.token), Output: aws.String(string(b))})
+	if err != nil {
+		return StageErr(ctx, "send task success", err)
+	}
+	AddStage(ctx, "send task success")
+	callback.markSent()
+	return nil
+}
+
+// SendTaskFailure fails the callback task on the context. The error name is taskErr's HandlerError code (or
+// "TaskFailed" if it doesn't have one) so that Retry and Catch clauses can match on it, and the cause is its message
+func SendTaskFailure(ctx context.Context, taskErr error) error {
+	callback, err := getTaskCallback(ctx)
+	if err != nil {
+		return err
+	}
+	code := GetErrorCode(taskErr)
+	if code == "" {
+		code = taskFailedErrorCode
+	}
+	_, err = callback.client.SendTaskFailure(ctx, &sfn.SendTaskFailureInput{
+		TaskToken: aws.String(callback.token),
+		Error:     aws.String(truncate(code, maxTaskFailureError)),
+		Cause:     aws.String(truncate(taskErr.Error(), maxTaskFailureCause)),
+	})
+	if err != nil {
+		return StageErr(ctx, "send task failure", err)
+	}
+	AddStage(ctx, "send task failure")
+	callback.markSent()
+	return nil
+}
+
+func (c *taskCallback) markSent() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = true
+}
+
+func (c *taskCallback) isSent() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sent
+}
+
+// GetCallbackTaskHandler returns a lambda handler for Step Functions callback tasks. The task token is attached to the
+// context (so that process can call SendTaskHeartbeat), and unless process has already sent the result itself, the
+// handler calls SendTaskSuccess with process's output or SendTaskFailure with its error. The handler only returns an
+// error if the result can't be sent
+func GetCallbackTaskHandler[T interface{}, U interface{}](client SFNTaskCallbackAPI, process CallbackTaskProcessor[T, U]) Handler[TaskTokenEvent[T], struct{}] {
+	return func(ctx context.Context, event TaskTokenEvent[T]) (struct{}, error) {
+		ctx = ContextWithTaskToken(ctx, client, event.TaskToken)
+		callback, _ := getTaskCallback(ctx)
+
+		//Stop processing before the deadline, leaving the deadline margin to send the result
+		processCtx, cancel := withDeadlineMargin(ctx)
+		output, err := process(processCtx, event.Input)
+		err = withCancelCause(processCtx, err)
+		if IsDeadlineExceeded(processCtx, err) {
+			err = flagDeadlineExceeded(ctx, err)
+		}
+		cancel()
+		if callback.isSent() {
+			return struct{}{}, nil
+		}
+
+		reportCtx, cancel := withReportTimeout(ctx)
+		defer cancel()
+		if err != nil {
+			GetLogger(ctx).Error("callback task failed", "errStr", err.Error(), "errObj", err, "stages", getStagesLogValue(ctx))
+			return struct{}{}, SendTaskFailure(reportCtx, err)
+		}
+		return struct{}{}, SendTaskSuccess(reportCtx, output)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCallbackTaskHandler(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		process     CallbackTaskProcessor[inputEvent, outputEvent]
+		timeout     time.Duration
+		checkResult func(t *testing.T, client *mockSFNClient)
+	}{
+		{
+			name: "Success sent",
+			process: func(ctx context.Context, input inputEvent) (outputEvent, error) {
+				assert.NoError(t, SendTaskHeartbeat(ctx))
+				return outputEvent{Bar: input.Foo + 1}, nil
+			},
+			checkResult: func(t *testing.T, client *mockSFNClient) {
+				assert.Equal(t, 1, client.heartbeats)
+				assert.Len(t, client.success, 1)
+				assert.Equal(t, "token-1", aws.ToString(client.success[0].TaskToken))
+				assert.JSONEq(t, `{"Bar":2}`, aws.ToString(client.success[0].Output))
+				assert.Empty(t, client.failure)
+			},
+		},
+		{
+			name: "Failure sent with error code",
+			process: func(ctx context.Context, input inputEvent) (outputEvent, error) {
+				return outputEvent{}, NewHandlerError("ValidationError", errors.New("foo is required"))
+			},
+			checkResult: func(t *testing.T, client *mockSFNClient) {
+				assert.Empty(t, client.success)
+				assert.Equal(t, "ValidationError", aws.ToString(client.failure[0].Error))
+				assert.Equal(t, "foo is required", aws.ToString(client.failure[0].Cause))
+			},
+		},
+		{
+			name: "Failure without code",
+			process: func(ctx context.Context, input inputEvent) (outputEvent, error) {
+				return outputEvent{}, errors.New("something bad happened")
+			},
+			checkResult: func(t *testing.T, client *mockSFNClient) {
+				assert.Equal(t, "TaskFailed", aws.ToString(client.failure[0].Error))
+			},
+		},
+		{
+			name:    "Deadline reached",
+			timeout: 600 * time.Millisecond,
+			process: func(ctx context.Context, input inputEvent) (outputEvent, error) {
+				<-ctx.Done()
+				return outputEvent{}, ctx.Err()
+			},
+			checkResult: func(t *testing.T, client *mockSFNClient) {
+				assert.Equal(t, ErrorCodeDeadlineExceeded, aws.ToString(client.failure[0].Error))
+				assert.Contains(t, aws.ToString(client.failure[0].Cause), ErrDeadlineMarginReached.Error())
+				assert.Equal(t, []error{nil}, client.ctxErrs)
+			},
+		},
+		{
+			name: "Result already sent by processor",
+			process: func(ctx context.Context, input inputEvent) (outputEvent, error) {
+				assert.NoError(t, SendTaskSuccess(ctx, map[string]string{"status": "early"}))
+				return outputEvent{}, nil
+			},
+			checkResult: func(t *testing.T, client *mockSFNClient) {
+				assert.Len(t, client.success, 1)
+				assert.JSONEq(t, `{"status":"early"}`, aws.ToString(client.success[0].Output))
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSFNClient{}
+			handler := GetCallbackTaskHandler(client, tc.process)
+			ctx := ContextWithStages(context.Background())
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			_, err := handler(ctx, TaskTokenEvent[inputEvent]{TaskToken: "token-1", Input: inputEvent{Foo: 1}})
+			assert.NoError(t, err)
+			tc.checkResult(t, client)
+		})
+	}
+}
+
+func TestSendTaskHeartbeatWithoutToken(t *testing.T) {
+	assert.ErrorIs(t, SendTaskHeartbeat(context.Background()), ErrNoTaskToken)
+}
+
+type mockSFNClient struct {
+	success    []*sfn.SendTaskSuccessInput
+	failure    []*sfn.SendTaskFailureInput
+	heartbeats int
+	ctxErrs    []error
+}
+
+func (m *mockSFNClient) SendTaskSuccess(ctx context.Context, params *sfn.SendTaskSuccessInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskSuccessOutput, error) {
+	m.success = append(m.success, params)
+	m.ctxErrs = append(m.ctxErrs, ctx.Err())
+	return &sfn.SendTaskSuccessOutput{}, nil
+}
+
+func (m *mockSFNClient) SendTaskFailure(ctx context.Context, params *sfn.SendTaskFailureInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskFailureOutput, error) {
+	m.failure = append(m.failure, params)
+	m.ctxErrs = append(m.ctxErrs, ctx.Err())
+	return &sfn.SendTaskFailureOutput{}, nil
+}
+
+func (m *mockSFNClient) SendTaskHeartbeat(ctx context.Context, params *sfn.SendTaskHeartbeatInput, optFns ...func(*sfn.Options)) (*sfn.SendTaskHeartbeatOutput, error) {
+	m.heartbeats++
+	return &sfn.SendTaskHeartbeatOutput{}, nil
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldCasing is a naming strategy for the field names of JSON responses
+type FieldCasing int
+
+const (
+	CamelCase FieldCasing = iota
+	SnakeCase
+)
+
+// WithFieldCasing marshals the handler's response to JSON and renames the keys of struct fields (at any depth) using
+// the given casing, so that responses are consistent regardless of the struct tags used for each type. Map keys are
+// data, so they're left as they are, as is the output of types that implement json.Marshaler. The handler returns an
+// error if two fields of a struct would have the same name after renaming (e.g. fooBar and foo_bar)
+func WithFieldCasing[T interface{}, U interface{}](casing FieldCasing, handlerFunc Handler[T, U]) Handler[T, json.RawMessage] {
+	return func(ctx context.Context, event T) (json.RawMessage, error) {
+		response, err := handlerFunc(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := json.Marshal(response)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		//Preserve numbers exactly rather than converting to float64
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		renamed, err := renameKeys(value, reflect.ValueOf(response), casing)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(renamed)
+	}
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// renameKeys renames the keys of the decoded JSON value that come from the fields of structs in source, the value it
+// was marshalled from
+func renameKeys(value interface{}, source reflect.Value, casing FieldCasing) (interface{}, error) {
+	for source.Kind() == reflect.Pointer || source.Kind() == reflect.Interface {
+		if source.IsNil() {
+			return value, nil
+		}
+		if source.Type().Implements(jsonMarshalerType) {
+			return value, nil
+		}
+		source = source.Elem()
+	}
+	if !source.IsValid() || source.Type().Implements(jsonMarshalerType) || reflect.PointerTo(source.Type()).Implements(jsonMarshalerType) {
+		return value, nil
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch source.Kind() {
+		case reflect.Struct:
+			return renameStructKeys(v, source, casing)
+		case reflect.Map:
+			for iter := source.MapRange(); iter.Next(); {
+				key, ok := jsonMapKey(iter.Key())
+				if _, found := v[key]; !ok || !found {
+					continue
+				}
+				child, err := renameKeys(v[key], iter.Value(), casing)
+				if err != nil {
+					return nil, err
+				}
+				v[key] = child
+			}
+		}
+		return v, nil
+	case []interface{}:
+		if source.Kind() != reflect.Slice && source.Kind() != reflect.Array || source.Len() != len(v) {
+			return v, nil
+		}
+		for i, child := range v {
+			renamed, err := renameKeys(child, source.Index(i), casing)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = renamed
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+func renameStructKeys(v map[string]interface{}, source reflect.Value, casing FieldCasing) (map[string]interface{}, error) {
+	fields := map[string]reflect.Value{}
+	collectJSONFields(source, fields)
+
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	renamed := make(map[string]interface{}, len(v))
+	originals := make(map[string]string, len(v))
+	for _, key := range keys {
+		newKey := convertCase(key, casing)
+		if original, exists := originals[newKey]; exists {
+			return nil, fmt.Errorf("fields %q and %q of %s both become %q", original, key, source.Type(), newKey)
+		}
+		originals[newKey] = key
+
+		child := v[key]
+		if field, ok := fields[key]; ok {
+			var err error
+			child, err = renameKeys(child, field, casing)
+			if err != nil {
+				return nil, err
+			}
+		}
+		renamed[newKey] = child
+	}
+	return renamed, nil
+}
+
+// collectJSONFields adds the fields of a struct to fields by their JSON name, including the promoted fields of
+// embedded structs (outer fields take precedence, as with encoding/json)
+func collectJSONFields(source reflect.Value, fields map[string]reflect.Value) {
+	embedded := []reflect.Value{}
+	for i := 0; i < source.NumField(); i++ {
+		field := source.Type().Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			value := source.Field(i)
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				embedded = append(embedded, value)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = source.Field(i)
+	}
+	for _, value := range embedded {
+		promoted := map[string]reflect.Value{}
+		collectJSONFields(value, promoted)
+		for name, field := range promoted {
+			if _, exists := fields[name]; !exists {
+				fields[name] = field
+			}
+		}
+	}
+}
+
+// jsonMapKey returns the key that encoding/json uses for a map key
+func jsonMapKey(key reflect.Value) (string, bool) {
+	if key.Kind() == reflect.String {
+		return key.String(), true
+	}
+	if key.CanInterface() {
+		if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+			b, err := marshaler.MarshalText()
+			return string(b), err == nil
+		}
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), true
+	}
+	return "", false
+}
+
+func convertCase(s string, casing FieldCasing) string {
+	words := splitWords(s)
+	if casing == SnakeCase {
+		return strings.Join(words, "_")
+	}
+	for i := 1; i < len(words); i++ {
+		r, size := utf8.DecodeRuneInString(words[i])
+		words[i] = string(unicode.ToUpper(r)) + words[i][size:]
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords splits an identifier in any common casing into lower case words, e.g. "HTTPStatusCode" and
+// "http_status_code" both become [http status code]
+func splitWords(s string) []string {
+	words := []string{}
+	current := []rune{}
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == ' ' {
+			if len(current) > 0 {
+				words = append(words, string(current))
+				current = []rune{}
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextIsLower {
+				words = append(words, string(current))
+				current = []rune{}
+			}
+		}
+		current = append(current, unicode.ToLower(r))
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+	return words
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFieldCasing(t *testing.T) {
+
+	type item struct {
+		ItemID   string `json:"item_id"`
+		Quantity int
+	}
+	type response struct {
+		OrderID    string
+		HTTPStatus int    `json:"httpStatus"`
+		Items      []item `json:"line-items"`
+		TotalPence int64
+	}
+
+	testcases := []struct {
+		name     string
+		casing   FieldCasing
+		expected string
+	}{
+		{
+			name:     "Camel case",
+			casing:   CamelCase,
+			expected: `{"orderId":"o-1","httpStatus":200,"lineItems":[{"itemId":"i-1","quantity":2}],"totalPence":9007199254740993}`,
+		},
+		{
+			name:     "Snake case",
+			casing:   SnakeCase,
+			expected: `{"order_id":"o-1","http_status":200,"line_items":[{"item_id":"i-1","quantity":2}],"total_pence":9007199254740993}`,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := WithFieldCasing(tc.casing, func(ctx context.Context, event inputEvent) (response, error) {
+				return response{OrderID: "o-1", HTTPStatus: 200, Items: []item{{ItemID: "i-1", Quantity: 2}}, TotalPence: 9007199254740993}, nil
+			})
+			output, err := h(context.Background(), inputEvent{})
+			assert.Nil(t, err)
+			assert.JSONEq(t, tc.expected, string(output))
+		})
+	}
+}
+
+func TestWithFieldCasingMapKeys(t *testing.T) {
+	type tagged struct {
+		DisplayName string `json:"display_name"`
+	}
+	type response struct {
+		TagSet map[string]tagged      `json:"tag_set"`
+		Labels map[string]interface{} `json:"labels"`
+		Raw    json.RawMessage        `json:"raw_value"`
+	}
+
+	h := WithFieldCasing(CamelCase, func(ctx context.Context, event inputEvent) (response, error) {
+		return response{
+			TagSet: map[string]tagged{"cost_centre": {DisplayName: "Cost"}},
+			Labels: map[string]interface{}{"team_name": map[string]string{"sub_team": "x"}},
+			Raw:    json.RawMessage(`{"keep_me":1}`),
+		}, nil
+	})
+	output, err := h(context.Background(), inputEvent{})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"tagSet":{"cost_centre":{"displayName":"Cost"}},"labels":{"team_name":{"sub_team":"x"}},"rawValue":{"keep_me":1}}`, string(output))
+}
+
+func TestWithFieldCasingCollision(t *testing.T) {
+	type response struct {
+		FooBar string `json:"fooBar"`
+		Other  string `json:"foo_bar"`
+	}
+
+	h := WithFieldCasing(SnakeCase, func(ctx context.Context, event inputEvent) (response, error) {
+		return response{FooBar: "a", Other: "b"}, nil
+	})
+	_, err := h(context.Background(), inputEvent{})
+	assert.ErrorContains(t, err, `both become "foo_bar"`)
+}
+
+func TestConvertCaseMultiByte(t *testing.T) {
+	assert.Equal(t, "straßeÉtat", convertCase("straße_état", CamelCase))
+}
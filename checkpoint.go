@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const checkpointKey = "checkpoint"
+
+// CheckpointStore persists the progress of long-running jobs between invocations
+type CheckpointStore interface {
+	Save(ctx context.Context, jobID string, state []byte) error
+	// Load returns the saved state for the job, or nil if there is no checkpoint
+	Load(ctx context.Context, jobID string) ([]byte, error)
+	Delete(ctx context.Context, jobID string) error
+}
+
+// Checkpoint saves and loads the state of a single job
+type Checkpoint struct {
+	store CheckpointStore
+	jobID string
+
+	mu      sync.Mutex
+	pending interface{}
+
+	//saveMu serialises writes to the store, so that a save before the deadline can't overwrite a cleared checkpoint
+	saveMu sync.Mutex
+	//cleared is set by Clear, and done once the handler has returned; no pending state is saved after either
+	cleared bool
+	done    bool
+}
+
+// GetCheckpoint returns the Checkpoint attached to the context by WithCheckpoint, or nil if there isn't one
+func GetCheckpoint(ctx context.Context) *Checkpoint {
+	val := ctx.Value(checkpointKey)
+	if val != nil {
+		return val.(*Checkpoint)
+	}
+	return nil
+}
+
+// WithCheckpoint attaches a Checkpoint for jobID to the context passed to the handler. If the handler is still
+// running just before the lambda deadline then the latest state passed to Checkpoint.Update is saved, so that the
+// next invocation can resume from it. The handler doesn't return until any save in progress has finished
+func WithCheckpoint[T interface{}, U interface{}](store CheckpointStore, jobID string, handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		checkpoint := &Checkpoint{store: store, jobID: jobID}
+		newContext := context.WithValue(ctx, checkpointKey, checkpoint)
+
+		deadline, hasDeadline := ctx.Deadline()
+		if hasDeadline {
+			clock := GetClock(ctx)
+			timer := clock.NewTimer(deadline.Add(-getDeadlineMargin(ctx)).Sub(clock.Now()))
+			stop := make(chan struct{})
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				select {
+				case <-timer.C():
+					checkpoint.savePending(context.WithoutCancel(newContext))
+				case <-stop:
+					timer.Stop()
+				}
+			}()
+			defer func() {
+				close(stop)
+				<-stopped
+				checkpoint.saveMu.Lock()
+				checkpoint.done = true
+				checkpoint.saveMu.Unlock()
+			}()
+		}
+
+		return handlerFunc(newContext, event)
+	}
+}
+
+// Save marshals state to JSON and saves it immediately
+func (c *Checkpoint) Save(ctx context.Context, state interface{}) error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	return c.save(ctx, state)
+}
+
+func (c *Checkpoint) save(ctx context.Context, state interface{}) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	GetLogger(ctx).Info("saving checkpoint", "jobId", c.jobID)
+	return c.store.Save(ctx, c.jobID, b)
+}
+
+// Load unmarshals the saved state into state. It returns false if there is no saved checkpoint
+func (c *Checkpoint) Load(ctx context.Context, state interface{}) (bool, error) {
+	b, err := c.store.Load(ctx, c.jobID)
+	if err != nil || b == nil {
+		return false, err
+	}
+	GetLogger(ctx).Info("loaded checkpoint", "jobId", c.jobID)
+	return true, json.Unmarshal(b, state)
+}
+
+// Update records the latest progress of the job. It is only saved if the deadline is reached before the handler returns
+func (c *Checkpoint) Update(state interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = state
+}
+
+// Clear deletes the saved checkpoint once the job has completed. A save of the pending state that is already in
+// progress finishes first, and no pending state is saved afterwards
+func (c *Checkpoint) Clear(ctx context.Context) error {
+	c.Update(nil)
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	c.cleared = true
+	GetLogger(ctx).Info("clearing checkpoint", "jobId", c.jobID)
+	return c.store.Delete(ctx, c.jobID)
+}
+
+func (c *Checkpoint) savePending(ctx context.Context) {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	if c.cleared || c.done {
+		return
+	}
+	c.mu.Lock()
+	state := c.pending
+	c.mu.Unlock()
+	if state == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, getDeadlineMargin(ctx))
+	defer cancel()
+	err := c.save(ctx, state)
+	if err != nil {
+		GetLogger(ctx).Error("failed to save checkpoint before deadline", "jobId", c.jobID, "error", err.Error())
+	}
+}
+
+// S3CheckpointAPI is the subset of the S3 client used by the S3 checkpoint store
+type S3CheckpointAPI interface {
+	S3GetObjectAPI
+	S3PutObjectAPI
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+type s3CheckpointStore struct {
+	client S3CheckpointAPI
+	bucket string
+	prefix string
+}
+
+// NewS3CheckpointStore returns a CheckpointStore that saves each job's state as an object under prefix
+func NewS3CheckpointStore(client S3CheckpointAPI, bucket string, prefix string) CheckpointStore {
+	return &s3CheckpointStore{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *s3CheckpointStore) Save(ctx context.Context, jobID string, state []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + jobID),
+		Body:   bytes.NewReader(state),
+	})
+	return err
+}
+
+func (s *s3CheckpointStore) Load(ctx context.Context, jobID string) ([]byte, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.prefix + jobID)})
+	if err != nil {
+		var noSuchKey *s3types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+func (s *s3CheckpointStore) Delete(ctx context.Context, jobID string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.prefix + jobID)})
+	return err
+}
+
+// DynamoDBCheckpointAPI is the subset of the DynamoDB client used by the DynamoDB checkpoint store
+type DynamoDBCheckpointAPI interface {
+	DynamoDBGetItemAPI
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+type dynamoDBCheckpointStore struct {
+	client DynamoDBCheckpointAPI
+	table  string
+}
+
+// NewDynamoDBCheckpointStore returns a CheckpointStore backed by a DynamoDB table with a string partition key named
+// "jobId". The state is stored in the "state" attribute
+func NewDynamoDBCheckpointStore(client DynamoDBCheckpointAPI, table string) CheckpointStore {
+	return &dynamoDBCheckpointStore{client: client, table: table}
+}
+
+func (d *dynamoDBCheckpointStore) Save(ctx context.Context, jobID string, state []byte) error {
+	_, err := d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item: map[string]ddbtypes.AttributeValue{
+			"jobId":     &ddbtypes.AttributeValueMemberS{Value: jobID},
+			"state":     &ddbtypes.AttributeValueMemberB{Value: state},
+			"updatedAt": &ddbtypes.AttributeValueMemberS{Value: GetClock(ctx).Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	return err
+}
+
+func (d *dynamoDBCheckpointStore) Load(ctx context.Context, jobID string) ([]byte, error) {
+	output, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            map[string]ddbtypes.AttributeValue{"jobId": &ddbtypes.AttributeValueMemberS{Value: jobID}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	state, ok := output.Item["state"].(*ddbtypes.AttributeValueMemberB)
+	if !ok {
+		return nil, nil
+	}
+	return state.Value, nil
+}
+
+func (d *dynamoDBCheckpointStore) Delete(ctx context.Context, jobID string) error {
+	_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.table),
+		Key:       map[string]ddbtypes.AttributeValue{"jobId": &ddbtypes.AttributeValueMemberS{Value: jobID}},
+	})
+	return err
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCheckpoint(t *testing.T) {
+
+	type jobState struct {
+		Processed int
+	}
+
+	testcases := []struct {
+		name        string
+		handler     func(clock *FakeClock, store *blockingCheckpointStore) Handler[inputEvent, outputEvent]
+		checkResult func(t *testing.T, store *blockingCheckpointStore)
+	}{
+		{
+			name: "State saved before deadline",
+			handler: func(clock *FakeClock, store *blockingCheckpointStore) Handler[inputEvent, outputEvent] {
+				return func(ctx context.Context, event inputEvent) (outputEvent, error) {
+					checkpoint := GetCheckpoint(ctx)
+					for i := 1; i <= 20; i++ {
+						checkpoint.Update(jobState{Processed: i})
+						if i == 6 {
+							for clock.PendingTimers() == 0 {
+								time.Sleep(time.Millisecond)
+							}
+							clock.Advance(10 * time.Second)
+							<-store.started
+						}
+					}
+					return outputEvent{}, nil
+				}
+			},
+			checkResult: func(t *testing.T, store *blockingCheckpointStore) {
+				state := jobState{}
+				found, err := (&Checkpoint{store: store, jobID: "job"}).Load(context.Background(), &state)
+				assert.Nil(t, err)
+				assert.True(t, found)
+				assert.Equal(t, 6, state.Processed)
+			},
+		},
+		{
+			name: "Completed job clears state",
+			handler: func(clock *FakeClock, store *blockingCheckpointStore) Handler[inputEvent, outputEvent] {
+				return func(ctx context.Context, event inputEvent) (outputEvent, error) {
+					checkpoint := GetCheckpoint(ctx)
+					err := checkpoint.Save(ctx, jobState{Processed: 1})
+					assert.Nil(t, err)
+					return outputEvent{}, checkpoint.Clear(ctx)
+				}
+			},
+			checkResult: func(t *testing.T, store *blockingCheckpointStore) {
+				assert.Empty(t, store.items)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Now())
+			ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(10*time.Second))
+			defer cancel()
+			ctx = ContextWithClock(ctx, clock)
+
+			//Saves finish immediately, but signal that they've started
+			store := &blockingCheckpointStore{
+				memoryCheckpointStore: memoryCheckpointStore{items: map[string][]byte{}},
+				started:               make(chan struct{}),
+				release:               make(chan struct{}),
+			}
+			close(store.release)
+			_, err := WithCheckpoint(store, "job", tc.handler(clock, store))(ctx, inputEvent{})
+			assert.Nil(t, err)
+			tc.checkResult(t, store)
+		})
+	}
+}
+
+func TestWithCheckpointSaveInProgress(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		clear       bool
+		expectSaved bool
+	}{
+		{name: "Handler waits for save", expectSaved: true},
+		{name: "Clear waits for save and deletes it", clear: true, expectSaved: false},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Now())
+			ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(10*time.Second))
+			defer cancel()
+			ctx = ContextWithClock(ctx, clock)
+
+			store := &blockingCheckpointStore{
+				memoryCheckpointStore: memoryCheckpointStore{items: map[string][]byte{}},
+				started:               make(chan struct{}),
+				release:               make(chan struct{}),
+			}
+			h := WithCheckpoint(store, "job", func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				checkpoint := GetCheckpoint(ctx)
+				checkpoint.Update(map[string]int{"processed": 1})
+				for clock.PendingTimers() == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				clock.Advance(10 * time.Second)
+				<-store.started
+				//Let the save finish after the handler has started to return
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					close(store.release)
+				}()
+				if tc.clear {
+					return outputEvent{}, checkpoint.Clear(ctx)
+				}
+				return outputEvent{}, nil
+			})
+			_, err := h(ctx, inputEvent{})
+			assert.Nil(t, err)
+
+			store.mu.Lock()
+			defer store.mu.Unlock()
+			_, saved := store.items["job"]
+			assert.Equal(t, tc.expectSaved, saved)
+		})
+	}
+}
+
+// blockingCheckpointStore signals when a save starts, and doesn't finish it until released
+type blockingCheckpointStore struct {
+	memoryCheckpointStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingCheckpointStore) Save(ctx context.Context, jobID string, state []byte) error {
+	close(b.started)
+	<-b.release
+	return b.memoryCheckpointStore.Save(ctx, jobID, state)
+}
+
+type memoryCheckpointStore struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func (m *memoryCheckpointStore) Save(ctx context.Context, jobID string, state []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[jobID] = state
+	return nil
+}
+
+func (m *memoryCheckpointStore) Load(ctx context.Context, jobID string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.items[jobID], nil
+}
+
+func (m *memoryCheckpointStore) Delete(ctx context.Context, jobID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, jobID)
+	return nil
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClock(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, realClock{}, GetClock(ctx))
+
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	assert.Equal(t, clock, GetClock(ContextWithClock(ctx, clock)))
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := NewFakeClock(start)
+
+	short := clock.NewTimer(time.Second)
+	long := clock.NewTimer(time.Minute)
+	stopped := clock.NewTimer(time.Second)
+	assert.True(t, stopped.Stop())
+	assert.Equal(t, 2, clock.PendingTimers())
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	assert.Equal(t, start.Add(2*time.Second), <-short.C())
+	assert.Len(t, long.C(), 0)
+	assert.Len(t, stopped.C(), 0)
+	assert.False(t, short.Stop())
+	assert.Equal(t, 1, clock.PendingTimers())
+
+	immediate := clock.NewTimer(0)
+	assert.Len(t, immediate.C(), 1)
+}
+
+func TestSleepWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	ctx := ContextWithClock(context.Background(), clock)
+
+	done := make(chan error)
+	go func() {
+		done <- Sleep(ctx, time.Hour)
+	}()
+	for clock.PendingTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Hour)
+	assert.NoError(t, <-done)
+}
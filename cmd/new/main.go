@@ -0,0 +1,85 @@
+// Command new scaffolds a lambda function that uses the handler package, for a chosen event source. It writes a
+// main.go with typed structs, a table-driven main_test.go and a payload.json sample event that can be sent to the
+// function when it is run locally (LOCAL_ADDR=:8080 go run . then curl -d @payload.json localhost:8080/endpoint).
+//
+// Usage:
+//
+//	go run github.com/ockendenjo/handler/cmd/new -source sqs -dir ./my-function
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed templates
+var templates embed.FS
+
+func main() {
+	source := flag.String("source", "", "event source: "+strings.Join(getSources(), ", "))
+	dir := flag.String("dir", ".", "directory to write the files to")
+	flag.Parse()
+
+	files, err := scaffold(*source, *dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range files {
+		fmt.Println("created", file)
+	}
+}
+
+// getSources returns the event sources that have templates
+func getSources() []string {
+	entries, _ := templates.ReadDir("templates")
+	sources := []string{}
+	for _, entry := range entries {
+		sources = append(sources, entry.Name())
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// scaffold writes the template files for source to dir and returns their paths. It fails without writing anything if
+// any of the files already exist
+func scaffold(source string, dir string) ([]string, error) {
+	root := "templates/" + source
+	entries, err := templates.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("unknown source %q, must be one of %s", source, strings.Join(getSources(), ", "))
+	}
+
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = filepath.Join(dir, strings.TrimSuffix(entry.Name(), ".tmpl"))
+		if _, err := os.Stat(paths[i]); err == nil {
+			return nil, fmt.Errorf("%s already exists", paths[i])
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		b, err := templates.ReadFile(root + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(paths[i], b, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
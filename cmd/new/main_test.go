@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaffold(t *testing.T) {
+	assert.Equal(t, []string{"apigateway", "eventbridge", "sqs"}, getSources())
+
+	for _, source := range getSources() {
+		t.Run(source, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "my-function")
+			files, err := scaffold(source, dir)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{filepath.Join(dir, "main.go"), filepath.Join(dir, "main_test.go"), filepath.Join(dir, "payload.json")}, files)
+
+			for _, file := range files {
+				b, err := os.ReadFile(file)
+				assert.NoError(t, err)
+				if filepath.Ext(file) == ".json" {
+					assert.True(t, json.Valid(b), file)
+					continue
+				}
+				formatted, err := format.Source(b)
+				assert.NoError(t, err, file)
+				assert.Equal(t, string(formatted), string(b), file)
+			}
+
+			_, err = scaffold(source, dir)
+			assert.ErrorContains(t, err, "already exists")
+		})
+	}
+
+	_, err := scaffold("kafka", t.TempDir())
+	assert.EqualError(t, err, `unknown source "kafka", must be one of apigateway, eventbridge, sqs`)
+}
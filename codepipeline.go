@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	cptypes "github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+// maxCodePipelineFailureMessage is the longest failure message accepted by PutJobFailureResult
+const maxCodePipelineFailureMessage = 5000
+
+// CodePipelineJobResultAPI is the subset of the CodePipeline client used to report job results
+type CodePipelineJobResultAPI interface {
+	PutJobSuccessResult(ctx context.Context, params *codepipeline.PutJobSuccessResultInput, optFns ...func(*codepipeline.Options)) (*codepipeline.PutJobSuccessResultOutput, error)
+	PutJobFailureResult(ctx context.Context, params *codepipeline.PutJobFailureResultInput, optFns ...func(*codepipeline.Options)) (*codepipeline.PutJobFailureResultOutput, error)
+}
+
+// CodePipelineJob is a CodePipeline custom action job with its user parameters unmarshalled into T
+type CodePipelineJob[T interface{}] struct {
+	ID                  string
+	AccountID           string
+	UserParameters      T
+	InputArtifacts      []events.CodePipelineInputArtifact
+	OutputArtifacts     []events.CodePipelineOutputArtifact
+	ArtifactCredentials events.CodePipelineArtifactCredentials
+	//ContinuationToken is the token returned by the previous invocation for this job, or empty for the first one
+	ContinuationToken string
+}
+
+// CodePipelineJobResult is reported to CodePipeline when a job succeeds. If ContinuationToken is set the action stays
+// in progress and the function is invoked again with the token (e.g. to poll a long-running deployment)
+type CodePipelineJobResult struct {
+	ContinuationToken   string
+	Summary             string
+	ExternalExecutionID string
+	OutputVariables     map[string]string
+}
+
+type CodePipelineJobProcessor[T interface{}] func(ctx context.Context, job CodePipelineJob[T]) (CodePipelineJobResult, error)
+
+type CodePipelineHandler = Handler[events.CodePipelineJobEvent, struct{}]
+
+// GetCodePipelineHandler returns a lambda handler for CodePipeline custom actions that unmarshals the job's user
+// parameters (a JSON string) into T and reports the outcome of processJob with PutJobSuccessResult or
+// PutJobFailureResult, so that the pipeline doesn't wait for the action to time out. User parameters that can't be
+// unmarshalled are reported as a ConfigurationError. The handler only returns an error if the result can't be reported
+func GetCodePipelineHandler[T interface{}](client CodePipelineJobResultAPI, processJob CodePipelineJobProcessor[T]) Handler[events.CodePipelineJobEvent, struct{}] {
+	return func(ctx context.Context, event events.CodePipelineJobEvent) (struct{}, error) {
+		ctx = ContextWithStages(ctx)
+		data := event.CodePipelineJob.Data
+		ctx = GetNewContextWithLogger(ctx, GetLogger(ctx).With("jobId", event.CodePipelineJob.ID))
+
+		job := CodePipelineJob[T]{
+			ID:                  event.CodePipelineJob.ID,
+			AccountID:           event.CodePipelineJob.AccountID,
+			InputArtifacts:      data.InputArtifacts,
+			OutputArtifacts:     data.OutPutArtifacts,
+			ArtifactCredentials: data.ArtifactCredentials,
+			ContinuationToken:   data.ContinuationToken,
+		}
+		if params := data.ActionConfiguration.Configuration.UserParameters; params != "" {
+			err := json.Unmarshal([]byte(params), &job.UserParameters)
+			if err != nil {
+				err = StageErr(ctx, "unmarshal user parameters", err)
+				GetLogger(ctx).Error("invalid codepipeline user parameters", "errStr", err.Error(), "stages", getStagesLogValue(ctx))
+				return struct{}{}, putCodePipelineJobFailure(ctx, client, job.ID, cptypes.FailureTypeConfigurationError, err)
+			}
+		}
+		AddStage(ctx, "unmarshal user parameters")
+
+		//Stop processing the job before the deadline, leaving the deadline margin to report the result
+		jobCtx, cancel := withDeadlineMargin(ctx)
+		result, err := processJob(jobCtx, job)
+		err = withCancelCause(jobCtx, err)
+		if IsDeadlineExceeded(jobCtx, err) {
+			err = flagDeadlineExceeded(ctx, err)
+		}
+		cancel()
+		if err != nil {
+			GetLogger(ctx).Error("codepipeline job failed", "errStr", err.Error(), "errObj", err, "stages", getStagesLogValue(ctx))
+			return struct{}{}, putCodePipelineJobFailure(ctx, client, job.ID, cptypes.FailureTypeJobFailed, err)
+		}
+
+		input := &codepipeline.PutJobSuccessResultInput{
+			JobId:           aws.String(job.ID),
+			OutputVariables: result.OutputVariables,
+		}
+		if result.ContinuationToken != "" {
+			input.ContinuationToken = aws.String(result.ContinuationToken)
+		}
+		if result.Summary != "" || result.ExternalExecutionID != "" {
+			input.ExecutionDetails = &cptypes.ExecutionDetails{}
+			if result.Summary != "" {
+				input.ExecutionDetails.Summary = aws.String(result.Summary)
+			}
+			if result.ExternalExecutionID != "" {
+				input.ExecutionDetails.ExternalExecutionId = aws.String(result.ExternalExecutionID)
+			}
+		}
+		reportCtx, cancel := withReportTimeout(ctx)
+		defer cancel()
+		_, err = client.PutJobSuccessResult(reportCtx, input)
+		if err != nil {
+			return struct{}{}, StageErr(ctx, "put job success result", err)
+		}
+		AddStage(ctx, "put job success result")
+		return struct{}{}, nil
+	}
+}
+
+func putCodePipelineJobFailure(ctx context.Context, client CodePipelineJobResultAPI, jobID string, failureType cptypes.FailureType, jobErr error) error {
+	reportCtx, cancel := withReportTimeout(ctx)
+	defer cancel()
+	_, err := client.PutJobFailureResult(reportCtx, &codepipeline.PutJobFailureResultInput{
+		JobId:          aws.String(jobID),
+		FailureDetails: &cptypes.FailureDetails{Type: failureType, Message: aws.String(truncate(jobErr.Error(), maxCodePipelineFailureMessage))},
+	})
+	if err != nil {
+		return StageErr(ctx, "put job failure result", fmt.Errorf("%w (job error: %w)", err, jobErr))
+	}
+	AddStage(ctx, "put job failure result")
+	return nil
+}
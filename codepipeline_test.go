@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	cptypes "github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type deployParameters struct {
+	Environment string `json:"environment"`
+}
+
+func TestGetCodePipelineHandler(t *testing.T) {
+
+	newEvent := func(userParameters string, continuationToken string) events.CodePipelineJobEvent {
+		return events.CodePipelineJobEvent{CodePipelineJob: events.CodePipelineJob{ID: "job-1", Data: events.CodePipelineData{
+			ActionConfiguration: events.CodePipelineActionConfiguration{Configuration: events.CodePipelineConfiguration{UserParameters: userParameters}},
+			ContinuationToken:   continuationToken,
+		}}}
+	}
+
+	testcases := []struct {
+		name        string
+		event       events.CodePipelineJobEvent
+		processJob  CodePipelineJobProcessor[deployParameters]
+		putErr      error
+		timeout     time.Duration
+		expectErr   bool
+		checkResult func(t *testing.T, client *mockCodePipelineClient)
+	}{
+		{
+			name:  "Success reported",
+			event: newEvent(`{"environment": "prod"}`, ""),
+			processJob: func(ctx context.Context, job CodePipelineJob[deployParameters]) (CodePipelineJobResult, error) {
+				assert.Equal(t, "prod", job.UserParameters.Environment)
+				return CodePipelineJobResult{Summary: "deployed", OutputVariables: map[string]string{"version": "42"}}, nil
+			},
+			checkResult: func(t *testing.T, client *mockCodePipelineClient) {
+				assert.Len(t, client.success, 1)
+				assert.Empty(t, client.failure)
+				assert.Equal(t, "job-1", aws.ToString(client.success[0].JobId))
+				assert.Equal(t, "deployed", aws.ToString(client.success[0].ExecutionDetails.Summary))
+				assert.Equal(t, map[string]string{"version": "42"}, client.success[0].OutputVariables)
+				assert.Nil(t, client.success[0].ContinuationToken)
+			},
+		},
+		{
+			name:  "Continuation",
+			event: newEvent("", "poll-1"),
+			processJob: func(ctx context.Context, job CodePipelineJob[deployParameters]) (CodePipelineJobResult, error) {
+				assert.Equal(t, "poll-1", job.ContinuationToken)
+				return CodePipelineJobResult{ContinuationToken: "poll-2"}, nil
+			},
+			checkResult: func(t *testing.T, client *mockCodePipelineClient) {
+				assert.Equal(t, "poll-2", aws.ToString(client.success[0].ContinuationToken))
+				assert.Nil(t, client.success[0].ExecutionDetails)
+			},
+		},
+		{
+			name:  "Failure reported",
+			event: newEvent("", ""),
+			processJob: func(ctx context.Context, job CodePipelineJob[deployParameters]) (CodePipelineJobResult, error) {
+				return CodePipelineJobResult{}, errors.New(strings.Repeat("x", 6000))
+			},
+			checkResult: func(t *testing.T, client *mockCodePipelineClient) {
+				assert.Empty(t, client.success)
+				assert.Equal(t, cptypes.FailureTypeJobFailed, client.failure[0].FailureDetails.Type)
+				assert.Len(t, aws.ToString(client.failure[0].FailureDetails.Message), maxCodePipelineFailureMessage)
+			},
+		},
+		{
+			name:  "Failure message truncated on a character boundary",
+			event: newEvent("", ""),
+			processJob: func(ctx context.Context, job CodePipelineJob[deployParameters]) (CodePipelineJobResult, error) {
+				return CodePipelineJobResult{}, errors.New("x" + strings.Repeat("é", 3000))
+			},
+			checkResult: func(t *testing.T, client *mockCodePipelineClient) {
+				message := aws.ToString(client.failure[0].FailureDetails.Message)
+				assert.True(t, utf8.ValidString(message))
+				assert.Len(t, message, maxCodePipelineFailureMessage-1)
+			},
+		},
+		{
+			name:    "Deadline reached",
+			event:   newEvent("", ""),
+			timeout: 600 * time.Millisecond,
+			processJob: func(ctx context.Context, job CodePipelineJob[deployParameters]) (CodePipelineJobResult, error) {
+				<-ctx.Done()
+				return CodePipelineJobResult{}, ctx.Err()
+			},
+			checkResult: func(t *testing.T, client *mockCodePipelineClient) {
+				assert.Equal(t, cptypes.FailureTypeJobFailed, client.failure[0].FailureDetails.Type)
+				assert.Contains(t, aws.ToString(client.failure[0].FailureDetails.Message), ErrDeadlineMarginReached.Error())
+				assert.Equal(t, []error{nil}, client.ctxErrs)
+			},
+		},
+		{
+			name:  "Invalid user parameters",
+			event: newEvent("not json", ""),
+			processJob: func(ctx context.Context, job CodePipelineJob[deployParameters]) (CodePipelineJobResult, error) {
+				t.Error("should not be called")
+				return CodePipelineJobResult{}, nil
+			},
+			checkResult: func(t *testing.T, client *mockCodePipelineClient) {
+				assert.Equal(t, cptypes.FailureTypeConfigurationError, client.failure[0].FailureDetails.Type)
+			},
+		},
+		{
+			name:  "Result can't be reported",
+			event: newEvent("", ""),
+			processJob: func(ctx context.Context, job CodePipelineJob[deployParameters]) (CodePipelineJobResult, error) {
+				return CodePipelineJobResult{}, nil
+			},
+			putErr:    errors.New("throttled"),
+			expectErr: true,
+			checkResult: func(t *testing.T, client *mockCodePipelineClient) {
+				assert.Len(t, client.success, 1)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockCodePipelineClient{putErr: tc.putErr}
+			handler := GetCodePipelineHandler(client, tc.processJob)
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			_, err := handler(ctx, tc.event)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.checkResult(t, client)
+		})
+	}
+}
+
+type mockCodePipelineClient struct {
+	success []*codepipeline.PutJobSuccessResultInput
+	failure []*codepipeline.PutJobFailureResultInput
+	putErr  error
+	ctxErrs []error
+}
+
+func (m *mockCodePipelineClient) PutJobSuccessResult(ctx context.Context, params *codepipeline.PutJobSuccessResultInput, optFns ...func(*codepipeline.Options)) (*codepipeline.PutJobSuccessResultOutput, error) {
+	m.success = append(m.success, params)
+	m.ctxErrs = append(m.ctxErrs, ctx.Err())
+	return &codepipeline.PutJobSuccessResultOutput{}, m.putErr
+}
+
+func (m *mockCodePipelineClient) PutJobFailureResult(ctx context.Context, params *codepipeline.PutJobFailureResultInput, optFns ...func(*codepipeline.Options)) (*codepipeline.PutJobFailureResultOutput, error) {
+	m.failure = append(m.failure, params)
+	m.ctxErrs = append(m.ctxErrs, ctx.Err())
+	return &codepipeline.PutJobFailureResultOutput{}, m.putErr
+}
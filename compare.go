@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Difference is a value that differs between the expected and actual values passed to CompareJSON. Expected or Actual
+// is nil if the path is missing from that side
+type Difference struct {
+	Path     string      `json:"path"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+}
+
+// CompareJSON compares the JSON encodings of expected and actual and returns the values that differ, with paths such as
+// "items.0.id". Paths matching an ignore path are skipped; a "*" segment in an ignore path matches any key or index,
+// e.g. "items.*.updatedAt". If there are differences they are recorded as a stage and logged, for canary and shadow
+// handlers
+func CompareJSON(ctx context.Context, expected interface{}, actual interface{}, ignorePaths ...string) ([]Difference, error) {
+	expectedValue, err := toJSONValue(expected)
+	if err != nil {
+		return nil, fmt.Errorf("marshal expected: %w", err)
+	}
+	actualValue, err := toJSONValue(actual)
+	if err != nil {
+		return nil, fmt.Errorf("marshal actual: %w", err)
+	}
+
+	ignore := make([][]string, len(ignorePaths))
+	for i, path := range ignorePaths {
+		ignore[i] = strings.Split(path, ".")
+	}
+	differences := []Difference{}
+	compareJSONValues(nil, expectedValue, actualValue, ignore, &differences)
+
+	if len(differences) > 0 {
+		AddStage(ctx, fmt.Sprintf("compare outputs: %d differences", len(differences)))
+		GetLogger(ctx).Warn("outputs differ", "differences", differences, "stages", getStagesLogValue(ctx))
+	}
+	return differences, nil
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	//Compare numbers exactly rather than as float64
+	decoder.UseNumber()
+	var value interface{}
+	err = decoder.Decode(&value)
+	return value, err
+}
+
+func compareJSONValues(path []string, expected interface{}, actual interface{}, ignore [][]string, differences *[]Difference) {
+	if matchesJSONPath(path, ignore) {
+		return
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		if a, ok := actual.(map[string]interface{}); ok {
+			keys := map[string]bool{}
+			for k := range e {
+				keys[k] = true
+			}
+			for k := range a {
+				keys[k] = true
+			}
+			sorted := make([]string, 0, len(keys))
+			for k := range keys {
+				sorted = append(sorted, k)
+			}
+			sort.Strings(sorted)
+			for _, k := range sorted {
+				compareJSONValues(append(path[:len(path):len(path)], k), e[k], a[k], ignore, differences)
+			}
+			return
+		}
+	case []interface{}:
+		if a, ok := actual.([]interface{}); ok {
+			for i := 0; i < max(len(e), len(a)); i++ {
+				var ev, av interface{}
+				if i < len(e) {
+					ev = e[i]
+				}
+				if i < len(a) {
+					av = a[i]
+				}
+				compareJSONValues(append(path[:len(path):len(path)], strconv.Itoa(i)), ev, av, ignore, differences)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		*differences = append(*differences, Difference{Path: strings.Join(path, "."), Expected: expected, Actual: actual})
+	}
+}
+
+// matchesJSONPath returns true if path matches one of the patterns, where a "*" segment matches any key or index
+func matchesJSONPath(path []string, patterns [][]string) bool {
+	for _, pattern := range patterns {
+		if len(pattern) != len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range pattern {
+			if segment != "*" && segment != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
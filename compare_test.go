@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareJSON(t *testing.T) {
+	expected := map[string]interface{}{
+		"id":    "1",
+		"total": 10.5,
+		"items": []map[string]interface{}{{"sku": "a", "updatedAt": "2024-01-01"}, {"sku": "b", "updatedAt": "2024-01-01"}},
+	}
+
+	testcases := []struct {
+		name        string
+		actual      interface{}
+		ignorePaths []string
+		expected    []Difference
+	}{
+		{
+			name:     "Equal",
+			actual:   expected,
+			expected: []Difference{},
+		},
+		{
+			name: "Changed, missing and extra values",
+			actual: map[string]interface{}{
+				"id":    "1",
+				"total": 11,
+				"items": []map[string]interface{}{{"sku": "a", "updatedAt": "2024-01-01"}},
+				"extra": true,
+			},
+			expected: []Difference{
+				{Path: "extra", Actual: true},
+				{Path: "items.1", Expected: map[string]interface{}{"sku": "b", "updatedAt": "2024-01-01"}},
+				{Path: "total", Expected: json.Number("10.5"), Actual: json.Number("11")},
+			},
+		},
+		{
+			name: "Ignored paths",
+			actual: map[string]interface{}{
+				"id":    "2",
+				"total": 10.5,
+				"items": []map[string]interface{}{{"sku": "a", "updatedAt": "2024-02-01"}, {"sku": "b", "updatedAt": "2024-02-01"}},
+			},
+			ignorePaths: []string{"id", "items.*.updatedAt"},
+			expected:    []Difference{},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := ContextWithStages(context.Background())
+			differences, err := CompareJSON(ctx, expected, tc.actual, tc.ignorePaths...)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, differences)
+		})
+	}
+}
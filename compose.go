@@ -0,0 +1,57 @@
+package handler
+
+import "context"
+
+// PreProcessor converts a raw event into the event type of a handler, e.g. to strip an envelope, decrypt a payload or
+// enrich the event
+type PreProcessor[R interface{}, T interface{}] func(ctx context.Context, event R) (T, error)
+
+// Compose returns a handler that runs pre on the raw event and passes the result to handlerFunc. Pre-processors can be
+// chained by composing again, e.g. Compose(unwrap, Compose(decrypt, h)). If pre fails the handler isn't called and the
+// error is returned wrapped with a "pre-process event" stage
+func Compose[R interface{}, T interface{}, U interface{}](pre PreProcessor[R, T], handlerFunc Handler[T, U]) Handler[R, U] {
+	return func(ctx context.Context, event R) (U, error) {
+		processed, err := pre(ctx, event)
+		if err != nil {
+			var zero U
+			return zero, StageErr(ctx, "pre-process event", err)
+		}
+		return handlerFunc(ctx, processed)
+	}
+}
+
+// PostProcessor transforms the response of a handler, e.g. to add standard metadata fields, strip internal fields or
+// validate the response before it is marshalled
+type PostProcessor[U interface{}, V interface{}] func(ctx context.Context, response U) (V, error)
+
+// ComposePost returns a handler that runs post on the response of handlerFunc. post isn't called if the handler
+// returns an error. If post fails the error is returned wrapped with a "post-process response" stage
+func ComposePost[T interface{}, U interface{}, V interface{}](handlerFunc Handler[T, U], post PostProcessor[U, V]) Handler[T, V] {
+	return func(ctx context.Context, event T) (V, error) {
+		var zero V
+		response, err := handlerFunc(ctx, event)
+		if err != nil {
+			return zero, err
+		}
+		processed, err := post(ctx, response)
+		if err != nil {
+			return zero, StageErr(ctx, "post-process response", err)
+		}
+		return processed, nil
+	}
+}
+
+// ChainPost combines post-processors that don't change the response type into one that runs them in order, so that a
+// service can define its standard chain once and apply it to every handler with ComposePost
+func ChainPost[U interface{}](posts ...PostProcessor[U, U]) PostProcessor[U, U] {
+	return func(ctx context.Context, response U) (U, error) {
+		for _, post := range posts {
+			var err error
+			response, err = post(ctx, response)
+			if err != nil {
+				return response, err
+			}
+		}
+		return response, nil
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompose(t *testing.T) {
+
+	type envelope struct {
+		Payload string
+	}
+
+	unwrap := func(ctx context.Context, event envelope) (string, error) {
+		if event.Payload == "" {
+			return "", errors.New("empty payload")
+		}
+		return event.Payload, nil
+	}
+	parse := func(ctx context.Context, payload string) (inputEvent, error) {
+		event := inputEvent{}
+		err := json.Unmarshal([]byte(payload), &event)
+		return event, err
+	}
+	h := Compose(unwrap, Compose(parse, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		return outputEvent{Bar: event.Foo * 2}, nil
+	}))
+
+	testcases := []struct {
+		name        string
+		event       envelope
+		checkResult func(t *testing.T, output outputEvent, err error, stages []string)
+	}{
+		{
+			name:  "Event pre-processed",
+			event: envelope{Payload: `{"Foo":4}`},
+			checkResult: func(t *testing.T, output outputEvent, err error, stages []string) {
+				assert.Nil(t, err)
+				assert.Equal(t, outputEvent{Bar: 8}, output)
+				assert.Empty(t, stages)
+			},
+		},
+		{
+			name:  "Pre-processor fails",
+			event: envelope{},
+			checkResult: func(t *testing.T, output outputEvent, err error, stages []string) {
+				assert.EqualError(t, err, "pre-process event: empty payload")
+				assert.Equal(t, []string{"pre-process event"}, stages)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := ContextWithStages(context.Background())
+			output, err := h(ctx, tc.event)
+			tc.checkResult(t, output, err, getStageDescriptions(ctx))
+		})
+	}
+}
+
+func TestComposePost(t *testing.T) {
+
+	double := func(ctx context.Context, response outputEvent) (outputEvent, error) {
+		return outputEvent{Bar: response.Bar * 2}, nil
+	}
+	validate := func(ctx context.Context, response outputEvent) (outputEvent, error) {
+		if response.Bar > 100 {
+			return response, errors.New("bar too large")
+		}
+		return response, nil
+	}
+	toMap := func(ctx context.Context, response outputEvent) (map[string]int, error) {
+		return map[string]int{"bar": response.Bar, "version": 2}, nil
+	}
+
+	testcases := []struct {
+		name        string
+		handlerErr  error
+		foo         int
+		checkResult func(t *testing.T, output map[string]int, err error, stages []string)
+	}{
+		{
+			name: "Response post-processed",
+			foo:  3,
+			checkResult: func(t *testing.T, output map[string]int, err error, stages []string) {
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]int{"bar": 6, "version": 2}, output)
+			},
+		},
+		{
+			name: "Post-processor fails",
+			foo:  51,
+			checkResult: func(t *testing.T, output map[string]int, err error, stages []string) {
+				assert.EqualError(t, err, "post-process response: bar too large")
+				assert.Nil(t, output)
+				assert.Equal(t, []string{"post-process response"}, stages)
+			},
+		},
+		{
+			name:       "Handler fails",
+			handlerErr: errors.New("something bad happened"),
+			checkResult: func(t *testing.T, output map[string]int, err error, stages []string) {
+				assert.EqualError(t, err, "something bad happened")
+				assert.Empty(t, stages)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := ComposePost(ComposePost(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				return outputEvent{Bar: event.Foo}, tc.handlerErr
+			}, ChainPost(double, validate)), toMap)
+
+			ctx := ContextWithStages(context.Background())
+			output, err := h(ctx, inputEvent{Foo: tc.foo})
+			tc.checkResult(t, output, err, getStageDescriptions(ctx))
+		})
+	}
+}
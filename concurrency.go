@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrorCodeConcurrencyLimitExceeded is the code given to errors returned when WithConcurrencyLimit rejects an
+// invocation. The invocation didn't run, so it is safe to retry
+const ErrorCodeConcurrencyLimitExceeded = "ConcurrencyLimitExceeded"
+
+// ErrConcurrencyLimitExceeded is wrapped by the error returned when WithConcurrencyLimit rejects an invocation
+var ErrConcurrencyLimitExceeded = errors.New("sandbox concurrency limit exceeded")
+
+// WithConcurrencyLimit limits how many invocations run handlerFunc at the same time in this sandbox, to protect
+// dependencies that aren't safe for concurrent use (e.g. native libraries when parallel direct invokes share a
+// sandbox). If wait is true an invocation over the limit waits for a slot until its context is done, otherwise it is
+// rejected immediately. A rejected invocation returns a HandlerError with the ConcurrencyLimitExceeded code (see
+// WithErrorTypes), so that the caller can retry it. It panics if limit is less than 1, as no invocation could ever run
+func WithConcurrencyLimit[T interface{}, U interface{}](limit int, wait bool, handlerFunc Handler[T, U]) Handler[T, U] {
+	if limit < 1 {
+		panic(fmt.Errorf("concurrency limit must be at least 1, got %d", limit))
+	}
+	slots := make(chan struct{}, limit)
+	return func(ctx context.Context, event T) (U, error) {
+		var zero U
+		select {
+		case slots <- struct{}{}:
+		default:
+			if !wait {
+				EmitMetric(ctx, "ConcurrencyLimitExceeded", 1, UnitCount, nil)
+				return zero, NewHandlerError(ErrorCodeConcurrencyLimitExceeded, ErrConcurrencyLimitExceeded)
+			}
+			AddStage(ctx, "wait for concurrency slot")
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				EmitMetric(ctx, "ConcurrencyLimitExceeded", 1, UnitCount, nil)
+				err := withCancelCause(ctx, ctx.Err())
+				return zero, NewHandlerError(ErrorCodeConcurrencyLimitExceeded, fmt.Errorf("%w: %w", ErrConcurrencyLimitExceeded, err))
+			}
+		}
+		defer func() { <-slots }()
+		return handlerFunc(ctx, event)
+	}
+}
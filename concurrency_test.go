@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithConcurrencyLimit(t *testing.T) {
+
+	testcases := []struct {
+		name      string
+		wait      bool
+		timeout   time.Duration
+		expectErr bool
+	}{
+		{name: "Rejected immediately", wait: false, timeout: time.Second, expectErr: true},
+		{name: "Waits for a slot", wait: true, timeout: time.Second, expectErr: false},
+		{name: "Gives up waiting when the context is done", wait: true, timeout: 20 * time.Millisecond, expectErr: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			h := WithConcurrencyLimit(1, tc.wait, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				if event.Foo == 1 {
+					close(started)
+					<-release
+				}
+				return outputEvent{Bar: event.Foo}, nil
+			})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, err := h(context.Background(), inputEvent{Foo: 1})
+				assert.Nil(t, err)
+			}()
+			<-started
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			result, err := h(ctx, inputEvent{Foo: 2})
+			<-done
+
+			if tc.expectErr {
+				assert.True(t, errors.Is(err, ErrConcurrencyLimitExceeded))
+				assert.Equal(t, ErrorCodeConcurrencyLimitExceeded, GetErrorCode(err))
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, 2, result.Bar)
+			}
+		})
+	}
+}
+
+func TestWithConcurrencyLimitInvalid(t *testing.T) {
+	assert.PanicsWithError(t, "concurrency limit must be at least 1, got 0", func() {
+		WithConcurrencyLimit(0, false, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+			return outputEvent{}, nil
+		})
+	})
+}
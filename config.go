@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/appconfigdata"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+const configKey = "config"
+
+// ConfigSource fetches a JSON configuration document. It returns nil if the document hasn't changed since the last
+// fetch (as AppConfig does)
+type ConfigSource func(ctx context.Context) ([]byte, error)
+
+// NewSSMConfigSource returns a ConfigSource that reads the value of an SSM parameter
+func NewSSMConfigSource(client SSMGetParameterAPI, name string) ConfigSource {
+	return func(ctx context.Context) ([]byte, error) {
+		output, err := client.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name), WithDecryption: aws.Bool(true)})
+		if err != nil {
+			return nil, err
+		}
+		return []byte(aws.ToString(output.Parameter.Value)), nil
+	}
+}
+
+// NewS3ConfigSource returns a ConfigSource that reads an S3 object
+func NewS3ConfigSource(client S3GetObjectAPI, bucket string, key string) ConfigSource {
+	return func(ctx context.Context) ([]byte, error) {
+		output, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil {
+			return nil, err
+		}
+		defer output.Body.Close()
+		return io.ReadAll(output.Body)
+	}
+}
+
+// AppConfigDataAPI is the subset of the AppConfig Data client used by the AppConfig config source
+type AppConfigDataAPI interface {
+	StartConfigurationSession(ctx context.Context, params *appconfigdata.StartConfigurationSessionInput, optFns ...func(*appconfigdata.Options)) (*appconfigdata.StartConfigurationSessionOutput, error)
+	GetLatestConfiguration(ctx context.Context, params *appconfigdata.GetLatestConfigurationInput, optFns ...func(*appconfigdata.Options)) (*appconfigdata.GetLatestConfigurationOutput, error)
+}
+
+// NewAppConfigSource returns a ConfigSource that polls an AppConfig configuration profile. The session is started on the
+// first fetch and kept for the life of the sandbox
+func NewAppConfigSource(client AppConfigDataAPI, application string, environment string, profile string) ConfigSource {
+	var token *string
+	return func(ctx context.Context) ([]byte, error) {
+		if token == nil {
+			session, err := client.StartConfigurationSession(ctx, &appconfigdata.StartConfigurationSessionInput{
+				ApplicationIdentifier:          aws.String(application),
+				EnvironmentIdentifier:          aws.String(environment),
+				ConfigurationProfileIdentifier: aws.String(profile),
+			})
+			if err != nil {
+				return nil, err
+			}
+			token = session.InitialConfigurationToken
+		}
+		output, err := client.GetLatestConfiguration(ctx, &appconfigdata.GetLatestConfigurationInput{ConfigurationToken: token})
+		if err != nil {
+			//Start a new session next time, in case the token has expired
+			token = nil
+			return nil, err
+		}
+		token = output.NextPollConfigurationToken
+		if len(output.Configuration) == 0 {
+			return nil, nil
+		}
+		return output.Configuration, nil
+	}
+}
+
+// ConfigChangeCallback is called with the previous and new configuration when it changes. previous is nil for the first
+// load
+type ConfigChangeCallback[C interface{}] func(ctx context.Context, previous *C, current *C)
+
+// ConfigWatcher keeps a sandbox-wide snapshot of a configuration document, refreshed at most once per interval, so that
+// long-lived sandboxes pick up changes without a redeploy
+type ConfigWatcher[C interface{}] struct {
+	source   ConfigSource
+	interval time.Duration
+
+	mu        sync.Mutex
+	current   *C
+	raw       []byte
+	fetched   time.Time
+	callbacks []ConfigChangeCallback[C]
+}
+
+func NewConfigWatcher[C interface{}](source ConfigSource, interval time.Duration) *ConfigWatcher[C] {
+	return &ConfigWatcher[C]{source: source, interval: interval}
+}
+
+// OnChange registers a callback that is called when the configuration changes
+func (w *ConfigWatcher[C]) OnChange(callback ConfigChangeCallback[C]) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callbacks = append(w.callbacks, callback)
+}
+
+// Get returns the configuration snapshot, refreshing it first if it is older than the interval. If a refresh fails the
+// previous snapshot is returned (and the error is logged), unless there isn't one yet
+func (w *ConfigWatcher[C]) Get(ctx context.Context) (*C, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current != nil && GetClock(ctx).Now().Sub(w.fetched) < w.interval {
+		return w.current, nil
+	}
+
+	err := w.refresh(ctx)
+	if err != nil {
+		if w.current == nil {
+			return nil, err
+		}
+		GetLogger(ctx).Warn("failed to refresh config, using previous snapshot", "error", err.Error())
+	}
+	return w.current, nil
+}
+
+// Start refreshes the configuration in the background every interval until ctx is done. Lambda freezes the sandbox
+// between invocations, so refreshes only happen while it is running; Get refreshes a stale snapshot regardless
+func (w *ConfigWatcher[C]) Start(ctx context.Context) {
+	clock := GetClock(ctx)
+	go func() {
+		for {
+			timer := clock.NewTimer(w.interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+			w.mu.Lock()
+			err := w.refresh(ctx)
+			w.mu.Unlock()
+			if err != nil {
+				GetLogger(ctx).Warn("failed to refresh config", "error", err.Error())
+			}
+		}
+	}()
+}
+
+// refresh must be called with w.mu held
+func (w *ConfigWatcher[C]) refresh(ctx context.Context) error {
+	raw, err := w.source(ctx)
+	if err != nil {
+		return err
+	}
+	w.fetched = GetClock(ctx).Now()
+	if raw == nil || (w.current != nil && bytes.Equal(raw, w.raw)) {
+		return nil
+	}
+
+	config := new(C)
+	err = json.Unmarshal(raw, config)
+	if err != nil {
+		return err
+	}
+	previous := w.current
+	w.current = config
+	w.raw = raw
+	if previous != nil {
+		GetLogger(ctx).Info("config changed")
+	}
+	for _, callback := range w.callbacks {
+		callback(ctx, previous, config)
+	}
+	return nil
+}
+
+// WithConfig attaches the watcher's configuration snapshot to the context of each invocation (see GetConfig). The
+// snapshot doesn't change during an invocation
+func WithConfig[C interface{}, T interface{}, U interface{}](watcher *ConfigWatcher[C], handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		config, err := watcher.Get(ctx)
+		if err != nil {
+			var response U
+			return response, StageErr(ctx, "load config", err)
+		}
+		return handlerFunc(context.WithValue(ctx, configKey, config), event)
+	}
+}
+
+// GetConfig returns the configuration attached to the context by WithConfig, or nil if there isn't one
+func GetConfig[C interface{}](ctx context.Context) *C {
+	config, _ := ctx.Value(configKey).(*C)
+	return config
+}
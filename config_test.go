@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Limit int
+}
+
+func TestConfigWatcher(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	ctx := ContextWithClock(context.Background(), clock)
+
+	documents := []string{`{"Limit":1}`, `{"Limit":1}`, `{"Limit":2}`}
+	fetches := 0
+	var fetchErr error
+	watcher := NewConfigWatcher[testConfig](func(ctx context.Context) ([]byte, error) {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		fetches++
+		return []byte(documents[fetches-1]), nil
+	}, time.Minute)
+
+	changes := []int{}
+	watcher.OnChange(func(ctx context.Context, previous *testConfig, current *testConfig) {
+		changes = append(changes, current.Limit)
+	})
+
+	h := WithConfig(watcher, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		return outputEvent{Bar: GetConfig[testConfig](ctx).Limit}, nil
+	})
+	invoke := func() int {
+		response, err := h(ctx, inputEvent{})
+		assert.Nil(t, err)
+		return response.Bar
+	}
+
+	assert.Equal(t, 1, invoke())
+	assert.Equal(t, 1, invoke())
+	assert.Equal(t, 1, fetches)
+
+	//Unchanged document
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, invoke())
+	assert.Equal(t, 2, fetches)
+
+	//Failed refresh keeps the previous snapshot
+	clock.Advance(time.Minute)
+	fetchErr = errors.New("something bad happened")
+	assert.Equal(t, 1, invoke())
+
+	fetchErr = nil
+	assert.Equal(t, 2, invoke())
+	assert.Equal(t, []int{1, 2}, changes)
+}
+
+func TestWithConfigLoadFails(t *testing.T) {
+	watcher := NewConfigWatcher[testConfig](func(ctx context.Context) ([]byte, error) {
+		return nil, errors.New("something bad happened")
+	}, time.Minute)
+	h := WithConfig(watcher, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		return outputEvent{}, nil
+	})
+	_, err := h(context.Background(), inputEvent{})
+	assert.EqualError(t, err, "load config: something bad happened")
+}
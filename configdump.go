@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretPatterns are the parts of environment variable names whose values are never logged
+var secretPatterns = []string{"SECRET", "PASSWORD", "PASSWD", "TOKEN", "CREDENTIAL", "PRIVATE", "API_KEY", "ACCESS_KEY"}
+
+// LogConfiguration logs the effective configuration of the sandbox: environment variables, Lambda function details
+// and Go runtime details. Only environment variables matching LOG_CONFIG_ALLOWLIST (comma-separated names, or prefixes
+// ending with *) are included, so none are logged if it isn't set: values such as connection strings can contain
+// secrets that the name checks don't catch. Values of variables whose names look like secrets are still redacted
+func LogConfiguration(ctx context.Context) {
+	allowlist := []string{}
+	if v := os.Getenv("LOG_CONFIG_ALLOWLIST"); v != "" {
+		allowlist = strings.Split(v, ",")
+	}
+
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !matchesAllowlist(name, allowlist) {
+			continue
+		}
+		if isSecretName(name) {
+			value = redacted
+		}
+		env[name] = value
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	envArgs := make([]interface{}, 0, len(keys)*2)
+	for _, k := range keys {
+		envArgs = append(envArgs, k, env[k])
+	}
+
+	GetLogger(ctx).Info("sandbox configuration",
+		"function", map[string]string{
+			"name":       os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+			"version":    os.Getenv("AWS_LAMBDA_FUNCTION_VERSION"),
+			"memoryMb":   os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE"),
+			"region":     os.Getenv("AWS_REGION"),
+			"runtimeEnv": os.Getenv("AWS_EXECUTION_ENV"),
+		},
+		"runtime", map[string]interface{}{
+			"goVersion":  runtime.Version(),
+			"numCpu":     runtime.NumCPU(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+		},
+		slog.Group("env", envArgs...),
+	)
+}
+
+func matchesAllowlist(name string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if prefix, isPrefix := strings.CutSuffix(pattern, "*"); isPrefix {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecretName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, pattern := range secretPatterns {
+		if strings.Contains(upper, pattern) {
+			return true
+		}
+	}
+	return false
+}
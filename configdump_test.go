@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogConfiguration(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		allowlist   string
+		checkResult func(t *testing.T, env map[string]interface{})
+	}{
+		{
+			name: "No variables logged without an allowlist",
+			checkResult: func(t *testing.T, env map[string]interface{}) {
+				assert.Empty(t, env)
+			},
+		},
+		{
+			name:      "Secrets redacted",
+			allowlist: "APP_*",
+			checkResult: func(t *testing.T, env map[string]interface{}) {
+				assert.Equal(t, "orders", env["APP_TABLE_NAME"])
+				assert.Equal(t, redacted, env["APP_DB_PASSWORD"])
+			},
+		},
+		{
+			name:      "Only allowlisted variables logged",
+			allowlist: "APP_TABLE*",
+			checkResult: func(t *testing.T, env map[string]interface{}) {
+				assert.Equal(t, map[string]interface{}{"APP_TABLE_NAME": "orders"}, env)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("APP_TABLE_NAME", "orders")
+			t.Setenv("APP_DB_PASSWORD", "hunter2")
+			t.Setenv("LOG_CONFIG_ALLOWLIST", tc.allowlist)
+
+			buf := &bytes.Buffer{}
+			ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+			LogConfiguration(ctx)
+
+			entry := map[string]interface{}{}
+			assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+			env, _ := entry["env"].(map[string]interface{})
+			tc.checkResult(t, env)
+		})
+	}
+}
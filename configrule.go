@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	cstypes "github.com/aws/aws-sdk-go-v2/service/configservice/types"
+)
+
+const (
+	// maxEvaluationsPerPut is the most evaluations accepted by a PutEvaluations call
+	maxEvaluationsPerPut = 100
+	// maxEvaluationAnnotation is the longest annotation accepted by PutEvaluations
+	maxEvaluationAnnotation = 256
+	// configTestModeToken is the result token used when a rule is invoked from a test, which PutEvaluations must be
+	// called with in test mode
+	configTestModeToken = "TESTMODE"
+)
+
+// ConfigEvaluationsAPI is the subset of the AWS Config client used to report evaluations
+type ConfigEvaluationsAPI interface {
+	PutEvaluations(ctx context.Context, params *configservice.PutEvaluationsInput, optFns ...func(*configservice.Options)) (*configservice.PutEvaluationsOutput, error)
+}
+
+// ConfigurationItem is the configuration item of a change-triggered Config rule invocation
+type ConfigurationItem struct {
+	ResourceType                 string            `json:"resourceType"`
+	ResourceID                   string            `json:"resourceId"`
+	ResourceName                 string            `json:"resourceName"`
+	ARN                          string            `json:"ARN"`
+	AWSRegion                    string            `json:"awsRegion"`
+	ConfigurationItemStatus      string            `json:"configurationItemStatus"`
+	ConfigurationItemCaptureTime time.Time         `json:"configurationItemCaptureTime"`
+	Configuration                json.RawMessage   `json:"configuration"`
+	Tags                         map[string]string `json:"tags"`
+}
+
+// ConfigInvokingEvent is the parsed invoking event of a Config rule invocation. ConfigurationItem is nil for scheduled
+// (periodic) rules
+type ConfigInvokingEvent struct {
+	MessageType              string             `json:"messageType"`
+	NotificationCreationTime time.Time          `json:"notificationCreationTime"`
+	ConfigurationItem        *ConfigurationItem `json:"configurationItem"`
+}
+
+// ConfigRuleEvent is a Config rule invocation with its invoking event parsed and its rule parameters unmarshalled into P
+type ConfigRuleEvent[P interface{}] struct {
+	AccountID      string
+	ConfigRuleName string
+	EventLeftScope bool
+	InvokingEvent  ConfigInvokingEvent
+	RuleParameters P
+}
+
+// ConfigEvaluation is the compliance of one resource. OrderingTimestamp defaults to the configuration item capture time
+// (or the notification time for scheduled rules)
+type ConfigEvaluation struct {
+	ResourceType      string
+	ResourceID        string
+	ComplianceType    cstypes.ComplianceType
+	Annotation        string
+	OrderingTimestamp time.Time
+}
+
+type ConfigRuleProcessor[P interface{}] func(ctx context.Context, event ConfigRuleEvent[P]) ([]ConfigEvaluation, error)
+
+type ConfigRuleHandler = Handler[events.ConfigEvent, struct{}]
+
+// GetConfigRuleHandler returns a lambda handler for AWS Config custom rules that parses the invoking event, unmarshals
+// the rule parameters into P, and reports the evaluations returned by evaluate with PutEvaluations (in batches of 100,
+// using the event's result token). Annotations are truncated to the 256 characters Config accepts
+func GetConfigRuleHandler[P interface{}](client ConfigEvaluationsAPI, evaluate ConfigRuleProcessor[P]) Handler[events.ConfigEvent, struct{}] {
+	return func(ctx context.Context, event events.ConfigEvent) (struct{}, error) {
+		ctx = ContextWithStages(ctx)
+
+		ruleEvent := ConfigRuleEvent[P]{
+			AccountID:      event.AccountID,
+			ConfigRuleName: event.ConfigRuleName,
+			EventLeftScope: event.EventLeftScope,
+		}
+		err := json.Unmarshal([]byte(event.InvokingEvent), &ruleEvent.InvokingEvent)
+		if err != nil {
+			return struct{}{}, StageErr(ctx, "parse invoking event", err)
+		}
+		AddStage(ctx, "parse invoking event")
+		if event.RuleParameters != "" {
+			err = json.Unmarshal([]byte(event.RuleParameters), &ruleEvent.RuleParameters)
+			if err != nil {
+				return struct{}{}, StageErr(ctx, "unmarshal rule parameters", err)
+			}
+		}
+		AddStage(ctx, "unmarshal rule parameters")
+
+		evaluations, err := evaluate(ctx, ruleEvent)
+		if err != nil {
+			return struct{}{}, err
+		}
+		AddStage(ctx, "evaluate")
+
+		orderingTimestamp := ruleEvent.InvokingEvent.NotificationCreationTime
+		if item := ruleEvent.InvokingEvent.ConfigurationItem; item != nil {
+			orderingTimestamp = item.ConfigurationItemCaptureTime
+		}
+		return struct{}{}, putConfigEvaluations(ctx, client, event.ResultToken, evaluations, orderingTimestamp)
+	}
+}
+
+func putConfigEvaluations(ctx context.Context, client ConfigEvaluationsAPI, resultToken string, evaluations []ConfigEvaluation, orderingTimestamp time.Time) error {
+	for start := 0; start < len(evaluations); start += maxEvaluationsPerPut {
+		end := min(start+maxEvaluationsPerPut, len(evaluations))
+		input := &configservice.PutEvaluationsInput{
+			ResultToken: aws.String(resultToken),
+			TestMode:    resultToken == configTestModeToken,
+		}
+		for _, e := range evaluations[start:end] {
+			evaluation := cstypes.Evaluation{
+				ComplianceResourceType: aws.String(e.ResourceType),
+				ComplianceResourceId:   aws.String(e.ResourceID),
+				ComplianceType:         e.ComplianceType,
+				OrderingTimestamp:      aws.Time(orderingTimestamp),
+			}
+			if !e.OrderingTimestamp.IsZero() {
+				evaluation.OrderingTimestamp = aws.Time(e.OrderingTimestamp)
+			}
+			if e.Annotation != "" {
+				evaluation.Annotation = aws.String(truncate(e.Annotation, maxEvaluationAnnotation))
+			}
+			input.Evaluations = append(input.Evaluations, evaluation)
+		}
+
+		output, err := client.PutEvaluations(ctx, input)
+		if err != nil {
+			return StageErr(ctx, "put evaluations", err)
+		}
+		if len(output.FailedEvaluations) > 0 {
+			return StageErr(ctx, "put evaluations", fmt.Errorf("%d evaluations failed", len(output.FailedEvaluations)))
+		}
+		AddStage(ctx, "put evaluations")
+	}
+	return nil
+}
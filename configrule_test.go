@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	cstypes "github.com/aws/aws-sdk-go-v2/service/configservice/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type bucketRuleParameters struct {
+	RequiredTag string `json:"requiredTag"`
+}
+
+func TestGetConfigRuleHandler(t *testing.T) {
+	captureTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	changeEvent := events.ConfigEvent{
+		ConfigRuleName: "bucket-tags",
+		ResultToken:    "token-1",
+		RuleParameters: `{"requiredTag": "owner"}`,
+		InvokingEvent:  `{"messageType": "ConfigurationItemChangeNotification", "configurationItem": {"resourceType": "AWS::S3::Bucket", "resourceId": "my-bucket", "configurationItemCaptureTime": "2024-05-01T12:00:00Z", "tags": {"env": "prod"}}}`,
+	}
+
+	testcases := []struct {
+		name        string
+		event       events.ConfigEvent
+		evaluate    ConfigRuleProcessor[bucketRuleParameters]
+		failed      []cstypes.Evaluation
+		expectErr   bool
+		checkResult func(t *testing.T, client *mockConfigClient)
+	}{
+		{
+			name:  "Evaluation reported",
+			event: changeEvent,
+			evaluate: func(ctx context.Context, event ConfigRuleEvent[bucketRuleParameters]) ([]ConfigEvaluation, error) {
+				item := event.InvokingEvent.ConfigurationItem
+				assert.Equal(t, "owner", event.RuleParameters.RequiredTag)
+				assert.Equal(t, map[string]string{"env": "prod"}, item.Tags)
+				return []ConfigEvaluation{{
+					ResourceType:   item.ResourceType,
+					ResourceID:     item.ResourceID,
+					ComplianceType: cstypes.ComplianceTypeNonCompliant,
+					Annotation:     strings.Repeat("x", 300),
+				}}, nil
+			},
+			checkResult: func(t *testing.T, client *mockConfigClient) {
+				assert.Len(t, client.puts, 1)
+				input := client.puts[0]
+				assert.Equal(t, "token-1", aws.ToString(input.ResultToken))
+				assert.False(t, input.TestMode)
+				assert.Equal(t, "my-bucket", aws.ToString(input.Evaluations[0].ComplianceResourceId))
+				assert.Equal(t, cstypes.ComplianceTypeNonCompliant, input.Evaluations[0].ComplianceType)
+				assert.Equal(t, captureTime, aws.ToTime(input.Evaluations[0].OrderingTimestamp))
+				assert.Len(t, aws.ToString(input.Evaluations[0].Annotation), maxEvaluationAnnotation)
+			},
+		},
+		{
+			name:  "Evaluations batched in test mode",
+			event: events.ConfigEvent{ResultToken: "TESTMODE", InvokingEvent: `{"messageType": "ScheduledNotification", "notificationCreationTime": "2024-05-01T12:00:00Z"}`},
+			evaluate: func(ctx context.Context, event ConfigRuleEvent[bucketRuleParameters]) ([]ConfigEvaluation, error) {
+				assert.Nil(t, event.InvokingEvent.ConfigurationItem)
+				evaluations := make([]ConfigEvaluation, 150)
+				for i := range evaluations {
+					evaluations[i] = ConfigEvaluation{ResourceType: "AWS::S3::Bucket", ResourceID: fmt.Sprintf("bucket-%d", i), ComplianceType: cstypes.ComplianceTypeCompliant}
+				}
+				return evaluations, nil
+			},
+			checkResult: func(t *testing.T, client *mockConfigClient) {
+				assert.Len(t, client.puts, 2)
+				assert.Len(t, client.puts[0].Evaluations, 100)
+				assert.Len(t, client.puts[1].Evaluations, 50)
+				assert.True(t, client.puts[1].TestMode)
+				assert.Equal(t, captureTime, aws.ToTime(client.puts[1].Evaluations[0].OrderingTimestamp))
+			},
+		},
+		{
+			name:  "Evaluation fails",
+			event: changeEvent,
+			evaluate: func(ctx context.Context, event ConfigRuleEvent[bucketRuleParameters]) ([]ConfigEvaluation, error) {
+				return nil, errors.New("something bad happened")
+			},
+			expectErr: true,
+			checkResult: func(t *testing.T, client *mockConfigClient) {
+				assert.Empty(t, client.puts)
+			},
+		},
+		{
+			name:  "Evaluations rejected",
+			event: changeEvent,
+			evaluate: func(ctx context.Context, event ConfigRuleEvent[bucketRuleParameters]) ([]ConfigEvaluation, error) {
+				return []ConfigEvaluation{{ResourceType: "AWS::S3::Bucket", ResourceID: "my-bucket", ComplianceType: cstypes.ComplianceTypeCompliant}}, nil
+			},
+			failed:    []cstypes.Evaluation{{ComplianceResourceId: aws.String("my-bucket")}},
+			expectErr: true,
+			checkResult: func(t *testing.T, client *mockConfigClient) {
+				assert.Len(t, client.puts, 1)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockConfigClient{failed: tc.failed}
+			handler := GetConfigRuleHandler(client, tc.evaluate)
+			_, err := handler(context.Background(), tc.event)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.checkResult(t, client)
+		})
+	}
+}
+
+type mockConfigClient struct {
+	puts   []*configservice.PutEvaluationsInput
+	failed []cstypes.Evaluation
+}
+
+func (m *mockConfigClient) PutEvaluations(ctx context.Context, params *configservice.PutEvaluationsInput, optFns ...func(*configservice.Options)) (*configservice.PutEvaluationsOutput, error) {
+	m.puts = append(m.puts, params)
+	return &configservice.PutEvaluationsOutput{FailedEvaluations: m.failed}, nil
+}
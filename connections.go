@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"os"
+	"sync/atomic"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+const connectionStatsKey = "connectionStats"
+
+// ConnectionStats counts the connections used by AWS SDK calls during an invocation. A high number of new connections
+// or TLS handshakes on warm invocations usually means a client is being constructed inside the handler rather than at
+// initialisation
+type ConnectionStats struct {
+	Reused        int64 `json:"reused"`
+	New           int64 `json:"new"`
+	TLSHandshakes int64 `json:"tlsHandshakes"`
+}
+
+// connectionDiagnosticsEnabled returns true if SDK_CONNECTION_DIAGNOSTICS is "true"
+func connectionDiagnosticsEnabled() bool {
+	return os.Getenv("SDK_CONNECTION_DIAGNOSTICS") == "true"
+}
+
+// ContextWithConnectionStats attaches a new ConnectionStats to the context
+func ContextWithConnectionStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, connectionStatsKey, &ConnectionStats{})
+}
+
+// GetConnectionStats returns the connection counts for the context, or nil if it has no ConnectionStats
+func GetConnectionStats(ctx context.Context) *ConnectionStats {
+	val := ctx.Value(connectionStatsKey)
+	if val == nil {
+		return nil
+	}
+	stats := val.(*ConnectionStats)
+	return &ConnectionStats{
+		Reused:        atomic.LoadInt64(&stats.Reused),
+		New:           atomic.LoadInt64(&stats.New),
+		TLSHandshakes: atomic.LoadInt64(&stats.TLSHandshakes),
+	}
+}
+
+// AddConnectionTrace is an AWS SDK API option that counts connection reuse for calls made with a context that has
+// ConnectionStats. Add it to aws.Config.APIOptions before creating clients
+func AddConnectionTrace(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("ConnectionTrace", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		val := ctx.Value(connectionStatsKey)
+		if val == nil {
+			return next.HandleFinalize(ctx, in)
+		}
+		stats := val.(*ConnectionStats)
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if info.Reused {
+					atomic.AddInt64(&stats.Reused, 1)
+				} else {
+					atomic.AddInt64(&stats.New, 1)
+				}
+			},
+			TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+				if err == nil {
+					atomic.AddInt64(&stats.TLSHandshakes, 1)
+				}
+			},
+		})
+		return next.HandleFinalize(ctx, in)
+	}), middleware.After)
+}
+
+// logConnectionStats logs the connection counts for the invocation, if there are any
+func logConnectionStats(ctx context.Context) {
+	stats := GetConnectionStats(ctx)
+	if stats == nil || stats.Reused+stats.New == 0 {
+		return
+	}
+	GetLogger(ctx).Info("sdk connection usage", "sdkConnections", stats, "stages", getStagesLogValue(ctx))
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddConnectionTrace(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := sqs.NewFromConfig(aws.Config{
+		Region:      "eu-west-2",
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient:  server.Client(),
+		APIOptions:  []func(*middleware.Stack) error{AddConnectionTrace},
+	}, func(o *sqs.Options) {
+		o.BaseEndpoint = aws.String(server.URL)
+	})
+
+	ctx := ContextWithConnectionStats(context.Background())
+	for i := 0; i < 3; i++ {
+		_, err := client.SendMessage(ctx, &sqs.SendMessageInput{QueueUrl: aws.String(server.URL), MessageBody: aws.String("hello")})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, &ConnectionStats{Reused: 2, New: 1, TLSHandshakes: 1}, GetConnectionStats(ctx))
+
+	//Calls without stats on the context aren't counted
+	_, err := client.SendMessage(context.Background(), &sqs.SendMessageInput{QueueUrl: aws.String(server.URL), MessageBody: aws.String("hello")})
+	assert.Nil(t, err)
+	assert.Equal(t, &ConnectionStats{Reused: 2, New: 1, TLSHandshakes: 1}, GetConnectionStats(ctx))
+	assert.Nil(t, GetConnectionStats(context.Background()))
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+const continuationKey = "continuation"
+
+// ErrContinuationLimitExceeded is returned when a function has re-invoked itself more than the allowed number of times
+var ErrContinuationLimitExceeded = errors.New("continuation limit exceeded")
+
+// LambdaInvokeAPI is the subset of the Lambda client used to invoke functions
+type LambdaInvokeAPI interface {
+	Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
+}
+
+// ContinuationEvent is the input event for a handler wrapped with WithContinuation. It unmarshals either a plain event
+// (the first invocation) or the envelope sent by Continue
+type ContinuationEvent[T interface{}] struct {
+	Payload T   `json:"payload"`
+	Hop     int `json:"continuationHop"`
+}
+
+func (c *ContinuationEvent[T]) UnmarshalJSON(b []byte) error {
+	envelope := struct {
+		Payload json.RawMessage `json:"payload"`
+		Hop     *int            `json:"continuationHop"`
+	}{}
+	if err := json.Unmarshal(b, &envelope); err == nil && envelope.Hop != nil {
+		c.Hop = *envelope.Hop
+		return json.Unmarshal(envelope.Payload, &c.Payload)
+	}
+	c.Hop = 0
+	return json.Unmarshal(b, &c.Payload)
+}
+
+type continuation struct {
+	client  LambdaInvokeAPI
+	hop     int
+	maxHops int
+}
+
+// WithContinuation allows the handler to call Continue to re-invoke the current function when its work can't be
+// finished within a single invocation. The handler fails with ErrContinuationLimitExceeded once it has been
+// re-invoked more than maxHops times, so that a job can't loop forever
+func WithContinuation[T interface{}, U interface{}](client LambdaInvokeAPI, maxHops int, handlerFunc Handler[T, U]) Handler[ContinuationEvent[T], U] {
+	return func(ctx context.Context, event ContinuationEvent[T]) (U, error) {
+		if event.Hop > maxHops {
+			var zero U
+			return zero, fmt.Errorf("%w: hop %d exceeds maximum of %d", ErrContinuationLimitExceeded, event.Hop, maxHops)
+		}
+		if event.Hop > 0 {
+			GetLogger(ctx).Info("continuing work from previous invocation", "continuationHop", event.Hop)
+		}
+
+		newContext := context.WithValue(ctx, continuationKey, &continuation{client: client, hop: event.Hop, maxHops: maxHops})
+		return handlerFunc(newContext, event.Payload)
+	}
+}
+
+// Continue asynchronously invokes the current function (from AWS_LAMBDA_FUNCTION_NAME) with payload. The context
+// must come from a handler wrapped with WithContinuation
+func Continue[T interface{}](ctx context.Context, payload T) error {
+	val := ctx.Value(continuationKey)
+	if val == nil {
+		return errors.New("context does not support continuation - wrap the handler with WithContinuation")
+	}
+	c := val.(*continuation)
+
+	b, err := json.Marshal(ContinuationEvent[T]{Payload: payload, Hop: c.hop + 1})
+	if err != nil {
+		return err
+	}
+
+	functionName := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	GetLogger(ctx).Info("re-invoking function to continue work", "functionName", functionName, "continuationHop", c.hop+1)
+	_, err = c.client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   aws.String(functionName),
+		InvocationType: types.InvocationTypeEvent,
+		Payload:        b,
+	})
+	return err
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContinuationEvent_UnmarshalJSON(t *testing.T) {
+
+	testcases := []struct {
+		name     string
+		input    string
+		expected ContinuationEvent[inputEvent]
+	}{
+		{
+			name:     "Plain event",
+			input:    `{"Foo":1}`,
+			expected: ContinuationEvent[inputEvent]{Payload: inputEvent{Foo: 1}},
+		},
+		{
+			name:     "Continuation envelope",
+			input:    `{"payload":{"Foo":2},"continuationHop":3}`,
+			expected: ContinuationEvent[inputEvent]{Payload: inputEvent{Foo: 2}, Hop: 3},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := ContinuationEvent[inputEvent]{}
+			err := json.Unmarshal([]byte(tc.input), &event)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, event)
+		})
+	}
+}
+
+func TestWithContinuation(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "my-function")
+
+	h := func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		return outputEvent{}, Continue(ctx, inputEvent{Foo: event.Foo + 1})
+	}
+
+	testcases := []struct {
+		name        string
+		event       ContinuationEvent[inputEvent]
+		checkResult func(t *testing.T, client *mockLambdaClient, err error)
+	}{
+		{
+			name:  "Function re-invoked with next hop",
+			event: ContinuationEvent[inputEvent]{Payload: inputEvent{Foo: 1}, Hop: 1},
+			checkResult: func(t *testing.T, client *mockLambdaClient, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "my-function", *client.input.FunctionName)
+				assert.Equal(t, types.InvocationTypeEvent, client.input.InvocationType)
+				assert.JSONEq(t, `{"payload":{"Foo":2},"continuationHop":2}`, string(client.input.Payload))
+			},
+		},
+		{
+			name:  "Hop limit exceeded",
+			event: ContinuationEvent[inputEvent]{Payload: inputEvent{Foo: 1}, Hop: 4},
+			checkResult: func(t *testing.T, client *mockLambdaClient, err error) {
+				assert.ErrorIs(t, err, ErrContinuationLimitExceeded)
+				assert.Nil(t, client.input)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockLambdaClient{}
+			_, err := WithContinuation(client, 3, h)(context.Background(), tc.event)
+			tc.checkResult(t, client, err)
+		})
+	}
+}
+
+type mockLambdaClient struct {
+	input *lambda.InvokeInput
+}
+
+func (m *mockLambdaClient) Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	m.input = params
+	return &lambda.InvokeOutput{StatusCode: 202}, nil
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// WriteContracts writes JSON Schema files describing the handler's input (input.schema.json) and output
+// (output.schema.json) types to dir, so that producers and consumers can check compatibility in CI. BuildAndStart calls
+// it instead of starting the lambda if WRITE_CONTRACTS_DIR is set
+func WriteContracts[T interface{}, U interface{}](dir string) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	contracts := map[string]reflect.Type{
+		"input.schema.json":  reflect.TypeFor[T](),
+		"output.schema.json": reflect.TypeFor[U](),
+	}
+	for name, t := range contracts {
+		schema := GetJSONSchema(t)
+		schema["$schema"] = jsonSchemaDraft
+		b, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(filepath.Join(dir, name), append(b, '\n'), 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetJSONSchema returns a JSON Schema for the JSON encoding of t, following the same json struct tag rules as
+// encoding/json. Fields without omitempty are required. Pointers, slices and maps may be null, as that is how
+// encoding/json encodes nil values. Interfaces and recursive types are left unconstrained
+func GetJSONSchema(t reflect.Type) map[string]interface{} {
+	return getJSONSchema(t, map[reflect.Type]bool{})
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+	marshalerType  = reflect.TypeFor[json.Marshaler]()
+)
+
+func getJSONSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		return nullable(getJSONSchema(t.Elem(), visiting))
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType, t.Implements(marshalerType), reflect.PointerTo(t).Implements(marshalerType):
+		//Custom encodings can't be described by reflection
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		var schema map[string]interface{}
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			schema = map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		} else {
+			schema = map[string]interface{}{"type": "array", "items": getJSONSchema(t.Elem(), visiting)}
+		}
+		if t.Kind() == reflect.Slice {
+			return nullable(schema)
+		}
+		return schema
+	case reflect.Map:
+		return nullable(map[string]interface{}{"type": "object", "additionalProperties": getJSONSchema(t.Elem(), visiting)})
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]interface{}{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		addStructFields(t, visiting, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func addStructFields(t reflect.Type, visiting map[reflect.Type]bool, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		options := strings.Split(opts, ",")
+
+		//Untagged embedded structs are flattened into the parent, as encoding/json does
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			addStructFields(fieldType, visiting, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema := getJSONSchema(field.Type, visiting)
+		if slices.Contains(options, "string") && isQuotable(field.Type) {
+			//The value is encoded as JSON inside a string
+			schema = map[string]interface{}{"type": "string"}
+			if field.Type.Kind() == reflect.Pointer {
+				schema = nullable(schema)
+			}
+		}
+		properties[name] = schema
+		if !slices.Contains(options, "omitempty") && !slices.Contains(options, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable allows the value described by schema to also be null. Unconstrained schemas already allow null
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []string{t, "null"}
+	}
+	return schema
+}
+
+// isQuotable returns true if the ",string" json tag option applies to fields of type t: strings, numbers and booleans,
+// or unnamed pointers to them
+func isQuotable(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type contractBase struct {
+	ID string `json:"id"`
+}
+
+type contractEvent struct {
+	contractBase
+	Count    int               `json:"count"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Created  time.Time         `json:"created"`
+	Data     []byte            `json:"data,omitempty"`
+	Parent   *contractEvent    `json:"parent,omitempty"`
+	Base     *contractBase     `json:"base"`
+	Names    []string          `json:"names"`
+	Total    int64             `json:"total,string"`
+	Limit    *int              `json:"limit,string"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func TestGetJSONSchema(t *testing.T) {
+	schema := GetJSONSchema(reflect.TypeFor[contractEvent]())
+
+	b, err := json.Marshal(schema)
+	assert.Nil(t, err)
+	expected := `{
+		"type": "object",
+		"required": ["id", "count", "created", "base", "names", "total", "limit"],
+		"properties": {
+			"id": {"type": "string"},
+			"count": {"type": "integer"},
+			"tags": {"type": ["array", "null"], "items": {"type": "string"}},
+			"labels": {"type": ["object", "null"], "additionalProperties": {"type": "string"}},
+			"created": {"type": "string", "format": "date-time"},
+			"data": {"type": ["string", "null"], "contentEncoding": "base64"},
+			"parent": {},
+			"base": {"type": ["object", "null"], "required": ["id"], "properties": {"id": {"type": "string"}}},
+			"names": {"type": ["array", "null"], "items": {"type": "string"}},
+			"total": {"type": "string"},
+			"limit": {"type": ["string", "null"]}
+		}
+	}`
+	assert.JSONEq(t, expected, string(b))
+
+	//Nil pointers, slices and maps are encoded as null, and ",string" fields as strings
+	b, err = json.Marshal(contractEvent{})
+	assert.Nil(t, err)
+	encoded := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(b, &encoded))
+	assert.Nil(t, encoded["base"])
+	assert.Nil(t, encoded["names"])
+	assert.Nil(t, encoded["limit"])
+	assert.Equal(t, "0", encoded["total"])
+}
+
+func TestWriteContracts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "contracts")
+	err := WriteContracts[inputEvent, outputEvent](dir)
+	assert.Nil(t, err)
+
+	for _, name := range []string{"input.schema.json", "output.schema.json"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		schema := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(b, &schema))
+		assert.Equal(t, jsonSchemaDraft, schema["$schema"])
+		assert.Equal(t, "object", schema["type"])
+	}
+}
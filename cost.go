@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default x86 on-demand prices in us-east-1. Set COST_PER_GB_SECOND and COST_PER_REQUEST for other regions and arm64
+const (
+	defaultCostPerGBSecond = 0.0000166667
+	defaultCostPerRequest  = 0.0000002
+)
+
+// CostEstimate is the estimated cost of an invocation (or of the time spent on one record of a batch)
+type CostEstimate struct {
+	DurationMs int64   `json:"durationMs"`
+	GBSeconds  float64 `json:"gbSeconds"`
+	USD        float64 `json:"usd"`
+}
+
+// costTimer measures the duration of an invocation or record when REPORT_ESTIMATED_COST is "true"
+type costTimer struct {
+	start   time.Time
+	enabled bool
+}
+
+func startCostTimer(ctx context.Context) costTimer {
+	if os.Getenv("REPORT_ESTIMATED_COST") != "true" {
+		return costTimer{}
+	}
+	return costTimer{start: GetClock(ctx).Now(), enabled: true}
+}
+
+// report logs the estimated cost with the stages, so spend can be attributed to code paths, and emits it as metrics.
+// The request charge is only included for whole invocations
+func (c costTimer) report(ctx context.Context, message string, includeRequest bool, args ...interface{}) {
+	if !c.enabled {
+		return
+	}
+	estimate := estimateCost(GetClock(ctx).Now().Sub(c.start), includeRequest)
+	args = append(args, "cost", estimate, "stages", getStagesLogValue(ctx))
+	GetLogger(ctx).Info(message, args...)
+	if includeRequest {
+		EmitMetric(ctx, "GBSeconds", estimate.GBSeconds, UnitNone, nil)
+		EmitMetric(ctx, "EstimatedCostUSD", estimate.USD, UnitNone, nil)
+	}
+}
+
+func estimateCost(duration time.Duration, includeRequest bool) CostEstimate {
+	memoryMB, err := strconv.Atoi(os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE"))
+	if err != nil {
+		memoryMB = 128
+	}
+	//Lambda bills duration rounded up to the nearest millisecond
+	durationMs := (duration + time.Millisecond - 1).Milliseconds()
+	gbSeconds := float64(memoryMB) / 1024 * float64(durationMs) / 1000
+
+	usd := gbSeconds * getEnvFloat("COST_PER_GB_SECOND", defaultCostPerGBSecond)
+	if includeRequest {
+		usd += getEnvFloat("COST_PER_REQUEST", defaultCostPerRequest)
+	}
+	return CostEstimate{DurationMs: durationMs, GBSeconds: gbSeconds, USD: usd}
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
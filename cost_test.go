@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateCost(t *testing.T) {
+	testcases := []struct {
+		name           string
+		memory         string
+		perGBSecond    string
+		duration       time.Duration
+		includeRequest bool
+		expected       CostEstimate
+	}{
+		{
+			name:           "Invocation at default prices",
+			memory:         "1024",
+			duration:       1500 * time.Millisecond,
+			includeRequest: true,
+			expected:       CostEstimate{DurationMs: 1500, GBSeconds: 1.5, USD: 1.5*defaultCostPerGBSecond + defaultCostPerRequest},
+		},
+		{
+			name:        "Record at configured price, rounded up to the millisecond",
+			memory:      "512",
+			perGBSecond: "0.00001",
+			duration:    1999500 * time.Microsecond,
+			expected:    CostEstimate{DurationMs: 2000, GBSeconds: 1, USD: 0.00001},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE", tc.memory)
+			t.Setenv("COST_PER_GB_SECOND", tc.perGBSecond)
+			estimate := estimateCost(tc.duration, tc.includeRequest)
+			assert.Equal(t, tc.expected.DurationMs, estimate.DurationMs)
+			assert.InDelta(t, tc.expected.GBSeconds, estimate.GBSeconds, 1e-12)
+			assert.InDelta(t, tc.expected.USD, estimate.USD, 1e-15)
+		})
+	}
+}
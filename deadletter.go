@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SQS allows at most 10 message attributes per message
+const maxSQSMessageAttributes = 10
+
+// maxFailureMessageLength keeps the error message attribute to a readable size
+const maxFailureMessageLength = 1024
+
+// Message attributes added to records sent to a dead-letter queue, in order of priority (if the record already has
+// attributes, lower priority ones are dropped to stay within the SQS limit)
+const (
+	FailureErrorCodeAttribute      = "FailureErrorCode"
+	FailureErrorMessageAttribute   = "FailureErrorMessage"
+	FailureErrorHashAttribute      = "FailureErrorHash"
+	FailureFirstTimestampAttribute = "FailureFirstTimestamp"
+	FailureHandlerVersionAttribute = "FailureHandlerVersion"
+)
+
+// WithNonRetryableDeadLetterQueue sends records that fail with a non-retryable error (see NonRetryable) to the queue at
+// queueURL with SendToDeadLetterQueue and treats them as handled, so that poison messages don't use up the source
+// queue's maxReceiveCount. Other failures are retried as normal. If the record can't be sent, it is reported as failed.
+// Without this option, records that fail with a non-retryable error are logged with their body and acknowledged
+func WithNonRetryableDeadLetterQueue(client SQSSendMessageAPI, queueURL string) SQSOption {
+	return func(o *sqsOptions) {
+		o.deadLetterClient = client
+		o.deadLetterQueueURL = queueURL
+	}
+}
+
+// SendToDeadLetterQueue sends the record to the dead-letter queue with its original attributes plus failure metadata
+// (see GetFailureAttributes), so that triage tooling can group and prioritise failures
+func SendToDeadLetterQueue(ctx context.Context, client SQSSendMessageAPI, queueURL string, record events.SQSMessage, err error) error {
+	attributes := toSQSMessageAttributes(record.MessageAttributes)
+	failureAttributes := GetFailureAttributes(ctx, record, err)
+	for _, name := range []string{FailureErrorCodeAttribute, FailureErrorMessageAttribute, FailureErrorHashAttribute, FailureFirstTimestampAttribute, FailureHandlerVersionAttribute} {
+		if _, ok := failureAttributes[name]; !ok {
+			continue
+		}
+		if len(attributes) >= maxSQSMessageAttributes {
+			GetLogger(ctx).Warn("dropping failure metadata to stay within the sqs attribute limit", "attribute", name)
+			continue
+		}
+		attributes[name] = failureAttributes[name]
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(queueURL),
+		MessageBody:       aws.String(record.Body),
+		MessageAttributes: attributes,
+	}
+	if groupID := record.Attributes["MessageGroupId"]; groupID != "" {
+		input.MessageGroupId = aws.String(groupID)
+		input.MessageDeduplicationId = aws.String(record.MessageId)
+	}
+	_, sendErr := client.SendMessage(ctx, input)
+	if sendErr != nil {
+		return StageErr(ctx, "send to dead-letter queue", sendErr)
+	}
+	AddStage(ctx, "send to dead-letter queue")
+	return nil
+}
+
+// GetFailureAttributes returns the failure metadata for a record:
+//   - FailureErrorCode: the HandlerError code, or "Unknown"
+//   - FailureErrorMessage: the error message (truncated)
+//   - FailureErrorHash: a hash of the error types and stages, which is the same for repeats of the same failure
+//   - FailureFirstTimestamp: when the record was first received (RFC3339)
+//   - FailureHandlerVersion: the function name and version
+//
+// SQS rejects String attributes with empty values, so attributes that would be empty (e.g. an empty error message, or
+// the handler version when running locally) are left out
+func GetFailureAttributes(ctx context.Context, record events.SQSMessage, err error) map[string]sqstypes.MessageAttributeValue {
+	code := GetErrorCode(err)
+	if code == "" {
+		code = "Unknown"
+	}
+	message := truncate(err.Error(), maxFailureMessageLength)
+
+	firstFailure := GetClock(ctx).Now()
+	if ms, parseErr := strconv.ParseInt(record.Attributes["ApproximateFirstReceiveTimestamp"], 10, 64); parseErr == nil {
+		firstFailure = time.UnixMilli(ms)
+	}
+
+	version := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if v := os.Getenv("AWS_LAMBDA_FUNCTION_VERSION"); version != "" && v != "" {
+		version += ":" + v
+	}
+
+	attributes := map[string]sqstypes.MessageAttributeValue{}
+	for name, value := range map[string]string{
+		FailureErrorCodeAttribute:      code,
+		FailureErrorMessageAttribute:   message,
+		FailureErrorHashAttribute:      getErrorHash(ctx, err),
+		FailureFirstTimestampAttribute: firstFailure.UTC().Format(time.RFC3339),
+		FailureHandlerVersionAttribute: version,
+	} {
+		if value != "" {
+			attributes[name] = sqstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+	}
+	return attributes
+}
+
+// getErrorHash identifies the class of a failure. Go errors don't carry stack traces, so the hash is of the types in the
+// error chain and the stages recorded before the failure, which ignores details such as IDs in the message
+func getErrorHash(ctx context.Context, err error) string {
+	parts := []string{GetErrorCode(err)}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		parts = append(parts, fmt.Sprintf("%T", e))
+	}
+	parts = append(parts, getStageDescriptions(ctx)...)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:8])
+}
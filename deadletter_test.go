@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendToDeadLetterQueue(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "my-function")
+	t.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "7")
+	firstReceive := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name        string
+		record      events.SQSMessage
+		err         error
+		checkResult func(t *testing.T, client *mockSQSClient)
+	}{
+		{
+			name: "Failure metadata attached",
+			record: events.SQSMessage{
+				MessageId:         "message-1",
+				Body:              "hello",
+				Attributes:        map[string]string{"ApproximateFirstReceiveTimestamp": strconv.FormatInt(firstReceive.UnixMilli(), 10)},
+				MessageAttributes: map[string]events.SQSMessageAttribute{"Foo": {DataType: "String", StringValue: aws.String("bar")}},
+			},
+			err: fmt.Errorf("load order: %w", NewHandlerError("OrderNotFound", errors.New("no order 123"))),
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				input := client.sent[0]
+				assert.Equal(t, "https://dlq", aws.ToString(input.QueueUrl))
+				assert.Equal(t, "hello", aws.ToString(input.MessageBody))
+				assert.Equal(t, "bar", aws.ToString(input.MessageAttributes["Foo"].StringValue))
+				assert.Equal(t, "OrderNotFound", aws.ToString(input.MessageAttributes[FailureErrorCodeAttribute].StringValue))
+				assert.Equal(t, "load order: no order 123", aws.ToString(input.MessageAttributes[FailureErrorMessageAttribute].StringValue))
+				assert.Len(t, aws.ToString(input.MessageAttributes[FailureErrorHashAttribute].StringValue), 16)
+				assert.Equal(t, "2024-05-01T12:00:00Z", aws.ToString(input.MessageAttributes[FailureFirstTimestampAttribute].StringValue))
+				assert.Equal(t, "my-function:7", aws.ToString(input.MessageAttributes[FailureHandlerVersionAttribute].StringValue))
+			},
+		},
+		{
+			name: "Low priority metadata dropped at attribute limit",
+			record: events.SQSMessage{
+				Body: "hello",
+				MessageAttributes: map[string]events.SQSMessageAttribute{
+					"A": {DataType: "String", StringValue: aws.String("1")},
+					"B": {DataType: "String", StringValue: aws.String("2")},
+					"C": {DataType: "String", StringValue: aws.String("3")},
+					"D": {DataType: "String", StringValue: aws.String("4")},
+					"E": {DataType: "String", StringValue: aws.String("5")},
+					"F": {DataType: "String", StringValue: aws.String("6")},
+					"G": {DataType: "String", StringValue: aws.String("7")},
+				},
+			},
+			err: errors.New("something bad happened"),
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				attributes := client.sent[0].MessageAttributes
+				assert.Len(t, attributes, 10)
+				assert.Equal(t, "Unknown", aws.ToString(attributes[FailureErrorCodeAttribute].StringValue))
+				assert.Contains(t, attributes, FailureErrorHashAttribute)
+				assert.NotContains(t, attributes, FailureFirstTimestampAttribute)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSQSClient{}
+			err := SendToDeadLetterQueue(context.Background(), client, "https://dlq", tc.record, tc.err)
+			assert.Nil(t, err)
+			tc.checkResult(t, client)
+		})
+	}
+}
+
+func TestGetFailureAttributesOmitsEmptyValues(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "")
+	t.Setenv("AWS_LAMBDA_FUNCTION_VERSION", "")
+
+	attributes := GetFailureAttributes(context.Background(), events.SQSMessage{}, errors.New(""))
+	assert.NotContains(t, attributes, FailureErrorMessageAttribute)
+	assert.NotContains(t, attributes, FailureHandlerVersionAttribute)
+	assert.Contains(t, attributes, FailureErrorCodeAttribute)
+	for name, attribute := range attributes {
+		assert.NotEmpty(t, aws.ToString(attribute.StringValue), name)
+	}
+}
+
+func TestWithNonRetryableDeadLetterQueue(t *testing.T) {
+	testcases := []struct {
+		name     string
+		sendErr  error
+		failures []events.SQSBatchItemFailure
+	}{
+		{
+			name:     "Non-retryable record handled",
+			failures: []events.SQSBatchItemFailure{{ItemIdentifier: "2"}},
+		},
+		{
+			name:     "Send failure",
+			sendErr:  errors.New("access denied"),
+			failures: []events.SQSBatchItemFailure{{ItemIdentifier: "1"}, {ItemIdentifier: "2"}},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSQSClient{sendErr: tc.sendErr}
+			h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+				if record.Body == "poison" {
+					return NonRetryable(errors.New("malformed message"))
+				}
+				return errors.New("downstream unavailable")
+			}, WithNonRetryableDeadLetterQueue(client, "https://dlq"))
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1", Body: "poison"}, {ReceiptHandle: "2", Body: "ok"}}})
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, tc.failures, result.BatchItemFailures)
+			assert.Len(t, client.sent, 1)
+			assert.Equal(t, "poison", aws.ToString(client.sent[0].MessageBody))
+		})
+	}
+}
+
+func TestGetErrorHash(t *testing.T) {
+	ctx := ContextWithStages(context.Background())
+	AddStage(ctx, "load order")
+
+	first := getErrorHash(ctx, fmt.Errorf("load order: %w", errors.New("no order 123")))
+	second := getErrorHash(ctx, fmt.Errorf("load order: %w", errors.New("no order 456")))
+	other := getErrorHash(ctx, errors.New("no order 123"))
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, other)
+}
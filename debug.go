@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DebugTokenExtractor returns the debug token carried by an event, or an empty string if there isn't one
+type DebugTokenExtractor[T interface{}] func(event T) string
+
+// WithDebugInvocations enables debug-level logging and logs the input for a single invocation when the event carries a
+// valid debug token, so that operators can get deep diagnostics for one replayed message without redeploying. A token
+// is valid if it is in the comma-separated DEBUG_TOKENS environment variable, or if it was made by NewDebugToken with
+// the key in DEBUG_SIGNING_KEY and hasn't expired. Invalid tokens are logged and otherwise ignored
+func WithDebugInvocations[T interface{}, U interface{}](getToken DebugTokenExtractor[T], handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		token := getToken(event)
+		if token == "" {
+			return handlerFunc(ctx, event)
+		}
+		if !isValidDebugToken(GetClock(ctx).Now(), token) {
+			GetLogger(ctx).Warn("ignoring invalid debug token")
+			return handlerFunc(ctx, event)
+		}
+
+		ctx = GetNewContextWithLogger(ctx, getDebugLogger(ctx))
+		AddStage(ctx, "debug enabled")
+		GetLogger(ctx).Debug("debug invocation", "input", maskLogValue(event))
+		return handlerFunc(ctx, event)
+	}
+}
+
+// NewDebugToken returns a debug token signed with key that is valid until expires
+func NewDebugToken(key string, expires time.Time) string {
+	expiry := strconv.FormatInt(expires.Unix(), 10)
+	return expiry + "." + signDebugExpiry(key, expiry)
+}
+
+func signDebugExpiry(key string, expiry string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func isValidDebugToken(now time.Time, token string) bool {
+	for _, allowed := range strings.Split(os.Getenv("DEBUG_TOKENS"), ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed != "" && subtle.ConstantTimeCompare([]byte(allowed), []byte(token)) == 1 {
+			return true
+		}
+	}
+
+	key := os.Getenv("DEBUG_SIGNING_KEY")
+	expiry, signature, found := strings.Cut(token, ".")
+	if key == "" || !found {
+		return false
+	}
+	expires, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || !now.Before(time.Unix(expires, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(signDebugExpiry(key, expiry)))
+}
+
+// getDebugLogger returns a copy of the ContextWithLogger logger that logs at debug level
+func getDebugLogger(ctx context.Context) *slog.Logger {
+	options := getLogHandlerOptions()
+	if options == nil {
+		options = &slog.HandlerOptions{}
+	}
+	options.Level = slog.LevelDebug
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, options)).With("debug", true)
+	if traceID := getTraceID(ctx); traceID != "" {
+		logger = logger.With("trace_id", traceID)
+	}
+	return logger
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type debugEvent struct {
+	DebugToken string
+}
+
+func TestWithDebugInvocations(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	t.Setenv("DEBUG_SIGNING_KEY", "secret")
+	t.Setenv("DEBUG_TOKENS", "static-token")
+
+	testcases := []struct {
+		name     string
+		token    string
+		expected bool
+	}{
+		{name: "No token", expected: false},
+		{name: "Allowlisted token", token: "static-token", expected: true},
+		{name: "Signed token", token: NewDebugToken("secret", now.Add(time.Hour)), expected: true},
+		{name: "Expired token", token: NewDebugToken("secret", now.Add(-time.Second)), expected: false},
+		{name: "Wrong key", token: NewDebugToken("other", now.Add(time.Hour)), expected: false},
+		{name: "Malformed token", token: "not-a-token", expected: false},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := ContextWithStages(ContextWithClock(context.Background(), NewFakeClock(now)))
+			h := WithDebugInvocations(func(event debugEvent) string {
+				return event.DebugToken
+			}, func(ctx context.Context, event debugEvent) (outputEvent, error) {
+				assert.Equal(t, tc.expected, GetLogger(ctx).Enabled(ctx, slog.LevelDebug))
+				return outputEvent{}, nil
+			})
+			_, err := h(ctx, debugEvent{DebugToken: tc.token})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, len(getStageDescriptions(ctx)) > 0)
+		})
+	}
+}
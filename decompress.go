@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrDecompressedSizeExceeded is returned when decompressed data grows beyond the configured limit
+var ErrDecompressedSizeExceeded = errors.New("decompressed size limit exceeded")
+
+// S3GetObjectAPI is the subset of the S3 client used to download objects
+type S3GetObjectAPI interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// ZipEntryProcessor is called for each file in a zip archive with a reader for the decompressed content
+type ZipEntryProcessor func(ctx context.Context, file *zip.File, r io.Reader) error
+
+// GzipReader returns a reader that decompresses r. Reads fail with ErrDecompressedSizeExceeded once more than maxBytes
+// have been produced, or with the context error once the context is done
+func GzipReader(ctx context.Context, r io.Reader, maxBytes int64) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(&contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return nil, err
+	}
+	return &readCloser{Reader: &limitedReader{r: gz, remaining: maxBytes}, closer: gz}, nil
+}
+
+// ForEachZipEntry calls processEntry for each file in the archive. maxBytes limits the total decompressed size across
+// all entries so that a zip-bomb cannot exhaust the lambda's memory or disk
+func ForEachZipEntry(ctx context.Context, r io.ReaderAt, size int64, maxBytes int64, processEntry ZipEntryProcessor) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	remaining := maxBytes
+	for _, file := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		//The declared size can be forged, so the limited reader below is still required
+		if file.UncompressedSize64 > uint64(remaining) {
+			return fmt.Errorf("zip entry %s: %w", file.Name, ErrDecompressedSizeExceeded)
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("zip entry %s: %w", file.Name, err)
+		}
+		lr := &limitedReader{r: &contextReader{ctx: ctx, r: rc}, remaining: remaining}
+		err = processEntry(ctx, file, lr)
+		_ = rc.Close()
+		if err != nil {
+			return fmt.Errorf("zip entry %s: %w", file.Name, err)
+		}
+		remaining = lr.remaining
+	}
+	return nil
+}
+
+// GetS3ObjectReader downloads an object from S3, transparently decompressing it if it is gzip encoded (either by
+// Content-Encoding or a .gz key suffix). maxBytes limits the size of the returned content
+func GetS3ObjectReader(ctx context.Context, client S3GetObjectAPI, bucket string, key string, maxBytes int64) (io.ReadCloser, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+
+	if aws.ToString(output.ContentEncoding) == "gzip" || strings.HasSuffix(key, ".gz") {
+		gz, err := GzipReader(ctx, output.Body, maxBytes)
+		if err != nil {
+			_ = output.Body.Close()
+			return nil, err
+		}
+		return &readCloser{Reader: gz, closer: multiCloser{gz, output.Body}}, nil
+	}
+
+	return &readCloser{Reader: &limitedReader{r: &contextReader{ctx: ctx, r: output.Body}, remaining: maxBytes}, closer: output.Body}, nil
+}
+
+// ForEachS3ZipEntry downloads a zip archive from S3 and calls processEntry for each file in it. The archive is buffered
+// in memory (zip requires random access), so maxBytes limits both the archive size and the total decompressed size
+func ForEachS3ZipEntry(ctx context.Context, client S3GetObjectAPI, bucket string, key string, maxBytes int64, processEntry ZipEntryProcessor) error {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	defer output.Body.Close()
+
+	b, err := io.ReadAll(&limitedReader{r: &contextReader{ctx: ctx, r: output.Body}, remaining: maxBytes})
+	if err != nil {
+		return err
+	}
+	return ForEachZipEntry(ctx, bytes.NewReader(b), int64(len(b)), maxBytes, processEntry)
+}
+
+// contextReader stops reading once the context is done (e.g. the lambda deadline has passed)
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// limitedReader behaves like io.LimitedReader but returns an error rather than io.EOF when the limit is exceeded
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		//Probe for more data so that content of exactly the limit is still allowed
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrDecompressedSizeExceeded
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *readCloser) Close() error {
+	return r.closer.Close()
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var errs []error
+	for _, c := range m {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
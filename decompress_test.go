@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzipReader(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		maxBytes    int64
+		checkResult func(t *testing.T, b []byte, err error)
+	}{
+		{
+			name:     "Content within limit",
+			maxBytes: 11,
+			checkResult: func(t *testing.T, b []byte, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "hello world", string(b))
+			},
+		},
+		{
+			name:     "Content exceeds limit",
+			maxBytes: 5,
+			checkResult: func(t *testing.T, b []byte, err error) {
+				assert.ErrorIs(t, err, ErrDecompressedSizeExceeded)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := GzipReader(context.Background(), bytes.NewReader(gzipBytes(t, "hello world")), tc.maxBytes)
+			assert.Nil(t, err)
+			b, err := io.ReadAll(r)
+			tc.checkResult(t, b, err)
+		})
+	}
+}
+
+func TestForEachZipEntry(t *testing.T) {
+
+	archive := zipBytes(t, map[string]string{"a.txt": "alpha", "b.txt": "bravo"})
+
+	testcases := []struct {
+		name        string
+		maxBytes    int64
+		checkResult func(t *testing.T, contents map[string]string, err error)
+	}{
+		{
+			name:     "All entries processed",
+			maxBytes: 1024,
+			checkResult: func(t *testing.T, contents map[string]string, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]string{"a.txt": "alpha", "b.txt": "bravo"}, contents)
+			},
+		},
+		{
+			name:     "Total size exceeds limit",
+			maxBytes: 7,
+			checkResult: func(t *testing.T, contents map[string]string, err error) {
+				assert.ErrorIs(t, err, ErrDecompressedSizeExceeded)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents := map[string]string{}
+			err := ForEachZipEntry(context.Background(), bytes.NewReader(archive), int64(len(archive)), tc.maxBytes, func(ctx context.Context, file *zip.File, r io.Reader) error {
+				b, err := io.ReadAll(r)
+				contents[file.Name] = string(b)
+				return err
+			})
+			tc.checkResult(t, contents, err)
+		})
+	}
+}
+
+func TestGetS3ObjectReader(t *testing.T) {
+
+	testcases := []struct {
+		name   string
+		key    string
+		output *s3.GetObjectOutput
+		err    error
+		check  func(t *testing.T, b []byte, err error)
+	}{
+		{
+			name:   "Plain object",
+			key:    "data.txt",
+			output: &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader([]byte("hello world")))},
+			check: func(t *testing.T, b []byte, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "hello world", string(b))
+			},
+		},
+		{
+			name:   "Gzip object",
+			key:    "data.txt.gz",
+			output: &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(gzipBytes(t, "hello world")))},
+			check: func(t *testing.T, b []byte, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "hello world", string(b))
+			},
+		},
+		{
+			name: "GetObject fails",
+			key:  "data.txt",
+			err:  errors.New("access denied"),
+			check: func(t *testing.T, b []byte, err error) {
+				assert.EqualError(t, err, "access denied")
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockS3Client{output: tc.output, err: tc.err}
+			r, err := GetS3ObjectReader(context.Background(), client, "bucket", tc.key, 1024)
+			if err != nil {
+				tc.check(t, nil, err)
+				return
+			}
+			defer r.Close()
+			b, err := io.ReadAll(r)
+			tc.check(t, b, err)
+		})
+	}
+}
+
+type mockS3Client struct {
+	output *s3.GetObjectOutput
+	err    error
+}
+
+func (m *mockS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return m.output, m.err
+}
+
+func gzipBytes(t *testing.T, s string) []byte {
+	buf := bytes.Buffer{}
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	buf := bytes.Buffer{}
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DocumentDBEvent is the event sent by a DocumentDB change stream event source mapping
+type DocumentDBEvent struct {
+	EventSource    string                  `json:"eventSource"`
+	EventSourceARN string                  `json:"eventSourceArn"`
+	Events         []DocumentDBEventRecord `json:"events"`
+}
+
+type DocumentDBEventRecord struct {
+	Event DocumentDBChangeEvent `json:"event"`
+}
+
+// DocumentDBChangeEvent is a change stream event. The document fields are left as extended JSON
+type DocumentDBChangeEvent struct {
+	ID            DocumentDBResumeToken `json:"_id"`
+	OperationType string                `json:"operationType"`
+	Namespace     DocumentDBNamespace   `json:"ns"`
+	DocumentKey   json.RawMessage       `json:"documentKey"`
+	FullDocument  json.RawMessage       `json:"fullDocument"`
+	ClusterTime   json.RawMessage       `json:"clusterTime"`
+}
+
+// DocumentDBResumeToken identifies a change stream event
+type DocumentDBResumeToken struct {
+	Data string `json:"_data"`
+}
+
+type DocumentDBNamespace struct {
+	DB         string `json:"db"`
+	Collection string `json:"coll"`
+}
+
+// DocumentDBChange is a change stream event with its full document unmarshalled into T. FullDocument is nil for delete
+// events (and for updates unless the change stream is configured to return the full document)
+type DocumentDBChange[T interface{}] struct {
+	ResumeToken   string
+	OperationType string
+	Database      string
+	Collection    string
+	DocumentKey   json.RawMessage
+	FullDocument  *T
+}
+
+type DocumentDBChangeProcessor[T interface{}] func(ctx context.Context, change DocumentDBChange[T]) error
+
+type DocumentDBHandler = Handler[DocumentDBEvent, struct{}]
+
+// GetDocumentDBHandler returns a lambda handler that will unmarshal the full document of each DocumentDB change event
+// into T (from relaxed extended JSON, so e.g. ObjectIds are objects with an "$oid" field) and process the events in
+// parallel using the provided processChange function. Events are not processed in order, even for the same document.
+// DocumentDB event source mappings don't support partial batch responses (ReportBatchItemFailures), so if any event
+// fails or times out the handler returns an error and the whole batch is retried; processChange should be idempotent
+func GetDocumentDBHandler[T interface{}](processChange DocumentDBChangeProcessor[T]) Handler[DocumentDBEvent, struct{}] {
+
+	source := batchSource[DocumentDBChangeEvent]{
+		name: "documentdb change",
+		logAttrs: func(event DocumentDBChangeEvent) []any {
+			return []any{"resumeToken", event.ID.Data, "operationType", event.OperationType}
+		},
+		process: func(ctx context.Context, event DocumentDBChangeEvent) error {
+			change := DocumentDBChange[T]{
+				ResumeToken:   event.ID.Data,
+				OperationType: event.OperationType,
+				Database:      event.Namespace.DB,
+				Collection:    event.Namespace.Collection,
+				DocumentKey:   event.DocumentKey,
+			}
+			if len(event.FullDocument) > 0 && string(event.FullDocument) != "null" {
+				change.FullDocument = new(T)
+				err := json.Unmarshal(event.FullDocument, change.FullDocument)
+				if err != nil {
+					return StageErr(ctx, "unmarshal full document", err)
+				}
+			}
+			AddStage(ctx, "unmarshal full document")
+			return processChange(ctx, change)
+		},
+	}
+
+	return func(ctx context.Context, event DocumentDBEvent) (struct{}, error) {
+		changes := make([]DocumentDBChangeEvent, len(event.Events))
+		for i, e := range event.Events {
+			changes[i] = e.Event
+		}
+		results, err := processBatch(ctx, changes, source)
+		if err != nil {
+			return struct{}{}, err
+		}
+		if failed := countFailed(results); failed > 0 {
+			return struct{}{}, fmt.Errorf("%d of %d documentdb change events failed", failed, len(results))
+		}
+		return struct{}{}, nil
+	}
+}
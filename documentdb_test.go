@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type documentDBItem struct {
+	ID struct {
+		OID string `json:"$oid"`
+	} `json:"_id"`
+	Name string `json:"name"`
+}
+
+func TestGetDocumentDBHandler(t *testing.T) {
+
+	payload := `{
+		"eventSource": "aws:docdb",
+		"events": [
+			{"event": {"_id": {"_data": "0163eeb6e7000000090100000009000041e1"}, "operationType": "insert", "ns": {"db": "shop", "coll": "items"},
+				"documentKey": {"_id": {"$oid": "63eeb6e7d418cd98afb1c1d7"}},
+				"fullDocument": {"_id": {"$oid": "63eeb6e7d418cd98afb1c1d7"}, "name": "widget"}}},
+			{"event": {"_id": {"_data": "0163eeb6e70000000a0100000009000041e1"}, "operationType": "delete", "ns": {"db": "shop", "coll": "items"},
+				"documentKey": {"_id": {"$oid": "63eeb6e7d418cd98afb1c1d7"}}}}
+		]
+	}`
+
+	testcases := []struct {
+		name          string
+		processChange DocumentDBChangeProcessor[documentDBItem]
+		expectErr     string
+	}{
+		{
+			name: "Full document unmarshalled",
+			processChange: func(ctx context.Context, change DocumentDBChange[documentDBItem]) error {
+				assert.Equal(t, "shop", change.Database)
+				assert.Equal(t, "items", change.Collection)
+				switch change.OperationType {
+				case "insert":
+					assert.Equal(t, "0163eeb6e7000000090100000009000041e1", change.ResumeToken)
+					assert.Equal(t, "63eeb6e7d418cd98afb1c1d7", change.FullDocument.ID.OID)
+					assert.Equal(t, "widget", change.FullDocument.Name)
+				case "delete":
+					assert.Nil(t, change.FullDocument)
+					assert.JSONEq(t, `{"_id": {"$oid": "63eeb6e7d418cd98afb1c1d7"}}`, string(change.DocumentKey))
+				}
+				return nil
+			},
+		},
+		{
+			name: "Change fails",
+			processChange: func(ctx context.Context, change DocumentDBChange[documentDBItem]) error {
+				if change.OperationType == "delete" {
+					return errors.New("something bad happened")
+				}
+				return nil
+			},
+			expectErr: "1 of 2 documentdb change events failed",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+
+			var event DocumentDBEvent
+			assert.NoError(t, json.Unmarshal([]byte(payload), &event))
+
+			handler := GetDocumentDBHandler(tc.processChange)
+			_, err := handler(ctx, event)
+			if tc.expectErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectErr)
+			}
+		})
+	}
+}
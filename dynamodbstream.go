@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBStreamRecord is a DynamoDB stream record with its images unmarshalled into T. NewImage is nil for REMOVE
+// events and OldImage is nil for INSERT events (or if the stream view type doesn't include them)
+type DynamoDBStreamRecord[T interface{}] struct {
+	EventName      string
+	SequenceNumber string
+	Keys           map[string]events.DynamoDBAttributeValue
+	NewImage       *T
+	OldImage       *T
+}
+
+type DynamoDBStreamRecordProcessor[T interface{}] func(ctx context.Context, record DynamoDBStreamRecord[T]) error
+
+type DynamoDBStreamHandler = Handler[events.DynamoDBEvent, events.DynamoDBEventResponse]
+
+// GetDynamoDBStreamHandler returns a lambda handler that will unmarshal the images of each DynamoDB stream record into T
+// and process the records in parallel using the provided processRecord function. Failed records are reported by
+// sequence number, so the event source mapping must have ReportBatchItemFailures enabled. Records are not processed in
+// order, even for the same key
+func GetDynamoDBStreamHandler[T interface{}](processRecord DynamoDBStreamRecordProcessor[T]) Handler[events.DynamoDBEvent, events.DynamoDBEventResponse] {
+
+	source := batchSource[events.DynamoDBEventRecord]{
+		name: "dynamodb stream record",
+		logAttrs: func(record events.DynamoDBEventRecord) []any {
+			return []any{"eventId", record.EventID, "sequenceNumber", record.Change.SequenceNumber}
+		},
+		process: func(ctx context.Context, record events.DynamoDBEventRecord) error {
+			streamRecord, err := toDynamoDBStreamRecord[T](record)
+			if err != nil {
+				return StageErr(ctx, "unmarshal images", err)
+			}
+			AddStage(ctx, "unmarshal images")
+			return processRecord(ctx, streamRecord)
+		},
+	}
+
+	return func(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+		results, err := processBatch(ctx, event.Records, source)
+		if err != nil {
+			return events.DynamoDBEventResponse{}, err
+		}
+
+		ids := make([]string, len(event.Records))
+		for i, record := range event.Records {
+			ids[i] = record.Change.SequenceNumber
+		}
+		failures := []events.DynamoDBBatchItemFailure{}
+		for _, id := range getBatchItemFailures(ctx, ids, results) {
+			failures = append(failures, events.DynamoDBBatchItemFailure{ItemIdentifier: id})
+		}
+		return events.DynamoDBEventResponse{BatchItemFailures: failures}, nil
+	}
+}
+
+func toDynamoDBStreamRecord[T interface{}](record events.DynamoDBEventRecord) (DynamoDBStreamRecord[T], error) {
+	streamRecord := DynamoDBStreamRecord[T]{
+		EventName:      record.EventName,
+		SequenceNumber: record.Change.SequenceNumber,
+		Keys:           record.Change.Keys,
+	}
+	var err error
+	streamRecord.NewImage, err = unmarshalStreamImage[T](record.Change.NewImage)
+	if err != nil {
+		return streamRecord, fmt.Errorf("new image: %w", err)
+	}
+	streamRecord.OldImage, err = unmarshalStreamImage[T](record.Change.OldImage)
+	if err != nil {
+		return streamRecord, fmt.Errorf("old image: %w", err)
+	}
+	return streamRecord, nil
+}
+
+func unmarshalStreamImage[T interface{}](image map[string]events.DynamoDBAttributeValue) (*T, error) {
+	if len(image) == 0 {
+		return nil, nil
+	}
+	item := make(map[string]ddbtypes.AttributeValue, len(image))
+	for k, v := range image {
+		item[k] = toDynamoDBAttributeValue(v)
+	}
+	value := new(T)
+	err := attributevalue.UnmarshalMap(item, value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// toDynamoDBAttributeValue converts a stream attribute value to the SDK type so that attributevalue can unmarshal it
+func toDynamoDBAttributeValue(v events.DynamoDBAttributeValue) ddbtypes.AttributeValue {
+	switch v.DataType() {
+	case events.DataTypeBinary:
+		return &ddbtypes.AttributeValueMemberB{Value: v.Binary()}
+	case events.DataTypeBinarySet:
+		return &ddbtypes.AttributeValueMemberBS{Value: v.BinarySet()}
+	case events.DataTypeBoolean:
+		return &ddbtypes.AttributeValueMemberBOOL{Value: v.Boolean()}
+	case events.DataTypeList:
+		list := make([]ddbtypes.AttributeValue, len(v.List()))
+		for i, item := range v.List() {
+			list[i] = toDynamoDBAttributeValue(item)
+		}
+		return &ddbtypes.AttributeValueMemberL{Value: list}
+	case events.DataTypeMap:
+		m := make(map[string]ddbtypes.AttributeValue, len(v.Map()))
+		for k, item := range v.Map() {
+			m[k] = toDynamoDBAttributeValue(item)
+		}
+		return &ddbtypes.AttributeValueMemberM{Value: m}
+	case events.DataTypeNumber:
+		return &ddbtypes.AttributeValueMemberN{Value: v.Number()}
+	case events.DataTypeNumberSet:
+		return &ddbtypes.AttributeValueMemberNS{Value: v.NumberSet()}
+	case events.DataTypeString:
+		return &ddbtypes.AttributeValueMemberS{Value: v.String()}
+	case events.DataTypeStringSet:
+		return &ddbtypes.AttributeValueMemberSS{Value: v.StringSet()}
+	default:
+		return &ddbtypes.AttributeValueMemberNULL{Value: true}
+	}
+}
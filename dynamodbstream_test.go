@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+type streamItem struct {
+	ID     string   `dynamodbav:"id"`
+	Count  int      `dynamodbav:"count"`
+	Active bool     `dynamodbav:"active"`
+	Tags   []string `dynamodbav:"tags"`
+}
+
+func TestGetDynamoDBStreamHandler(t *testing.T) {
+
+	insert := events.DynamoDBEventRecord{EventName: "INSERT", Change: events.DynamoDBStreamRecord{
+		SequenceNumber: "100",
+		NewImage: map[string]events.DynamoDBAttributeValue{
+			"id":     events.NewStringAttribute("item-1"),
+			"count":  events.NewNumberAttribute("3"),
+			"active": events.NewBooleanAttribute(true),
+			"tags":   events.NewStringSetAttribute([]string{"a", "b"}),
+		},
+	}}
+	remove := events.DynamoDBEventRecord{EventName: "REMOVE", Change: events.DynamoDBStreamRecord{
+		SequenceNumber: "200",
+		OldImage: map[string]events.DynamoDBAttributeValue{
+			"id": events.NewStringAttribute("item-2"),
+		},
+	}}
+
+	testcases := []struct {
+		name          string
+		processRecord DynamoDBStreamRecordProcessor[streamItem]
+		event         events.DynamoDBEvent
+		checkResult   func(t *testing.T, result events.DynamoDBEventResponse)
+	}{
+		{
+			name: "Images unmarshalled",
+			processRecord: func(ctx context.Context, record DynamoDBStreamRecord[streamItem]) error {
+				switch record.EventName {
+				case "INSERT":
+					assert.Equal(t, &streamItem{ID: "item-1", Count: 3, Active: true, Tags: []string{"a", "b"}}, record.NewImage)
+					assert.Nil(t, record.OldImage)
+				case "REMOVE":
+					assert.Nil(t, record.NewImage)
+					assert.Equal(t, "item-2", record.OldImage.ID)
+				}
+				return nil
+			},
+			event: events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{insert, remove}},
+			checkResult: func(t *testing.T, result events.DynamoDBEventResponse) {
+				assert.Equal(t, events.DynamoDBEventResponse{BatchItemFailures: []events.DynamoDBBatchItemFailure{}}, result)
+			},
+		},
+		{
+			name: "Failures reported by sequence number",
+			processRecord: func(ctx context.Context, record DynamoDBStreamRecord[streamItem]) error {
+				if record.EventName == "REMOVE" {
+					return errors.New("something bad happened")
+				}
+				return nil
+			},
+			event: events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{insert, remove}},
+			checkResult: func(t *testing.T, result events.DynamoDBEventResponse) {
+				expected := events.DynamoDBEventResponse{BatchItemFailures: []events.DynamoDBBatchItemFailure{
+					{ItemIdentifier: "200"},
+				}}
+				assert.Equal(t, expected, result)
+			},
+		},
+		{
+			name: "Image can't be unmarshalled",
+			processRecord: func(ctx context.Context, record DynamoDBStreamRecord[streamItem]) error {
+				return nil
+			},
+			event: events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{{Change: events.DynamoDBStreamRecord{
+				SequenceNumber: "300",
+				NewImage:       map[string]events.DynamoDBAttributeValue{"count": events.NewStringAttribute("not a number")},
+			}}}},
+			checkResult: func(t *testing.T, result events.DynamoDBEventResponse) {
+				assert.Equal(t, []events.DynamoDBBatchItemFailure{{ItemIdentifier: "300"}}, result.BatchItemFailures)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+
+			handler := GetDynamoDBStreamHandler(tc.processRecord)
+			result, err := handler(ctx, tc.event)
+			assert.Nil(t, err)
+			tc.checkResult(t, result)
+		})
+	}
+}
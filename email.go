@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+// SESAPI is the subset of the SES v2 client used by EmailSender
+type SESAPI interface {
+	SendEmail(ctx context.Context, params *sesv2.SendEmailInput, optFns ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error)
+}
+
+// Email is an outgoing email. If there are any attachments the email is sent as raw MIME
+type Email struct {
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	TextBody    string
+	HTMLBody    string
+	Attachments []EmailAttachment
+}
+
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// EmailSender sends emails via SES from a fixed address
+type EmailSender struct {
+	client SESAPI
+	from   string
+}
+
+func NewEmailSender(client SESAPI, from string) *EmailSender {
+	return &EmailSender{client: client, from: from}
+}
+
+// MustGetEmailSender returns an EmailSender that sends from the address in the EMAIL_FROM_ADDRESS environment variable
+func MustGetEmailSender(awsConfig aws.Config) *EmailSender {
+	return NewEmailSender(sesv2.NewFromConfig(awsConfig), MustGetEnv("EMAIL_FROM_ADDRESS"))
+}
+
+// Send sends the email and returns the SES message ID
+func (s *EmailSender) Send(ctx context.Context, email Email) (string, error) {
+	input := &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(s.from),
+		Destination:      &types.Destination{ToAddresses: email.To, CcAddresses: email.Cc, BccAddresses: email.Bcc},
+	}
+
+	if len(email.Attachments) > 0 {
+		raw, err := s.buildRawMessage(email)
+		if err != nil {
+			return "", err
+		}
+		input.Content = &types.EmailContent{Raw: &types.RawMessage{Data: raw}}
+	} else {
+		body := &types.Body{}
+		if email.TextBody != "" {
+			body.Text = &types.Content{Data: aws.String(email.TextBody), Charset: aws.String("UTF-8")}
+		}
+		if email.HTMLBody != "" {
+			body.Html = &types.Content{Data: aws.String(email.HTMLBody), Charset: aws.String("UTF-8")}
+		}
+		input.Content = &types.EmailContent{Simple: &types.Message{
+			Subject: &types.Content{Data: aws.String(email.Subject), Charset: aws.String("UTF-8")},
+			Body:    body,
+		}}
+	}
+
+	return s.send(ctx, input)
+}
+
+// SendTemplate sends an email using an SES template. templateData is marshalled to JSON
+func (s *EmailSender) SendTemplate(ctx context.Context, to []string, templateName string, templateData interface{}) (string, error) {
+	data, err := json.Marshal(templateData)
+	if err != nil {
+		return "", err
+	}
+
+	return s.send(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(s.from),
+		Destination:      &types.Destination{ToAddresses: to},
+		Content: &types.EmailContent{Template: &types.Template{
+			TemplateName: aws.String(templateName),
+			TemplateData: aws.String(string(data)),
+		}},
+	})
+}
+
+func (s *EmailSender) send(ctx context.Context, input *sesv2.SendEmailInput) (string, error) {
+	logger := GetLogger(ctx)
+	logger.Info("sending email", "to", input.Destination.ToAddresses)
+
+	output, err := s.client.SendEmail(ctx, input)
+	if err != nil {
+		EmitMetric(ctx, "EmailSendFailed", 1, UnitCount, nil)
+		return "", fmt.Errorf("failed to send email: %w", err)
+	}
+
+	EmitMetric(ctx, "EmailSent", 1, UnitCount, nil)
+	messageID := aws.ToString(output.MessageId)
+	logger.Info("sent email", "messageId", messageID)
+	return messageID, nil
+}
+
+// formatAddressHeader validates the addresses and joins them for a MIME header. Addresses containing CR or LF are
+// rejected, as they could otherwise add headers to the message
+func formatAddressHeader(addresses []string) (string, error) {
+	for _, address := range addresses {
+		if strings.ContainsAny(address, "\r\n") {
+			return "", fmt.Errorf("invalid email address %q: contains a line break", address)
+		}
+		if _, err := mail.ParseAddress(address); err != nil {
+			return "", fmt.Errorf("invalid email address %q: %w", address, err)
+		}
+	}
+	return strings.Join(addresses, ", "), nil
+}
+
+func (s *EmailSender) buildRawMessage(email Email) ([]byte, error) {
+	buf := bytes.Buffer{}
+	mixed := multipart.NewWriter(&buf)
+
+	from, err := formatAddressHeader([]string{s.from})
+	if err != nil {
+		return nil, err
+	}
+	to, err := formatAddressHeader(email.To)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := formatAddressHeader(email.Cc)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	if len(email.Cc) > 0 {
+		fmt.Fprintf(&buf, "Cc: %s\r\n", cc)
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", email.Subject))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mixed.Boundary())
+
+	//Text and HTML bodies are alternatives of each other, nested within the mixed part
+	alternativeBuf := bytes.Buffer{}
+	alternative := multipart.NewWriter(&alternativeBuf)
+	for _, body := range []struct{ contentType, content string }{
+		{"text/plain", email.TextBody},
+		{"text/html", email.HTMLBody},
+	} {
+		if body.content == "" {
+			continue
+		}
+		part, err := alternative.CreatePart(textproto.MIMEHeader{"Content-Type": {body.contentType + "; charset=UTF-8"}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write([]byte(body.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(alternativeBuf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range email.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		//RFC 2045 limits encoded lines to 76 characters
+		for len(encoded) > 76 {
+			if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+				return nil, err
+			}
+			encoded = encoded[76:]
+		}
+		if _, err := part.Write([]byte(encoded)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailSender_Send(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		email       Email
+		err         error
+		checkResult func(t *testing.T, input *sesv2.SendEmailInput, messageID string, err error)
+	}{
+		{
+			name:  "Simple email",
+			email: Email{To: []string{"to@example.com"}, Subject: "Hello", TextBody: "Hi there"},
+			checkResult: func(t *testing.T, input *sesv2.SendEmailInput, messageID string, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "message-id", messageID)
+				assert.Equal(t, "from@example.com", aws.ToString(input.FromEmailAddress))
+				assert.Equal(t, "Hi there", aws.ToString(input.Content.Simple.Body.Text.Data))
+				assert.Nil(t, input.Content.Simple.Body.Html)
+			},
+		},
+		{
+			name: "Email with attachment",
+			email: Email{
+				To:          []string{"to@example.com"},
+				Subject:     "Report",
+				HTMLBody:    "<p>Attached</p>",
+				Attachments: []EmailAttachment{{Filename: "report.csv", ContentType: "text/csv", Data: []byte("a,b\n1,2\n")}},
+			},
+			checkResult: func(t *testing.T, input *sesv2.SendEmailInput, messageID string, err error) {
+				assert.Nil(t, err)
+				raw := string(input.Content.Raw.Data)
+				assert.Contains(t, raw, "Subject: Report")
+				assert.Contains(t, raw, "<p>Attached</p>")
+				assert.Contains(t, raw, `filename=report.csv`)
+				assert.Contains(t, raw, "YSxiCjEsMgo=")
+			},
+		},
+		{
+			name: "Header injection rejected",
+			email: Email{
+				To:          []string{"to@example.com\r\nBcc: attacker@example.com"},
+				Subject:     "Report",
+				Attachments: []EmailAttachment{{Filename: "report.csv", Data: []byte("a,b\n")}},
+			},
+			checkResult: func(t *testing.T, input *sesv2.SendEmailInput, messageID string, err error) {
+				assert.ErrorContains(t, err, "contains a line break")
+				assert.Nil(t, input)
+			},
+		},
+		{
+			name: "Invalid cc address rejected",
+			email: Email{
+				To:          []string{"to@example.com"},
+				Cc:          []string{"not an address"},
+				Subject:     "Report",
+				Attachments: []EmailAttachment{{Filename: "report.csv", Data: []byte("a,b\n")}},
+			},
+			checkResult: func(t *testing.T, input *sesv2.SendEmailInput, messageID string, err error) {
+				assert.ErrorContains(t, err, `invalid email address "not an address"`)
+				assert.Nil(t, input)
+			},
+		},
+		{
+			name:  "SES returns error",
+			email: Email{To: []string{"to@example.com"}, Subject: "Hello", TextBody: "Hi there"},
+			err:   errors.New("throttled"),
+			checkResult: func(t *testing.T, input *sesv2.SendEmailInput, messageID string, err error) {
+				assert.EqualError(t, err, "failed to send email: throttled")
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSESClient{err: tc.err}
+			sender := NewEmailSender(client, "from@example.com")
+			messageID, err := sender.Send(context.Background(), tc.email)
+			tc.checkResult(t, client.input, messageID, err)
+		})
+	}
+}
+
+type mockSESClient struct {
+	input *sesv2.SendEmailInput
+	err   error
+}
+
+func (m *mockSESClient) SendEmail(ctx context.Context, params *sesv2.SendEmailInput, optFns ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error) {
+	m.input = params
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &sesv2.SendEmailOutput{MessageId: aws.String("message-id")}, nil
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// ErrorCodeDeadlineExceeded is the code given to errors caused by the invocation running out of time
+const ErrorCodeDeadlineExceeded = "DeadlineExceeded"
+
+const deadlineCountedKey = "deadlineCounted"
+
+const errorTypesKey = "errorTypes"
+
+// HandlerError is an error with a stable code identifying the class of failure (e.g. "ValidationError")
+type HandlerError struct {
+	Code string
+	Err  error
+}
+
+func NewHandlerError(code string, err error) *HandlerError {
+	return &HandlerError{Code: code, Err: err}
+}
+
+func (e *HandlerError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// GetErrorCode returns the code of the first HandlerError in err's chain, or an empty string if there isn't one
+func GetErrorCode(err error) string {
+	var handlerError *HandlerError
+	if errors.As(err, &handlerError) {
+		return handlerError.Code
+	}
+	return ""
+}
+
+// WithErrorTypes reports errors that wrap a HandlerError to Lambda with the error code as the errorType, rather than the
+// name of the Go error type. Step Functions Retry and Catch clauses can then match on the code. Inside WithLogger, the
+// error is converted by WithLogger once it has flagged a deadline error, so that running out of time is reported as
+// DeadlineExceeded and a coded error returned after the deadline keeps its code
+func WithErrorTypes[T interface{}, U interface{}](handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		if enabled, ok := ctx.Value(errorTypesKey).(*atomic.Bool); ok {
+			enabled.Store(true)
+			return handlerFunc(ctx, event)
+		}
+		response, err := handlerFunc(ctx, event)
+		return response, toErrorType(err)
+	}
+}
+
+// toErrorType converts an error that wraps a HandlerError into an error with the code as its errorType
+func toErrorType(err error) error {
+	if code := GetErrorCode(err); code != "" {
+		return messages.InvokeResponse_Error{Message: err.Error(), Type: code}
+	}
+	return err
+}
+
+// IsDeadlineExceeded returns true if err was caused by the context deadline expiring, rather than by a failure in the
+// handler's own logic. Only the error chain is checked: an error that doesn't wrap context.DeadlineExceeded (e.g. a
+// validation error) keeps its meaning even if it's returned after ctx has expired. ctx is used to add the cancellation
+// cause, as withCancelCause does
+func IsDeadlineExceeded(ctx context.Context, err error) bool {
+	return errors.Is(withCancelCause(ctx, err), context.DeadlineExceeded)
+}
+
+// flagDeadlineExceeded records a "deadline exceeded" stage and metric, and gives err the DeadlineExceeded code unless it
+// already has one (or has already been converted by WithErrorTypes)
+func flagDeadlineExceeded(ctx context.Context, err error) error {
+	AddStage(ctx, "deadline exceeded")
+	emitDeadlineExceeded(ctx)
+	var invokeError messages.InvokeResponse_Error
+	if GetErrorCode(err) != "" || errors.As(err, &invokeError) {
+		return err
+	}
+	return NewHandlerError(ErrorCodeDeadlineExceeded, err)
+}
+
+// emitDeadlineExceeded emits the DeadlineExceeded metric. A batch record is counted at most once, although it's
+// reported when processWithDeadline gives up on it and again if it then returns a deadline error
+func emitDeadlineExceeded(ctx context.Context) {
+	if counted, ok := ctx.Value(deadlineCountedKey).(*atomic.Bool); ok && counted.Swap(true) {
+		return
+	}
+	EmitMetric(ctx, "DeadlineExceeded", 1, UnitCount, nil)
+}
+
+// ErrDeadlineMarginReached is the cancellation cause of record contexts that ran out of time before the invocation
+// deadline, leaving deadlineMargin to return the batch response
+var ErrDeadlineMarginReached = errors.New("invocation deadline margin reached")
+
+// ErrPriorityLaneDeadline is the cancellation cause of SQS record contexts that ran out of the time given to their
+// priority lane (see WithPriorityLanes)
+var ErrPriorityLaneDeadline = errors.New("priority lane deadline reached")
+
+// ErrRecordTimeout is the cancellation cause of SQS record contexts that ran out of the time given to each record (see
+// WithRecordTimeout)
+var ErrRecordTimeout = errors.New("record timeout reached")
+
+// ErrBatchComplete is the cancellation cause of record contexts that were still running when the batch response was
+// returned
+var ErrBatchComplete = errors.New("batch response already returned")
+
+// withCancelCause adds the cancellation cause of ctx to err if err is a bare context error, so that "context canceled"
+// and "context deadline exceeded" messages say why the context was cancelled
+func withCancelCause(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	cause := context.Cause(ctx)
+	if cause == nil || errors.Is(err, cause) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, cause)
+}
+
+// truncate returns the longest prefix of s that is at most max bytes and doesn't split a UTF-8 character
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
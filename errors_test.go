@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithErrorTypes(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		err         error
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name: "HandlerError reported with code",
+			err:  fmt.Errorf("load order: %w", NewHandlerError("OrderNotFound", errors.New("no such order"))),
+			checkResult: func(t *testing.T, err error) {
+				assert.Equal(t, messages.InvokeResponse_Error{Message: "load order: no such order", Type: "OrderNotFound"}, err)
+			},
+		},
+		{
+			name: "Other errors unchanged",
+			err:  errors.New("something bad happened"),
+			checkResult: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "something bad happened")
+			},
+		},
+		{
+			name: "No error",
+			checkResult: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := WithErrorTypes(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				return outputEvent{}, tc.err
+			})
+			_, err := h(context.Background(), inputEvent{})
+			tc.checkResult(t, err)
+		})
+	}
+}
+
+func TestWithErrorTypesAfterDeadline(t *testing.T) {
+	testcases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "Coded error keeps its code", err: NewHandlerError("OrderNotFound", errors.New("no such order")), expected: "OrderNotFound"},
+		{name: "Deadline error without a code", err: fmt.Errorf("read: %w", context.DeadlineExceeded), expected: ErrorCodeDeadlineExceeded},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := WithLogger(WithErrorTypes(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				<-ctx.Done()
+				return outputEvent{}, tc.err
+			}))
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(10*time.Millisecond))
+			defer cancel()
+			_, err := h(ctx, inputEvent{})
+			var invokeError messages.InvokeResponse_Error
+			assert.ErrorAs(t, err, &invokeError)
+			assert.Equal(t, tc.expected, invokeError.Type)
+			assert.Equal(t, tc.err.Error(), invokeError.Message)
+		})
+	}
+}
+
+func TestIsDeadlineExceeded(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	testcases := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Wrapped deadline error",
+			ctx:      context.Background(),
+			err:      fmt.Errorf("get object: %w", context.DeadlineExceeded),
+			expected: true,
+		},
+		{
+			name:     "Deadline error after context expired",
+			ctx:      expired,
+			err:      expired.Err(),
+			expected: true,
+		},
+		{
+			name:     "Business error after context expired",
+			ctx:      expired,
+			err:      errors.New("foo must be odd"),
+			expected: false,
+		},
+		{
+			name:     "Business error",
+			ctx:      context.Background(),
+			err:      errors.New("something bad happened"),
+			expected: false,
+		},
+		{
+			name:     "No error",
+			ctx:      expired,
+			expected: false,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsDeadlineExceeded(tc.ctx, tc.err))
+		})
+	}
+}
+
+func TestWithCancelCause(t *testing.T) {
+	cancelled, cancel := context.WithCancelCause(context.Background())
+	cancel(ErrBatchComplete)
+
+	err := withCancelCause(cancelled, fmt.Errorf("get object: %w", context.Canceled))
+	assert.EqualError(t, err, "get object: context canceled: batch response already returned")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, ErrBatchComplete)
+
+	//Errors that aren't context errors are left alone
+	err = withCancelCause(cancelled, errors.New("something bad happened"))
+	assert.EqualError(t, err, "something bad happened")
+
+	//Contexts cancelled without a cause are left alone
+	plain, plainCancel := context.WithCancel(context.Background())
+	plainCancel()
+	err = withCancelCause(plain, context.Canceled)
+	assert.EqualError(t, err, "context canceled")
+}
+
+func TestTruncate(t *testing.T) {
+	testcases := []struct {
+		name     string
+		s        string
+		max      int
+		expected string
+	}{
+		{name: "Short string unchanged", s: "abc", max: 5, expected: "abc"},
+		{name: "ASCII cut at max", s: "abcdef", max: 4, expected: "abcd"},
+		{name: "Multi-byte character not split", s: "abé", max: 3, expected: "ab"},
+		{name: "Multi-byte character kept whole", s: "abéd", max: 4, expected: "abé"},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, truncate(tc.s, tc.max))
+		})
+	}
+}
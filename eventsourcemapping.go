@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// LambdaEventSourceMappingAPI is the subset of the Lambda client used by EventSourceMapping
+type LambdaEventSourceMappingAPI interface {
+	UpdateEventSourceMapping(ctx context.Context, params *lambda.UpdateEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.UpdateEventSourceMappingOutput, error)
+}
+
+// EventSourceMapping pauses and resumes consumption from an event source (e.g. an SQS queue) during incidents
+type EventSourceMapping struct {
+	client LambdaEventSourceMappingAPI
+	uuid   string
+}
+
+// NewEventSourceMapping returns an EventSourceMapping for the mapping with the given UUID
+func NewEventSourceMapping(client LambdaEventSourceMappingAPI, uuid string) *EventSourceMapping {
+	return &EventSourceMapping{client: client, uuid: uuid}
+}
+
+// Pause disables the mapping so that no more events are consumed
+func (m *EventSourceMapping) Pause(ctx context.Context) error {
+	return m.setEnabled(ctx, false)
+}
+
+// Resume re-enables the mapping
+func (m *EventSourceMapping) Resume(ctx context.Context) error {
+	return m.setEnabled(ctx, true)
+}
+
+func (m *EventSourceMapping) setEnabled(ctx context.Context, enabled bool) error {
+	output, err := m.client.UpdateEventSourceMapping(ctx, &lambda.UpdateEventSourceMappingInput{
+		UUID:    aws.String(m.uuid),
+		Enabled: aws.Bool(enabled),
+	})
+	if err != nil {
+		return StageErr(ctx, "update event source mapping", err)
+	}
+	AddStage(ctx, "update event source mapping")
+	GetLogger(ctx).Info("updated event source mapping", "uuid", m.uuid, "enabled", enabled, "state", aws.ToString(output.State))
+	return nil
+}
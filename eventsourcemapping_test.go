@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSourceMapping(t *testing.T) {
+
+	testcases := []struct {
+		name            string
+		update          func(m *EventSourceMapping, ctx context.Context) error
+		expectedEnabled bool
+	}{
+		{
+			name:            "Pause",
+			update:          (*EventSourceMapping).Pause,
+			expectedEnabled: false,
+		},
+		{
+			name:            "Resume",
+			update:          (*EventSourceMapping).Resume,
+			expectedEnabled: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockEventSourceMappingClient{}
+			err := tc.update(NewEventSourceMapping(client, "a1b2c3"), context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, "a1b2c3", aws.ToString(client.input.UUID))
+			assert.Equal(t, tc.expectedEnabled, aws.ToBool(client.input.Enabled))
+		})
+	}
+}
+
+type mockEventSourceMappingClient struct {
+	input *lambda.UpdateEventSourceMappingInput
+}
+
+func (m *mockEventSourceMappingClient) UpdateEventSourceMapping(ctx context.Context, params *lambda.UpdateEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.UpdateEventSourceMappingOutput, error) {
+	m.input = params
+	return &lambda.UpdateEventSourceMappingOutput{State: aws.String("Disabling")}, nil
+}
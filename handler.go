@@ -8,17 +8,49 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-lambda-go/cfn"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/xray"
 )
 
 const loggerKey = "logger"
+const deadlineMarginKey = "deadlineMargin"
+
+// deadlineMargin is the time reserved before the lambda deadline for handlers to report results
+const deadlineMargin = 500 * time.Millisecond
+
+// getDeadlineMargin returns the deadline margin set on the context (see WithTimeoutMargin), or deadlineMargin
+func getDeadlineMargin(ctx context.Context) time.Duration {
+	if margin, ok := ctx.Value(deadlineMarginKey).(time.Duration); ok {
+		return margin
+	}
+	return deadlineMargin
+}
+
+// withDeadlineMargin returns a context that is cancelled with ErrDeadlineMarginReached when only the deadline margin is
+// left before ctx's deadline, so that the handler has time to report the result
+func withDeadlineMargin(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadlineCause(ctx, deadline.Add(-getDeadlineMargin(ctx)), ErrDeadlineMarginReached)
+}
+
+// withReportTimeout returns a context for reporting a result after processing has ended, which isn't cancelled with
+// ctx but times out after the deadline margin
+func withReportTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), getDeadlineMargin(ctx))
+}
 
 func GetLogger(ctx context.Context) *slog.Logger {
 	val := ctx.Value(loggerKey)
@@ -38,12 +70,31 @@ type Handler[T interface{}, U interface{}] func(ctx context.Context, event T) (U
 func WithLogger[T interface{}, U interface{}](handlerFunc Handler[T, U]) Handler[T, U] {
 	return func(ctx context.Context, event T) (U, error) {
 		// Perform pre-handler tasks here
-		newContext := ContextWithLogger(ctx)
-
+		newContext := ensureRand(ContextWithLogger(ctx))
+		defer trackInvocation()()
+
+		usage := startResourceUsage(newContext)
+		cost := startCostTimer(newContext)
+		newContext, stopWatchdog := startWatchdog(newContext)
+		//Set by WithErrorTypes, which leaves converting the error to here
+		errorTypes := &atomic.Bool{}
+		newContext = context.WithValue(newContext, errorTypesKey, errorTypes)
 		response, err := handlerFunc(newContext, event)
+		stopWatchdog()
+		logConnectionStats(newContext)
+		usage.report(newContext)
+		cost.report(newContext, "invocation cost", true)
 		if err != nil {
 			logger := GetLogger(ctx)
-			logger.Error("lambda execution failed", "error", err.Error())
+			if IsDeadlineExceeded(newContext, err) {
+				err = flagDeadlineExceeded(newContext, err)
+				logger.Error("lambda execution deadline exceeded", "error", err.Error(), "randSeed", getRandSeed(newContext), "stages", getStagesLogValue(newContext))
+			} else {
+				logger.Error("lambda execution failed", "error", err.Error(), "randSeed", getRandSeed(newContext), "stages", getStagesLogValue(newContext))
+			}
+			if errorTypes.Load() {
+				err = toErrorType(err)
+			}
 		}
 
 		return response, err
@@ -51,16 +102,37 @@ func WithLogger[T interface{}, U interface{}](handlerFunc Handler[T, U]) Handler
 }
 
 func ContextWithLogger(ctx context.Context) context.Context {
-	traceId := os.Getenv("_X_AMZN_TRACE_ID")
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	if traceId != "" {
-		parts := strings.Split(traceId, ";")
-		if len(parts) > 0 {
-			logger = logger.With("trace_id", strings.Replace(parts[0], "Root=", "", 1))
-		}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, getLogHandlerOptions()))
+	if traceId := getTraceID(ctx); traceId != "" {
+		logger = logger.With("trace_id", traceId)
 	}
 	newContext := context.WithValue(ctx, loggerKey, logger)
-	return newContext
+	if connectionDiagnosticsEnabled() {
+		newContext = ContextWithConnectionStats(newContext)
+	}
+	return ContextWithStages(newContext)
+}
+
+// getTraceID returns the X-Ray trace root ID of the invocation, or an empty string if there isn't one
+func getTraceID(ctx context.Context) string {
+	traceId := os.Getenv("_X_AMZN_TRACE_ID")
+	//Prefer the per-invocation header on the context (this is also how the trace header is passed in local mode)
+	if header, ok := ctx.Value(xray.LambdaTraceHeaderKey).(string); ok && header != "" {
+		traceId = header
+	}
+	if traceId == "" {
+		return ""
+	}
+	parts := strings.Split(traceId, ";")
+	return strings.Replace(parts[0], "Root=", "", 1)
+}
+
+// getRequestID returns the lambda request ID, or a unique placeholder if the context has no lambda context
+func getRequestID(ctx context.Context) string {
+	if lc, found := lambdacontext.FromContext(ctx); found {
+		return lc.AwsRequestID
+	}
+	return fmt.Sprintf("unknown-%d", GetClock(ctx).Now().UnixNano())
 }
 
 func MustGetEnv(key string) string {
@@ -84,6 +156,14 @@ func MustGetEnvInt(key string) int {
 func BuildAndStart[T interface{}, U interface{}](getHandler func(awsConfig aws.Config) Handler[T, U]) {
 	ctx := context.Background()
 
+	if dir := os.Getenv("WRITE_CONTRACTS_DIR"); dir != "" {
+		err := WriteContracts[T, U](dir)
+		if err != nil {
+			log.Fatalf("unable to write contracts, %v", err)
+		}
+		return
+	}
+
 	cfg, err := config.LoadDefaultConfig(ctx, config.WithRetryer(func() aws.Retryer {
 		return retry.NewStandard(func(so *retry.StandardOptions) {
 			//Use a large number so that the SDK client shouldn't run out of retry attempts
@@ -96,13 +176,25 @@ func BuildAndStart[T interface{}, U interface{}](getHandler func(awsConfig aws.C
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
 
+	if os.Getenv("LOG_CONFIG_ON_START") == "true" {
+		LogConfiguration(ContextWithLogger(ctx))
+	}
+
 	//Instrument the AWS SDK - this needs to happen before any service clients (e.g. s3Client) are created
 	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+	if connectionDiagnosticsEnabled() {
+		cfg.APIOptions = append(cfg.APIOptions, AddConnectionTrace)
+	}
 
 	//Pass the AWS config to the get handler - service clients can be created in this method
 	handlerFn := getHandler(cfg)
 
-	lambda.Start(WithLogger(handlerFn))
+	if addr := os.Getenv("LOCAL_ADDR"); addr != "" {
+		log.Fatal(StartLocal(addr, handlerFn))
+	}
+	lambda.StartWithOptions(WithLogger(handlerFn), lambda.WithEnableSIGTERM(func() {
+		runShutdownHooks(ContextWithLogger(ctx))
+	}))
 }
 
 func BuildAndStartCustomResource(getHandler func(awsConfig aws.Config) cfn.CustomResourceFunction) {
@@ -114,8 +206,15 @@ func BuildAndStartCustomResource(getHandler func(awsConfig aws.Config) cfn.Custo
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
 
+	if os.Getenv("LOG_CONFIG_ON_START") == "true" {
+		LogConfiguration(ContextWithLogger(ctx))
+	}
+
 	//Instrument the AWS SDK - this needs to happen before any service clients (e.g. s3Client) are created
 	awsv2.AWSV2Instrumentor(&cfg.APIOptions)
+	if connectionDiagnosticsEnabled() {
+		cfg.APIOptions = append(cfg.APIOptions, AddConnectionTrace)
+	}
 
 	//Pass the AWS config to the get handler - service clients can be created in this method
 	handlerFn := getHandler(cfg)
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -36,6 +37,16 @@ func TestWithLogger(t *testing.T) {
 				assert.NotNil(t, err)
 			},
 		},
+		{
+			name: "Handler exceeds deadline",
+			handler: func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				return outputEvent{}, fmt.Errorf("load customer: %w", context.DeadlineExceeded)
+			},
+			checkResult: func(t *testing.T, output outputEvent, err error) {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+				assert.Equal(t, ErrorCodeDeadlineExceeded, GetErrorCode(err))
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
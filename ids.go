@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a new ULID: 26 characters that sort by creation time. The time comes from the context's clock and the
+// random part from crypto/rand, unless a test has set a seed with ContextWithRand (so that IDs are reproducible). The
+// ID is recorded as a stage
+func NewID(ctx context.Context) string {
+	b := make([]byte, 16)
+	randomBytes(ctx, b[6:])
+	ms := uint64(GetClock(ctx).Now().UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+
+	id := encodeULID(b)
+	AddStage(ctx, "new id "+id)
+	return id
+}
+
+// NewUUID returns a new random (version 4) UUID using crypto/rand, unless a test has set a seed with ContextWithRand.
+// The ID is recorded as a stage
+func NewUUID(ctx context.Context) string {
+	b := make([]byte, 16)
+	randomBytes(ctx, b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	id := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	AddStage(ctx, "new id "+id)
+	return id
+}
+
+// randomBytes fills b from crypto/rand, or from the generator set with ContextWithRand. The generator attached by
+// WithLogger isn't used, as its seed is logged when an invocation fails and IDs must not be predictable
+func randomBytes(ctx context.Context, b []byte) {
+	r, ok := getExplicitRand(ctx)
+	if !ok {
+		//crypto/rand.Read never returns an error
+		_, _ = cryptorand.Read(b)
+		return
+	}
+	for i := 0; i < len(b); i += 8 {
+		var chunk [8]byte
+		binary.BigEndian.PutUint64(chunk[:], r.Uint64())
+		copy(b[i:], chunk[:])
+	}
+}
+
+// IDAttribute returns id as a string message attribute, for stamping it on outgoing SQS or SNS messages
+func IDAttribute(id string) sqstypes.MessageAttributeValue {
+	return sqstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(id)}
+}
+
+// encodeULID encodes 128 bits as 26 Crockford base32 characters, most significant bits first
+func encodeULID(b []byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewID(t *testing.T) {
+	clock := NewFakeClock(time.UnixMilli(1_469_918_176_385))
+	ctx := ContextWithStages(ContextWithClock(ContextWithRand(context.Background(), 42), clock))
+
+	id := NewID(ctx)
+	assert.Len(t, id, 26)
+	//The timestamp part is the same as in the ULID spec example
+	assert.Equal(t, "01ARYZ6S41", id[:10])
+
+	clock.Advance(time.Millisecond)
+	next := NewID(ctx)
+	assert.Less(t, id, next)
+	assert.Equal(t, []string{"new id " + id, "new id " + next}, getStageDescriptions(ctx))
+
+	//Reproducible from the seed
+	replay := ContextWithClock(ContextWithRand(context.Background(), 42), NewFakeClock(time.UnixMilli(1_469_918_176_385)))
+	assert.Equal(t, id, NewID(replay))
+}
+
+func TestNewUUID(t *testing.T) {
+	id := NewUUID(context.Background())
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+	assert.Equal(t, id, aws.ToString(IDAttribute(id).StringValue))
+}
+
+func TestNewIDNotFromLoggedSeed(t *testing.T) {
+	//The generator attached by WithLogger has its seed logged, so it must not be used for IDs
+	ids := []string{}
+	for i := 0; i < 2; i++ {
+		ctx := contextWithRand(context.Background(), 42, false)
+		ids = append(ids, NewUUID(ctx))
+	}
+	assert.NotEqual(t, ids[0], ids[1])
+
+	seeded := []string{NewUUID(ContextWithRand(context.Background(), 42)), NewUUID(ContextWithRand(context.Background(), 42))}
+	assert.Equal(t, seeded[0], seeded[1])
+}
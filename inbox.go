@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBTransactWriteAPI is the subset of the DynamoDB client used by Inbox
+type DynamoDBTransactWriteAPI interface {
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// Inbox records which messages a handler has processed in a DynamoDB table with a string partition key named "id", in
+// the same transaction as the handler's own DynamoDB writes. A redelivered message then can't apply its writes twice,
+// giving exactly-once effects for consumers whose side effects are DynamoDB writes. Set up a TTL on the "expiresAt"
+// attribute to remove old records
+type Inbox struct {
+	client      DynamoDBTransactWriteAPI
+	table       string
+	handlerName string
+	// Retention is how long inbox records are kept. It must be longer than a message can be redelivered for
+	Retention time.Duration
+}
+
+// NewInbox returns an Inbox for the handler. If handlerName is empty the function name is used
+func NewInbox(client DynamoDBTransactWriteAPI, table string, handlerName string) *Inbox {
+	if handlerName == "" {
+		handlerName = os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	}
+	return &Inbox{client: client, table: table, handlerName: handlerName, Retention: 14 * 24 * time.Hour}
+}
+
+// Commit applies writes together with an inbox record for messageID. It returns false without applying the writes if
+// the message has already been processed by this handler. At most 99 writes can be committed with the inbox record
+func (i *Inbox) Commit(ctx context.Context, messageID string, writes ...ddbtypes.TransactWriteItem) (bool, error) {
+	now := GetClock(ctx).Now()
+	record := ddbtypes.TransactWriteItem{Put: &ddbtypes.Put{
+		TableName: aws.String(i.table),
+		Item: map[string]ddbtypes.AttributeValue{
+			"id":          &ddbtypes.AttributeValueMemberS{Value: i.handlerName + "#" + messageID},
+			"messageId":   &ddbtypes.AttributeValueMemberS{Value: messageID},
+			"handlerName": &ddbtypes.AttributeValueMemberS{Value: i.handlerName},
+			"processedAt": &ddbtypes.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+			"expiresAt":   &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(i.Retention).Unix(), 10)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}}
+
+	_, err := i.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]ddbtypes.TransactWriteItem{record}, writes...),
+	})
+	if err != nil {
+		var cancelled *ddbtypes.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 0 && aws.ToString(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			AddStage(ctx, "inbox duplicate")
+			GetLogger(ctx).Info("skipping message already in inbox", "messageId", messageID)
+			return false, nil
+		}
+		return false, StageErr(ctx, "inbox commit", err)
+	}
+	AddStage(ctx, "inbox commit")
+	return true, nil
+}
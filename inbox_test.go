@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInboxCommit(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	ctx := ContextWithStages(ContextWithClock(context.Background(), clock))
+	client := &mockDynamoDBTransactClient{seen: map[string]bool{}}
+	inbox := NewInbox(client, "inbox", "orders-consumer")
+
+	write := ddbtypes.TransactWriteItem{Update: &ddbtypes.Update{
+		TableName:        aws.String("orders"),
+		Key:              map[string]ddbtypes.AttributeValue{"id": &ddbtypes.AttributeValueMemberS{Value: "order-1"}},
+		UpdateExpression: aws.String("ADD quantity :one"),
+	}}
+
+	committed, err := inbox.Commit(ctx, "message-1", write)
+	assert.Nil(t, err)
+	assert.True(t, committed)
+	assert.Len(t, client.input.TransactItems, 2)
+	record := client.input.TransactItems[0].Put.Item
+	assert.Equal(t, "orders-consumer#message-1", record["id"].(*ddbtypes.AttributeValueMemberS).Value)
+	assert.Equal(t, "1715774400", record["expiresAt"].(*ddbtypes.AttributeValueMemberN).Value)
+
+	//Redelivered message
+	committed, err = inbox.Commit(ctx, "message-1", write)
+	assert.Nil(t, err)
+	assert.False(t, committed)
+
+	client.err = errors.New("something bad happened")
+	_, err = inbox.Commit(ctx, "message-2", write)
+	assert.EqualError(t, err, "inbox commit: something bad happened")
+}
+
+type mockDynamoDBTransactClient struct {
+	input *dynamodb.TransactWriteItemsInput
+	seen  map[string]bool
+	err   error
+}
+
+func (m *mockDynamoDBTransactClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	m.input = params
+	if m.err != nil {
+		return nil, m.err
+	}
+	id := params.TransactItems[0].Put.Item["id"].(*ddbtypes.AttributeValueMemberS).Value
+	if m.seen[id] {
+		return nil, &ddbtypes.TransactionCanceledException{CancellationReasons: []ddbtypes.CancellationReason{
+			{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")},
+		}}
+	}
+	m.seen[id] = true
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// InputTelemetry describes the shape of an invocation's input
+type InputTelemetry struct {
+	Bytes int `json:"bytes"`
+	// Records is the length of a top-level "Records" array (as in SQS, SNS, S3, Kinesis and DynamoDB stream events), or
+	// -1 if there isn't one
+	Records int      `json:"records"`
+	Keys    []string `json:"keys"`
+}
+
+// WithInputTelemetry adds the size of the input, its record count and its top-level JSON keys to every log line of the
+// invocation, and emits InputBytes and InputRecords metrics, so that changes in producer behaviour (larger payloads,
+// new fields) can be spotted from logs alone. The size is of the event re-marshalled to JSON, so fields that T doesn't
+// declare are not counted
+func WithInputTelemetry[T interface{}, U interface{}](handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		telemetry, err := getInputTelemetry(event)
+		if err != nil {
+			GetLogger(ctx).Warn("failed to measure input", "error", err.Error())
+			return handlerFunc(ctx, event)
+		}
+
+		ctx = GetNewContextWithLogger(ctx, GetLogger(ctx).With("input", telemetry))
+		EmitMetric(ctx, "InputBytes", float64(telemetry.Bytes), UnitBytes, nil)
+		if telemetry.Records >= 0 {
+			EmitMetric(ctx, "InputRecords", float64(telemetry.Records), UnitCount, nil)
+		}
+		return handlerFunc(ctx, event)
+	}
+}
+
+func getInputTelemetry(event interface{}) (InputTelemetry, error) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return InputTelemetry{}, err
+	}
+	telemetry := InputTelemetry{Bytes: len(b), Records: -1, Keys: []string{}}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) != nil {
+		//Not an object, so there are no keys
+		return telemetry, nil
+	}
+	for k := range fields {
+		telemetry.Keys = append(telemetry.Keys, k)
+	}
+	sort.Strings(telemetry.Keys)
+
+	var records []json.RawMessage
+	if raw, ok := fields["Records"]; ok && json.Unmarshal(raw, &records) == nil {
+		telemetry.Records = len(records)
+	}
+	return telemetry, nil
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetInputTelemetry(t *testing.T) {
+
+	testcases := []struct {
+		name     string
+		event    interface{}
+		expected InputTelemetry
+	}{
+		{
+			name:     "Batch event",
+			event:    events.SQSEvent{Records: []events.SQSMessage{{}, {}}},
+			expected: InputTelemetry{Records: 2, Keys: []string{"Records"}},
+		},
+		{
+			name:     "Object",
+			event:    map[string]interface{}{"b": 1, "a": "x"},
+			expected: InputTelemetry{Bytes: 15, Records: -1, Keys: []string{"a", "b"}},
+		},
+		{
+			name:     "Not an object",
+			event:    []int{1, 2, 3},
+			expected: InputTelemetry{Bytes: 7, Records: -1, Keys: []string{}},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			telemetry, err := getInputTelemetry(tc.event)
+			assert.Nil(t, err)
+			if tc.expected.Bytes == 0 {
+				tc.expected.Bytes = telemetry.Bytes
+			}
+			assert.Equal(t, tc.expected, telemetry)
+		})
+	}
+}
+
+func TestWithInputTelemetry(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+
+	h := WithInputTelemetry(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		GetLogger(ctx).Info("processing")
+		return outputEvent{}, nil
+	})
+	_, err := h(ctx, inputEvent{Foo: 1})
+	assert.Nil(t, err)
+
+	line := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, map[string]interface{}{"bytes": float64(9), "records": float64(-1), "keys": []interface{}{"Foo"}}, line["input"])
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// InvokeOptions controls how InvokeAll spreads out its calls
+type InvokeOptions struct {
+	// TransactionsPerSecond is the maximum rate of Invoke calls. Zero means no limit
+	TransactionsPerSecond float64
+	// Concurrency is the maximum number of calls in flight at once (default 10). For async invocations this also bounds
+	// the number of concurrent Invoke API calls, not the concurrency of the invoked function
+	Concurrency int
+	// Async invokes the function with the Event invocation type rather than waiting for its response
+	Async bool
+}
+
+// InvokeResult is the result of one invocation made by InvokeAll. Payload is the function's response for synchronous
+// invocations. Err is set if the call failed, the function returned an error, or there wasn't time to make the call
+type InvokeResult struct {
+	Payload    []byte
+	StatusCode int32
+	Err        error
+}
+
+// InvokeAll invokes the function once for each payload (marshalled to JSON), spacing the calls out to respect the
+// options, so that fan-out functions don't trip account concurrency limits. Calls that would start after the context
+// deadline (less the deadline margin) are not made and get ErrInsufficientTime. The results are in payload order, and
+// the error joins the errors of every failed invocation. A stage is recorded for each call with its status or error,
+// followed by a summary once every call has finished
+func InvokeAll[T interface{}](ctx context.Context, client LambdaInvokeAPI, functionName string, payloads []T, options InvokeOptions) ([]InvokeResult, error) {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	invocationType := lambdatypes.InvocationTypeRequestResponse
+	if options.Async {
+		invocationType = lambdatypes.InvocationTypeEvent
+	}
+
+	//Calls are only started before the deadline margin
+	startCtx := ctx
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+		var cancel context.CancelFunc
+		startCtx, cancel = context.WithDeadline(ctx, deadline.Add(-getDeadlineMargin(ctx)))
+		defer cancel()
+	}
+
+	limiter := newRateLimiter(options.TransactionsPerSecond)
+	semaphore := make(chan struct{}, concurrency)
+	results := make([]InvokeResult, len(payloads))
+	wg := sync.WaitGroup{}
+	for i, payload := range payloads {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			results[i].Err = fmt.Errorf("marshal payload: %w", err)
+			continue
+		}
+		if startCtx.Err() != nil || limiter.Wait(startCtx) != nil {
+			results[i].Err = ErrInsufficientTime
+			continue
+		}
+		select {
+		case semaphore <- struct{}{}:
+		case <-startCtx.Done():
+			results[i].Err = ErrInsufficientTime
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, b []byte) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			results[i] = invokeFunction(ctx, client, functionName, i, invocationType, b)
+		}(i, b)
+	}
+	wg.Wait()
+
+	errs := []error{}
+	failed := 0
+	for i, result := range results {
+		if result.Err != nil {
+			failed++
+			errs = append(errs, fmt.Errorf("invocation %d: %w", i, result.Err))
+		}
+	}
+	AddStage(ctx, fmt.Sprintf("invoke %s: %d of %d succeeded", functionName, len(results)-failed, len(results)))
+	GetLogger(ctx).Info("invoked function", "functionName", functionName, "invocations", len(results), "failed", failed, "async", options.Async)
+	return results, errors.Join(errs...)
+}
+
+// invokeFunction makes the invocation with index i, recording a stage with its status or error
+func invokeFunction(ctx context.Context, client LambdaInvokeAPI, functionName string, i int, invocationType lambdatypes.InvocationType, payload []byte) InvokeResult {
+	stage := fmt.Sprintf("invoke %s #%d", functionName, i)
+	output, err := client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   aws.String(functionName),
+		InvocationType: invocationType,
+		Payload:        payload,
+	})
+	if err != nil {
+		AddStage(ctx, fmt.Sprintf("%s: %s", stage, err.Error()))
+		return InvokeResult{Err: err}
+	}
+	result := InvokeResult{Payload: output.Payload, StatusCode: output.StatusCode}
+	if output.FunctionError != nil {
+		AddStage(ctx, fmt.Sprintf("%s: status %d, function error %s", stage, output.StatusCode, aws.ToString(output.FunctionError)))
+		result.Err = fmt.Errorf("function error %s: %s", aws.ToString(output.FunctionError), string(output.Payload))
+		return result
+	}
+	AddStage(ctx, fmt.Sprintf("%s: status %d", stage, output.StatusCode))
+	return result
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvokeAll(t *testing.T) {
+	client := &mockFanOutLambdaClient{}
+	ctx := ContextWithStages(context.Background())
+
+	start := time.Now()
+	results, err := InvokeAll(ctx, client, "worker", []inputEvent{{Foo: 1}, {Foo: 2}, {Foo: 3}}, InvokeOptions{TransactionsPerSecond: 20, Concurrency: 2})
+	assert.EqualError(t, err, "invocation 1: function error Unhandled: {\"errorMessage\":\"bad\"}")
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Len(t, results, 3)
+	assert.Equal(t, `{"Bar":3}`, string(results[2].Payload))
+	assert.Equal(t, lambdatypes.InvocationTypeRequestResponse, client.invocationType)
+	stages := getStageDescriptions(ctx)
+	assert.ElementsMatch(t, []string{
+		"invoke worker #0: status 200",
+		"invoke worker #1: status 200, function error Unhandled",
+		"invoke worker #2: status 200",
+		"invoke worker: 2 of 3 succeeded",
+	}, stages)
+	//The summary is recorded once every call has finished
+	assert.Equal(t, "invoke worker: 2 of 3 succeeded", stages[len(stages)-1])
+}
+
+func TestInvokeAllDeadline(t *testing.T) {
+	client := &mockFanOutLambdaClient{}
+	ctx, cancel := context.WithTimeout(context.Background(), 700*time.Millisecond)
+	defer cancel()
+
+	//Only the calls that can start in the 200ms before the deadline margin are made
+	results, err := InvokeAll(ctx, client, "worker", []inputEvent{{Foo: 1}, {Foo: 1}, {Foo: 1}, {Foo: 1}}, InvokeOptions{TransactionsPerSecond: 8, Async: true})
+	assert.NotNil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.ErrorIs(t, results[3].Err, ErrInsufficientTime)
+	assert.Equal(t, lambdatypes.InvocationTypeEvent, client.invocationType)
+}
+
+func TestInvokeAllDeadlineMargin(t *testing.T) {
+	client := &mockFanOutLambdaClient{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, deadlineMarginKey, time.Second)
+
+	results, err := InvokeAll(ctx, client, "worker", []inputEvent{{Foo: 1}}, InvokeOptions{})
+	assert.ErrorIs(t, err, ErrInsufficientTime)
+	assert.ErrorIs(t, results[0].Err, ErrInsufficientTime)
+}
+
+type mockFanOutLambdaClient struct {
+	mu             sync.Mutex
+	invocationType lambdatypes.InvocationType
+}
+
+func (m *mockFanOutLambdaClient) Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	m.mu.Lock()
+	m.invocationType = params.InvocationType
+	m.mu.Unlock()
+
+	event := inputEvent{}
+	_ = json.Unmarshal(params.Payload, &event)
+	if event.Foo == 2 {
+		return &lambda.InvokeOutput{StatusCode: 200, FunctionError: aws.String("Unhandled"), Payload: []byte(`{"errorMessage":"bad"}`)}, nil
+	}
+	b, _ := json.Marshal(outputEvent{Bar: event.Foo})
+	return &lambda.InvokeOutput{StatusCode: 200, Payload: b}, nil
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// KinesisRecord is a Kinesis record with its data unmarshalled into T
+type KinesisRecord[T interface{}] struct {
+	Payload                     T
+	EventID                     string
+	PartitionKey                string
+	SequenceNumber              string
+	ApproximateArrivalTimestamp time.Time
+}
+
+type KinesisRecordProcessor[T interface{}] func(ctx context.Context, record KinesisRecord[T]) error
+
+type KinesisHandler = Handler[events.KinesisEvent, events.KinesisEventResponse]
+
+// GetKinesisHandler returns a lambda handler that will unmarshal the data of each Kinesis record into T and process the
+// records in parallel using the provided processRecord function. The record data is base64 encoded in the event JSON,
+// but it has already been decoded into bytes by the time the event is passed to the handler. Failed and timed-out
+// records are reported by sequence number, so the event source mapping must have ReportBatchItemFailures enabled
+func GetKinesisHandler[T interface{}](processRecord KinesisRecordProcessor[T]) Handler[events.KinesisEvent, events.KinesisEventResponse] {
+
+	source := batchSource[events.KinesisEventRecord]{
+		name: "kinesis record",
+		logAttrs: func(record events.KinesisEventRecord) []any {
+			return []any{"eventId", record.EventID, "sequenceNumber", record.Kinesis.SequenceNumber}
+		},
+		process: func(ctx context.Context, record events.KinesisEventRecord) error {
+			kinesisRecord := KinesisRecord[T]{
+				EventID:                     record.EventID,
+				PartitionKey:                record.Kinesis.PartitionKey,
+				SequenceNumber:              record.Kinesis.SequenceNumber,
+				ApproximateArrivalTimestamp: record.Kinesis.ApproximateArrivalTimestamp.UTC(),
+			}
+			err := json.Unmarshal(record.Kinesis.Data, &kinesisRecord.Payload)
+			if err != nil {
+				return StageErr(ctx, "unmarshal data", err)
+			}
+			AddStage(ctx, "unmarshal data")
+			return processRecord(ctx, kinesisRecord)
+		},
+	}
+
+	return func(ctx context.Context, event events.KinesisEvent) (events.KinesisEventResponse, error) {
+		results, err := processBatch(ctx, event.Records, source)
+		if err != nil {
+			return events.KinesisEventResponse{}, err
+		}
+
+		ids := make([]string, len(event.Records))
+		for i, record := range event.Records {
+			ids[i] = record.Kinesis.SequenceNumber
+		}
+		failures := []events.KinesisBatchItemFailure{}
+		for _, id := range getBatchItemFailures(ctx, ids, results) {
+			failures = append(failures, events.KinesisBatchItemFailure{ItemIdentifier: id})
+		}
+		return events.KinesisEventResponse{BatchItemFailures: failures}, nil
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetKinesisHandler(t *testing.T) {
+
+	//Data is base64 encoded in the raw event, as Lambda delivers it
+	raw := `{"Records":[
+		{"eventID":"shard-1:100","kinesis":{"partitionKey":"a","sequenceNumber":"100","data":"eyJGb28iOjF9"}},
+		{"eventID":"shard-1:200","kinesis":{"partitionKey":"b","sequenceNumber":"200","data":"eyJGb28iOjJ9"}}
+	]}`
+	twoRecordEvent := events.KinesisEvent{}
+	err := json.Unmarshal([]byte(raw), &twoRecordEvent)
+	assert.Nil(t, err)
+
+	testcases := []struct {
+		name          string
+		processRecord KinesisRecordProcessor[inputEvent]
+		event         events.KinesisEvent
+		checkResult   func(t *testing.T, result events.KinesisEventResponse)
+	}{
+		{
+			name: "All records processed",
+			processRecord: func(ctx context.Context, record KinesisRecord[inputEvent]) error {
+				if record.Payload.Foo == 1 {
+					assert.Equal(t, "a", record.PartitionKey)
+					assert.Equal(t, "100", record.SequenceNumber)
+				}
+				return nil
+			},
+			event: twoRecordEvent,
+			checkResult: func(t *testing.T, result events.KinesisEventResponse) {
+				assert.Equal(t, events.KinesisEventResponse{BatchItemFailures: []events.KinesisBatchItemFailure{}}, result)
+			},
+		},
+		{
+			name: "Failures reported by sequence number",
+			processRecord: func(ctx context.Context, record KinesisRecord[inputEvent]) error {
+				if record.Payload.Foo == 2 {
+					return errors.New("something bad happened")
+				}
+				return nil
+			},
+			event: twoRecordEvent,
+			checkResult: func(t *testing.T, result events.KinesisEventResponse) {
+				assert.Equal(t, []events.KinesisBatchItemFailure{{ItemIdentifier: "200"}}, result.BatchItemFailures)
+			},
+		},
+		{
+			name: "Invalid data",
+			processRecord: func(ctx context.Context, record KinesisRecord[inputEvent]) error {
+				return nil
+			},
+			event: events.KinesisEvent{Records: []events.KinesisEventRecord{
+				{Kinesis: events.KinesisRecord{SequenceNumber: "300", Data: []byte("not json")}},
+			}},
+			checkResult: func(t *testing.T, result events.KinesisEventResponse) {
+				assert.Equal(t, []events.KinesisBatchItemFailure{{ItemIdentifier: "300"}}, result.BatchItemFailures)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+
+			handler := GetKinesisHandler(tc.processRecord)
+			result, err := handler(ctx, tc.event)
+			assert.Nil(t, err)
+			tc.checkResult(t, result)
+		})
+	}
+}
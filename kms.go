@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"container/list"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// KMSAPI is the subset of the KMS client used by KMSEncrypter
+type KMSAPI interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+}
+
+// Envelope holds a payload encrypted with a KMS data key, along with the encrypted data key needed to decrypt it
+type Envelope struct {
+	EncryptedKey []byte `json:"encryptedKey"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// KMSEncrypter encrypts and decrypts payloads using a KMS key. Small payloads (up to 4KB) can be encrypted directly by
+// KMS with Encrypt; larger payloads should use EnvelopeEncrypt which encrypts locally with a cached data key
+type KMSEncrypter struct {
+	client        KMSAPI
+	keyID         string
+	dataKeyMaxAge time.Duration
+
+	mu      sync.Mutex
+	dataKey *dataKey
+	//decryptedKeys caches decrypted data keys by their encrypted form, with the most recently used at the front of
+	//decryptedOrder
+	decryptedKeys  map[string]*list.Element
+	decryptedOrder *list.List
+}
+
+// maxDecryptedKeys limits how many decrypted data keys a KMSEncrypter caches
+const maxDecryptedKeys = 100
+
+type decryptedKey struct {
+	encrypted string
+	plaintext []byte
+	cached    time.Time
+}
+
+type dataKey struct {
+	plaintext []byte
+	encrypted []byte
+	created   time.Time
+}
+
+// NewKMSEncrypter returns a KMSEncrypter that uses the given key ID/ARN. Data keys are reused for up to 5 minutes, and
+// decrypted data keys are cached for as long, for the most recently used 100 keys
+func NewKMSEncrypter(client KMSAPI, keyID string) *KMSEncrypter {
+	return &KMSEncrypter{
+		client:         client,
+		keyID:          keyID,
+		dataKeyMaxAge:  5 * time.Minute,
+		decryptedKeys:  map[string]*list.Element{},
+		decryptedOrder: list.New(),
+	}
+}
+
+// MustGetKMSEncrypter returns a KMSEncrypter for the key ARN in the KMS_KEY_ARN environment variable
+func MustGetKMSEncrypter(awsConfig aws.Config) *KMSEncrypter {
+	return NewKMSEncrypter(kms.NewFromConfig(awsConfig), MustGetEnv("KMS_KEY_ARN"))
+}
+
+// Encrypt encrypts a small payload directly with KMS
+func (e *KMSEncrypter) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	output, err := e.client.Encrypt(ctx, &kms.EncryptInput{KeyId: aws.String(e.keyID), Plaintext: plaintext})
+	if err != nil {
+		return nil, StageErr(ctx, "kms encrypt", err)
+	}
+	AddStage(ctx, "kms encrypt")
+	return output.CiphertextBlob, nil
+}
+
+// Decrypt decrypts a payload that was encrypted with Encrypt
+func (e *KMSEncrypter) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	output, err := e.client.Decrypt(ctx, &kms.DecryptInput{KeyId: aws.String(e.keyID), CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, StageErr(ctx, "kms decrypt", err)
+	}
+	AddStage(ctx, "kms decrypt")
+	return output.Plaintext, nil
+}
+
+// EnvelopeEncrypt encrypts a payload of any size with AES-GCM using a KMS data key
+func (e *KMSEncrypter) EnvelopeEncrypt(ctx context.Context, plaintext []byte) (*Envelope, error) {
+	key, err := e.getDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key.plaintext)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		EncryptedKey: key.encrypted,
+		Nonce:        nonce,
+		Ciphertext:   gcm.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
+
+// EnvelopeDecrypt decrypts a payload that was encrypted with EnvelopeEncrypt
+func (e *KMSEncrypter) EnvelopeDecrypt(ctx context.Context, envelope *Envelope) ([]byte, error) {
+	if envelope == nil {
+		return nil, errors.New("envelope must not be nil")
+	}
+
+	key, err := e.decryptDataKey(ctx, envelope.EncryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+}
+
+func (e *KMSEncrypter) getDataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := GetClock(ctx).Now()
+	if e.dataKey != nil && now.Sub(e.dataKey.created) < e.dataKeyMaxAge {
+		return e.dataKey, nil
+	}
+
+	output, err := e.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{KeyId: aws.String(e.keyID), KeySpec: types.DataKeySpecAes256})
+	if err != nil {
+		return nil, StageErr(ctx, "kms generate data key", err)
+	}
+	AddStage(ctx, "kms generate data key")
+	e.dataKey = &dataKey{plaintext: output.Plaintext, encrypted: output.CiphertextBlob, created: now}
+	e.cacheDecryptedKey(now, output.CiphertextBlob, output.Plaintext)
+	return e.dataKey, nil
+}
+
+func (e *KMSEncrypter) decryptDataKey(ctx context.Context, encrypted []byte) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := GetClock(ctx).Now()
+	if element, found := e.decryptedKeys[string(encrypted)]; found {
+		key := element.Value.(*decryptedKey)
+		if now.Sub(key.cached) < e.dataKeyMaxAge {
+			e.decryptedOrder.MoveToFront(element)
+			return key.plaintext, nil
+		}
+		e.decryptedOrder.Remove(element)
+		delete(e.decryptedKeys, key.encrypted)
+	}
+
+	output, err := e.client.Decrypt(ctx, &kms.DecryptInput{KeyId: aws.String(e.keyID), CiphertextBlob: encrypted})
+	if err != nil {
+		return nil, StageErr(ctx, "kms decrypt", err)
+	}
+	AddStage(ctx, "kms decrypt")
+	e.cacheDecryptedKey(now, encrypted, output.Plaintext)
+	return output.Plaintext, nil
+}
+
+// cacheDecryptedKey caches a decrypted data key, evicting the least recently used key if the cache is full. Must be
+// called with e.mu held
+func (e *KMSEncrypter) cacheDecryptedKey(now time.Time, encrypted []byte, plaintext []byte) {
+	if element, found := e.decryptedKeys[string(encrypted)]; found {
+		e.decryptedOrder.Remove(element)
+	}
+	e.decryptedKeys[string(encrypted)] = e.decryptedOrder.PushFront(&decryptedKey{encrypted: string(encrypted), plaintext: plaintext, cached: now})
+	for e.decryptedOrder.Len() > maxDecryptedKeys {
+		oldest := e.decryptedOrder.Back()
+		e.decryptedOrder.Remove(oldest)
+		delete(e.decryptedKeys, oldest.Value.(*decryptedKey).encrypted)
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKMSEncrypter(t *testing.T) {
+
+	t.Run("Encrypt and decrypt", func(t *testing.T) {
+		client := &mockKMSClient{}
+		encrypter := NewKMSEncrypter(client, "key-arn")
+
+		ctx := ContextWithStages(context.Background())
+		ciphertext, err := encrypter.Encrypt(ctx, []byte("secret"))
+		assert.Nil(t, err)
+		assert.NotEqual(t, []byte("secret"), ciphertext)
+
+		plaintext, err := encrypter.Decrypt(ctx, ciphertext)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("secret"), plaintext)
+		assert.Equal(t, []string{"kms encrypt", "kms decrypt"}, getStageDescriptions(ctx))
+	})
+
+	t.Run("Envelope encrypt and decrypt", func(t *testing.T) {
+		client := &mockKMSClient{}
+		encrypter := NewKMSEncrypter(client, "key-arn")
+		payload := bytes.Repeat([]byte("a"), 10_000)
+
+		ctx := ContextWithStages(context.Background())
+		first, err := encrypter.EnvelopeEncrypt(ctx, payload)
+		assert.Nil(t, err)
+		second, err := encrypter.EnvelopeEncrypt(ctx, payload)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, client.dataKeyCalls, "data key should be cached")
+		assert.Equal(t, first.EncryptedKey, second.EncryptedKey)
+
+		//Use a new encrypter so that the data key has to be decrypted by KMS
+		plaintext, err := NewKMSEncrypter(client, "key-arn").EnvelopeDecrypt(ctx, first)
+		assert.Nil(t, err)
+		assert.Equal(t, payload, plaintext)
+		//Cached keys don't call KMS, so they don't add a stage
+		assert.Equal(t, []string{"kms generate data key", "kms decrypt"}, getStageDescriptions(ctx))
+	})
+
+	t.Run("Decrypted data keys expire", func(t *testing.T) {
+		clock := NewFakeClock(time.Now())
+		ctx := ContextWithClock(context.Background(), clock)
+		client := &mockKMSClient{}
+		envelope, err := NewKMSEncrypter(client, "key-arn").EnvelopeEncrypt(ctx, []byte("secret"))
+		assert.Nil(t, err)
+
+		decrypter := NewKMSEncrypter(client, "key-arn")
+		for i := 0; i < 2; i++ {
+			_, err = decrypter.EnvelopeDecrypt(ctx, envelope)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 1, client.decryptCalls, "decrypted data key should be cached")
+
+		clock.Advance(5 * time.Minute)
+		_, err = decrypter.EnvelopeDecrypt(ctx, envelope)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, client.decryptCalls, "decrypted data key should have expired")
+	})
+
+	t.Run("Decrypted data keys are limited", func(t *testing.T) {
+		client := &mockKMSClient{}
+		encrypter := NewKMSEncrypter(client, "key-arn")
+		for i := 0; i < maxDecryptedKeys+10; i++ {
+			_, err := encrypter.decryptDataKey(context.Background(), []byte{byte(i), byte(i >> 8)})
+			assert.Nil(t, err)
+		}
+		assert.Len(t, encrypter.decryptedKeys, maxDecryptedKeys)
+		assert.Equal(t, maxDecryptedKeys, encrypter.decryptedOrder.Len())
+
+		//The least recently used keys were evicted
+		_, err := encrypter.decryptDataKey(context.Background(), []byte{byte(0), byte(0)})
+		assert.Nil(t, err)
+		assert.Equal(t, maxDecryptedKeys+11, client.decryptCalls)
+	})
+}
+
+// mockKMSClient "encrypts" by reversing the bytes
+type mockKMSClient struct {
+	dataKeyCalls int
+	decryptCalls int
+}
+
+func (m *mockKMSClient) Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error) {
+	return &kms.EncryptOutput{CiphertextBlob: reverse(params.Plaintext)}, nil
+}
+
+func (m *mockKMSClient) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	m.decryptCalls++
+	return &kms.DecryptOutput{Plaintext: reverse(params.CiphertextBlob)}, nil
+}
+
+func (m *mockKMSClient) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	m.dataKeyCalls++
+	key := bytes.Repeat([]byte{byte(m.dataKeyCalls)}, 31)
+	key = append(key, 0xff)
+	return &kms.GenerateDataKeyOutput{Plaintext: key, CiphertextBlob: reverse(key)}, nil
+}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// LocalEndpoint is the path that StartLocal accepts invocations on
+const LocalEndpoint = "/endpoint"
+
+// localTimeout matches the maximum lambda timeout, so that handlers which need a deadline can run locally
+const localTimeout = 15 * time.Minute
+
+// StartLocal serves the handler over HTTP so that it can be run outside of lambda. Each POST to /endpoint invokes the
+// handler with the JSON request body as the event. An X-Amzn-Trace-Id request header is propagated into the context and
+// logs in the same way as the lambda runtime's trace header. If LOCAL_XRAY_ENABLED is "true", segments are also sent
+// to the X-Ray daemon (at AWS_XRAY_DAEMON_ADDRESS, default 127.0.0.1:2000)
+func StartLocal[T interface{}, U interface{}](addr string, handlerFunc Handler[T, U]) error {
+	mux := http.NewServeMux()
+	var invokeHandler http.Handler = GetLocalHTTPHandler(handlerFunc)
+	if os.Getenv("LOCAL_XRAY_ENABLED") == "true" {
+		name := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+		if name == "" {
+			name = "local"
+		}
+		invokeHandler = xray.Handler(xray.NewFixedSegmentNamer(name), invokeHandler)
+	}
+	mux.Handle(LocalEndpoint, invokeHandler)
+	GetLogger(context.Background()).Info("serving handler locally", "addr", addr, "endpoint", LocalEndpoint)
+	return http.ListenAndServe(addr, mux)
+}
+
+// GetLocalHTTPHandler returns an http.Handler that invokes the handler (wrapped with WithLogger) for each request
+func GetLocalHTTPHandler[T interface{}, U interface{}](handlerFunc Handler[T, U]) http.Handler {
+	h := WithLogger(handlerFunc)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var event T
+		err := json.NewDecoder(r.Body).Decode(&event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), localTimeout)
+		defer cancel()
+		ctx = lambdacontext.NewContext(ctx, &lambdacontext.LambdaContext{AwsRequestID: newLocalRequestID()})
+		if traceHeader := getLocalTraceHeader(ctx, r); traceHeader != "" {
+			ctx = context.WithValue(ctx, xray.LambdaTraceHeaderKey, traceHeader)
+			w.Header().Set("X-Amzn-Trace-Id", traceHeader)
+		}
+
+		response, err := h(ctx, event)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]string{"errorMessage": err.Error(), "errorType": GetErrorCode(err)})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+// getLocalTraceHeader prefers the segment created by xray.Handler (which continues any incoming trace) over the raw
+// request header
+func getLocalTraceHeader(ctx context.Context, r *http.Request) string {
+	if traceID := xray.TraceID(ctx); traceID != "" {
+		return "Root=" + traceID
+	}
+	return r.Header.Get("X-Amzn-Trace-Id")
+}
+
+func newLocalRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLocalHTTPHandler(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		method      string
+		body        string
+		traceHeader string
+		err         error
+		checkResult func(t *testing.T, rec *httptest.ResponseRecorder, ctx context.Context)
+	}{
+		{
+			name:        "Event handled with trace header",
+			method:      http.MethodPost,
+			body:        `{"Foo":3}`,
+			traceHeader: "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1",
+			checkResult: func(t *testing.T, rec *httptest.ResponseRecorder, ctx context.Context) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.JSONEq(t, `{"Bar":6}`, rec.Body.String())
+				assert.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1", rec.Header().Get("X-Amzn-Trace-Id"))
+				assert.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1", ctx.Value(xray.LambdaTraceHeaderKey))
+				lc, ok := lambdacontext.FromContext(ctx)
+				assert.True(t, ok)
+				assert.NotEmpty(t, lc.AwsRequestID)
+				_, hasDeadline := ctx.Deadline()
+				assert.True(t, hasDeadline)
+			},
+		},
+		{
+			name:   "Handler error",
+			method: http.MethodPost,
+			body:   `{"Foo":3}`,
+			err:    NewHandlerError("ValidationError", errors.New("invalid")),
+			checkResult: func(t *testing.T, rec *httptest.ResponseRecorder, ctx context.Context) {
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+				assert.JSONEq(t, `{"errorMessage":"invalid","errorType":"ValidationError"}`, rec.Body.String())
+				assert.Empty(t, rec.Header().Get("X-Amzn-Trace-Id"))
+			},
+		},
+		{
+			name:   "Invalid JSON",
+			method: http.MethodPost,
+			body:   `{`,
+			checkResult: func(t *testing.T, rec *httptest.ResponseRecorder, ctx context.Context) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Nil(t, ctx)
+			},
+		},
+		{
+			name:   "Wrong method",
+			method: http.MethodGet,
+			checkResult: func(t *testing.T, rec *httptest.ResponseRecorder, ctx context.Context) {
+				assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var handlerCtx context.Context
+			h := GetLocalHTTPHandler(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				handlerCtx = ctx
+				return outputEvent{Bar: event.Foo * 2}, tc.err
+			})
+
+			req := httptest.NewRequest(tc.method, LocalEndpoint, strings.NewReader(tc.body))
+			if tc.traceHeader != "" {
+				req.Header.Set("X-Amzn-Trace-Id", tc.traceHeader)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			tc.checkResult(t, rec, handlerCtx)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// getLogHandlerOptions returns the options for the JSON log handler created by ContextWithLogger. Log attributes can be
+// filtered centrally with the LOG_FIELD_ALLOWLIST and LOG_FIELD_DENYLIST environment variables (comma-separated keys,
+// or prefixes ending with *). The denylist applies to attributes and groups at any depth; the allowlist applies to
+// top-level attributes and groups, and the time, level and msg fields are always kept
+func getLogHandlerOptions() *slog.HandlerOptions {
+	allowlist := splitLogFieldList(os.Getenv("LOG_FIELD_ALLOWLIST"))
+	denylist := splitLogFieldList(os.Getenv("LOG_FIELD_DENYLIST"))
+	if len(allowlist) == 0 && len(denylist) == 0 {
+		return nil
+	}
+
+	return &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			//ReplaceAttr isn't called for groups themselves, so attributes inside a group are matched on the group names
+			if len(denylist) > 0 {
+				if matchesAllowlist(a.Key, denylist) {
+					return slog.Attr{}
+				}
+				for _, group := range groups {
+					if matchesAllowlist(group, denylist) {
+						return slog.Attr{}
+					}
+				}
+			}
+			if len(allowlist) == 0 {
+				return a
+			}
+			if len(groups) > 0 {
+				if matchesAllowlist(groups[0], allowlist) {
+					return a
+				}
+				return slog.Attr{}
+			}
+			switch a.Key {
+			case slog.TimeKey, slog.LevelKey, slog.MessageKey:
+				return a
+			}
+			if !matchesAllowlist(a.Key, allowlist) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}
+}
+
+func splitLogFieldList(v string) []string {
+	list := []string{}
+	for _, key := range strings.Split(v, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			list = append(list, key)
+		}
+	}
+	return list
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLogHandlerOptions(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		allowlist   string
+		denylist    string
+		checkResult func(t *testing.T, entry map[string]interface{})
+	}{
+		{
+			name: "All fields logged by default",
+			checkResult: func(t *testing.T, entry map[string]interface{}) {
+				assert.Equal(t, "hello", entry["body"])
+				assert.Equal(t, "abc", entry["trace_id"])
+				assert.Equal(t, map[string]interface{}{"name": "orders", "secret": "x"}, entry["env"])
+			},
+		},
+		{
+			name:     "Denied fields removed",
+			denylist: "body, secret",
+			checkResult: func(t *testing.T, entry map[string]interface{}) {
+				assert.NotContains(t, entry, "body")
+				assert.Equal(t, "abc", entry["trace_id"])
+				assert.Equal(t, map[string]interface{}{"name": "orders"}, entry["env"])
+			},
+		},
+		{
+			name:     "Denied group removed",
+			denylist: "env",
+			checkResult: func(t *testing.T, entry map[string]interface{}) {
+				assert.NotContains(t, entry, "env")
+				assert.Equal(t, "hello", entry["body"])
+			},
+		},
+		{
+			name:      "Only allowed fields kept",
+			allowlist: "trace*,env",
+			denylist:  "secret",
+			checkResult: func(t *testing.T, entry map[string]interface{}) {
+				assert.NotContains(t, entry, "body")
+				assert.Equal(t, "abc", entry["trace_id"])
+				assert.Equal(t, "test message", entry["msg"])
+				assert.Equal(t, "INFO", entry["level"])
+				assert.Equal(t, map[string]interface{}{"name": "orders"}, entry["env"])
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LOG_FIELD_ALLOWLIST", tc.allowlist)
+			t.Setenv("LOG_FIELD_DENYLIST", tc.denylist)
+
+			buf := &bytes.Buffer{}
+			logger := slog.New(slog.NewJSONHandler(buf, getLogHandlerOptions())).With("trace_id", "abc")
+			logger.Info("test message", "body", "hello", slog.Group("env", "name", "orders", "secret", "x"))
+
+			entry := map[string]interface{}{}
+			assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+			tc.checkResult(t, entry)
+		})
+	}
+}
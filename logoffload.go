@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// WithLogOffload keeps log lines under maxBytes (CloudWatch truncates lines over 256KB) by uploading the largest
+// attributes of an oversized line to S3 and logging a pointer ({"s3Bucket", "s3Key", "sizeBytes"}) in their place.
+// Objects are written under <prefix>log-params/<date>/<request ID>/. Attributes added with Logger.With count towards the
+// size of a line, but only the attributes of the log call itself are offloaded
+func WithLogOffload[T interface{}, U interface{}](client S3PutObjectAPI, bucket string, prefix string, maxBytes int, handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		offload := &offloadHandler{
+			next:      GetLogger(ctx).Handler(),
+			client:    client,
+			bucket:    bucket,
+			keyPrefix: fmt.Sprintf("%slog-params/%s/%s/", prefix, GetClock(ctx).Now().UTC().Format("2006-01-02"), getRequestID(ctx)),
+			maxBytes:  maxBytes,
+			count:     &atomic.Int64{},
+		}
+		ctx = GetNewContextWithLogger(ctx, slog.New(offload))
+		return handlerFunc(ctx, event)
+	}
+}
+
+type offloadHandler struct {
+	next      slog.Handler
+	client    S3PutObjectAPI
+	bucket    string
+	keyPrefix string
+	maxBytes  int
+	count     *atomic.Int64
+	//withBytes is the encoded size of the attributes and groups added with WithAttrs and WithGroup
+	withBytes int
+}
+
+func (h *offloadHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *offloadHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	copied := *h
+	copied.next = h.next.WithAttrs(attrs)
+	for _, a := range attrs {
+		copied.withBytes += len(a.Key) + len(encodeAttrValue(a.Value.Resolve()))
+	}
+	return &copied
+}
+
+func (h *offloadHandler) WithGroup(name string) slog.Handler {
+	copied := *h
+	copied.next = h.next.WithGroup(name)
+	copied.withBytes += len(name)
+	return &copied
+}
+
+func (h *offloadHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	encoded := make([][]byte, 0, record.NumAttrs())
+	total := len(record.Message) + h.withBytes
+	record.Attrs(func(a slog.Attr) bool {
+		a.Value = a.Value.Resolve()
+		b := encodeAttrValue(a.Value)
+		attrs = append(attrs, a)
+		encoded = append(encoded, b)
+		total += len(a.Key) + len(b)
+		return true
+	})
+	if total <= h.maxBytes {
+		return h.next.Handle(ctx, record)
+	}
+
+	//Offload the largest attributes first
+	order := make([]int, len(attrs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(encoded[order[i]]) > len(encoded[order[j]])
+	})
+	for _, i := range order {
+		if total <= h.maxBytes {
+			break
+		}
+		key := fmt.Sprintf("%s%d-%s.json", h.keyPrefix, h.count.Add(1), attrs[i].Key)
+		err := h.upload(ctx, key, encoded[i])
+		if err != nil {
+			attrs[i] = slog.String(attrs[i].Key, fmt.Sprintf("[offload failed: %s]", err.Error()))
+		} else {
+			attrs[i] = slog.Any(attrs[i].Key, map[string]interface{}{"s3Bucket": h.bucket, "s3Key": key, "sizeBytes": len(encoded[i])})
+		}
+		total -= len(encoded[i])
+	}
+
+	offloaded := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	offloaded.AddAttrs(attrs...)
+	return h.next.Handle(ctx, offloaded)
+}
+
+// encodeAttrValue returns the JSON encoding of a resolved attribute value, or its string form if it can't be encoded
+func encodeAttrValue(v slog.Value) []byte {
+	b, err := json.Marshal(v.Any())
+	if err != nil {
+		return []byte(v.String())
+	}
+	return b
+}
+
+func (h *offloadHandler) upload(ctx context.Context, key string, b []byte) error {
+	//The line may be logged as the invocation runs out of time, so don't use the handler's context
+	ctx, cancel := withReportTimeout(ctx)
+	defer cancel()
+	_, err := h.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(h.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
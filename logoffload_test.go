@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogOffload(t *testing.T) {
+
+	largeBody := strings.Repeat("x", 500)
+
+	testcases := []struct {
+		name        string
+		body        string
+		context     string
+		checkResult func(t *testing.T, client *mockS3PutClient, entry map[string]interface{})
+	}{
+		{
+			name: "Small line logged unchanged",
+			body: "hello",
+			checkResult: func(t *testing.T, client *mockS3PutClient, entry map[string]interface{}) {
+				assert.Nil(t, client.input)
+				assert.Equal(t, "hello", entry["body"])
+				assert.Equal(t, "abc", entry["trace_id"])
+			},
+		},
+		{
+			name: "Largest attribute offloaded",
+			body: largeBody,
+			checkResult: func(t *testing.T, client *mockS3PutClient, entry map[string]interface{}) {
+				assert.Equal(t, "log-bucket", aws.ToString(client.input.Bucket))
+				assert.Regexp(t, `^my-function/log-params/\d{4}-\d{2}-\d{2}/request-1/1-body\.json$`, aws.ToString(client.input.Key))
+				assert.Equal(t, `"`+largeBody+`"`, client.body)
+
+				pointer := entry["body"].(map[string]interface{})
+				assert.Equal(t, "log-bucket", pointer["s3Bucket"])
+				assert.Equal(t, aws.ToString(client.input.Key), pointer["s3Key"])
+				assert.Equal(t, 502.0, pointer["sizeBytes"])
+				assert.Equal(t, "something bad happened", entry["error"])
+				assert.Equal(t, "abc", entry["trace_id"])
+			},
+		},
+		{
+			name:    "Attributes added with the logger counted",
+			body:    strings.Repeat("x", 100),
+			context: strings.Repeat("y", 100),
+			checkResult: func(t *testing.T, client *mockS3PutClient, entry map[string]interface{}) {
+				assert.Regexp(t, `/1-body\.json$`, aws.ToString(client.input.Key))
+				assert.Equal(t, strings.Repeat("y", 100), entry["context"])
+				assert.Equal(t, "log-bucket", entry["body"].(map[string]interface{})["s3Bucket"])
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockS3PutClient{}
+			buf := &bytes.Buffer{}
+			ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+			ctx = lambdacontext.NewContext(ctx, &lambdacontext.LambdaContext{AwsRequestID: "request-1"})
+
+			h := WithLogOffload(client, "log-bucket", "my-function/", 200, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				GetLogger(ctx).With("trace_id", "abc", "context", tc.context).Error("processing failed", "body", tc.body, "error", errors.New("something bad happened").Error())
+				return outputEvent{}, nil
+			})
+			_, err := h(ctx, inputEvent{})
+			assert.Nil(t, err)
+
+			entry := map[string]interface{}{}
+			assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+			tc.checkResult(t, client, entry)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const hopCountKey = "hopCount"
+
+// HopCountAttribute is the message attribute used to propagate the hop count when a message is passed between functions
+const HopCountAttribute = "HopCount"
+
+const defaultMaxHops = 10
+
+// ErrLoopDetected is returned when a message has passed through more functions than allowed by MAX_HOPS. The SQS handler
+// treats it as non-retryable, so a looping message goes to the dead-letter queue (or is dropped) instead of being retried
+var ErrLoopDetected = errors.New("message loop detected")
+
+// GetHopCount returns the number of functions the message being processed has already passed through
+func GetHopCount(ctx context.Context) int {
+	val := ctx.Value(hopCountKey)
+	if val != nil {
+		return val.(int)
+	}
+	return 0
+}
+
+// NextHopCount returns the value to set for HopCountAttribute on messages sent by the current function
+func NextHopCount(ctx context.Context) string {
+	return strconv.Itoa(GetHopCount(ctx) + 1)
+}
+
+// ContextWithHopCount attaches the hop count to the context. The larger of hops and the Lambda recursion detection
+// lineage count is used, and ErrLoopDetected is returned if it exceeds the MAX_HOPS environment variable (default 10)
+func ContextWithHopCount(ctx context.Context, hops int) (context.Context, error) {
+	if lineage, found := GetLambdaLineage(); found && lineage > hops {
+		hops = lineage
+	}
+
+	maxHops := defaultMaxHops
+	if v := os.Getenv("MAX_HOPS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			maxHops = i
+		}
+	}
+
+	if hops > maxHops {
+		GetLogger(ctx).Error("message loop detected", "hopCount", hops, "maxHops", maxHops)
+		return ctx, fmt.Errorf("%w: hop count %d exceeds maximum of %d", ErrLoopDetected, hops, maxHops)
+	}
+	return context.WithValue(ctx, hopCountKey, hops), nil
+}
+
+// GetLambdaLineage returns the invocation count from the Lineage field that Lambda's recursive loop detection adds to
+// the trace header. The second return value is false if the header has no lineage
+func GetLambdaLineage() (int, bool) {
+	for _, part := range strings.Split(os.Getenv("_X_AMZN_TRACE_ID"), ";") {
+		value, found := strings.CutPrefix(part, "Lineage=")
+		if !found {
+			continue
+		}
+		//The lineage has the format <hash>:<count> (newer headers also prefix a version)
+		fields := strings.Split(value, ":")
+		count, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			return 0, false
+		}
+		return count, true
+	}
+	return 0, false
+}
+
+func getSQSHopCount(record events.SQSMessage) int {
+	attribute, found := record.MessageAttributes[HopCountAttribute]
+	if !found || attribute.StringValue == nil {
+		return 0
+	}
+	hops, _ := strconv.Atoi(*attribute.StringValue)
+	return hops
+}
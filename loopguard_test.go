@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextWithHopCount(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		hops        int
+		maxHops     string
+		traceID     string
+		checkResult func(t *testing.T, ctx context.Context, err error)
+	}{
+		{
+			name: "Within default limit",
+			hops: 3,
+			checkResult: func(t *testing.T, ctx context.Context, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 3, GetHopCount(ctx))
+				assert.Equal(t, "4", NextHopCount(ctx))
+			},
+		},
+		{
+			name:    "Exceeds configured limit",
+			hops:    3,
+			maxHops: "2",
+			checkResult: func(t *testing.T, ctx context.Context, err error) {
+				assert.ErrorIs(t, err, ErrLoopDetected)
+			},
+		},
+		{
+			name:    "Lambda lineage exceeds limit",
+			hops:    1,
+			traceID: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1;Lineage=a87bd80c:12",
+			checkResult: func(t *testing.T, ctx context.Context, err error) {
+				assert.ErrorIs(t, err, ErrLoopDetected)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("MAX_HOPS", tc.maxHops)
+			t.Setenv("_X_AMZN_TRACE_ID", tc.traceID)
+
+			ctx, err := ContextWithHopCount(context.Background(), tc.hops)
+			tc.checkResult(t, ctx, err)
+		})
+	}
+}
+
+func TestGetSQSHandlerLoopDetected(t *testing.T) {
+	client := &mockSQSClient{}
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		t.Error("looping message should not be processed")
+		return nil
+	}, WithNonRetryableDeadLetterQueue(client, "https://dlq"))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{
+		ReceiptHandle:     "1",
+		Body:              "hello",
+		MessageAttributes: map[string]events.SQSMessageAttribute{HopCountAttribute: {StringValue: aws.String("11"), DataType: "Number"}},
+	}}})
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+	assert.Len(t, client.sent, 1)
+	assert.Contains(t, aws.ToString(client.sent[0].MessageAttributes[FailureErrorMessageAttribute].StringValue), "loop guard: ")
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const maskedValue = "****"
+
+// getLogMaskPaths parses LOG_MASK_PATHS: comma-separated JSON paths such as "$.customer.email" or "$.items[*].card".
+// A "*" matches any key or array index
+func getLogMaskPaths() [][]string {
+	paths := [][]string{}
+	for _, path := range strings.Split(os.Getenv("LOG_MASK_PATHS"), ",") {
+		path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+		if path == "" {
+			continue
+		}
+		path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+		paths = append(paths, strings.Split(strings.TrimPrefix(path, "."), "."))
+	}
+	return paths
+}
+
+// maskLogBody masks the LOG_MASK_PATHS in a JSON message body before it is logged. Bodies that aren't JSON are returned
+// unchanged
+func maskLogBody(body string) string {
+	paths := getLogMaskPaths()
+	if len(paths) == 0 {
+		return body
+	}
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if decoder.Decode(&value) != nil {
+		return body
+	}
+	b, err := json.Marshal(maskJSONValue(nil, value, paths))
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+// maskLogValue masks the LOG_MASK_PATHS in the JSON encoding of v, for logging events that have already been unmarshalled
+func maskLogValue(v interface{}) interface{} {
+	if len(getLogMaskPaths()) == 0 {
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	return json.RawMessage(maskLogBody(string(b)))
+}
+
+func maskJSONValue(path []string, value interface{}, paths [][]string) interface{} {
+	if matchesJSONPath(path, paths) {
+		return maskScalar(value)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = maskJSONValue(append(path[:len(path):len(path)], k), item, paths)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = maskJSONValue(append(path[:len(path):len(path)], strconv.Itoa(i)), item, paths)
+		}
+	}
+	return value
+}
+
+// maskScalar replaces a value with "****", or with an HMAC of it if LOG_MASK_MODE is "hash" so that equal values can
+// still be correlated across log lines. The HMAC is keyed with LOG_MASK_KEY so that low-entropy values (e.g. card
+// numbers) can't be recovered by hashing guesses; without a key, values are masked with "****"
+func maskScalar(value interface{}) interface{} {
+	key := os.Getenv("LOG_MASK_KEY")
+	if os.Getenv("LOG_MASK_MODE") != "hash" || key == "" {
+		return maskedValue
+	}
+	b, _ := json.Marshal(value)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(bytes.TrimSpace(b))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
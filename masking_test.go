@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskLogBody(t *testing.T) {
+	body := `{"customer":{"email":"a@example.com","name":"A"},"cards":[{"number":"4111"},{"number":"5500"}],"total":1.50}`
+
+	testcases := []struct {
+		name     string
+		paths    string
+		mode     string
+		key      string
+		body     string
+		expected string
+	}{
+		{
+			name:     "No paths",
+			body:     body,
+			expected: body,
+		},
+		{
+			name:     "Masked",
+			paths:    "$.customer.email, $.cards[*].number",
+			body:     body,
+			expected: `{"cards":[{"number":"****"},{"number":"****"}],"customer":{"email":"****","name":"A"},"total":1.50}`,
+		},
+		{
+			name:     "Hashed",
+			paths:    "$.customer.email",
+			mode:     "hash",
+			key:      "secret",
+			body:     body,
+			expected: `{"cards":[{"number":"4111"},{"number":"5500"}],"customer":{"email":"hmac:87ec7eeb9429a2e4","name":"A"},"total":1.50}`,
+		},
+		{
+			name:     "Hash mode without a key",
+			paths:    "$.customer.email",
+			mode:     "hash",
+			body:     body,
+			expected: `{"cards":[{"number":"4111"},{"number":"5500"}],"customer":{"email":"****","name":"A"},"total":1.50}`,
+		},
+		{
+			name:     "Not JSON",
+			paths:    "$.customer.email",
+			body:     "plain text",
+			expected: "plain text",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LOG_MASK_PATHS", tc.paths)
+			t.Setenv("LOG_MASK_MODE", tc.mode)
+			t.Setenv("LOG_MASK_KEY", tc.key)
+			assert.Equal(t, tc.expected, maskLogBody(tc.body))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	UnitCount        = "Count"
+	UnitMilliseconds = "Milliseconds"
+	UnitBytes        = "Bytes"
+	UnitNone         = "None"
+)
+
+const metricNamespaceParamsKey = "metricNamespaceParams"
+
+// CloudWatch namespaces may contain alphanumeric characters and . - _ / # : and spaces
+var metricNamespacePattern = regexp.MustCompile(`^[a-zA-Z0-9.\-_/#: ]{1,255}$`)
+var metricNamespacePlaceholder = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+var metricsWriter io.Writer = os.Stdout
+
+// ContextWithMetricNamespaceParam sets the value used for the {name} placeholder in METRIC_NAMESPACE, so that shared
+// multi-tenant functions can emit metrics into per-tenant namespaces
+func ContextWithMetricNamespaceParam(ctx context.Context, name string, value string) context.Context {
+	params := map[string]string{}
+	if existing, ok := ctx.Value(metricNamespaceParamsKey).(map[string]string); ok {
+		for k, v := range existing {
+			params[k] = v
+		}
+	}
+	params[name] = value
+	return context.WithValue(ctx, metricNamespaceParamsKey, params)
+}
+
+// getMetricNamespace returns METRIC_NAMESPACE with its placeholders filled. It returns an error if a placeholder has
+// no value or the result isn't a valid CloudWatch namespace
+func getMetricNamespace(ctx context.Context) (string, error) {
+	template := os.Getenv("METRIC_NAMESPACE")
+	if template == "" {
+		return "", nil
+	}
+	params, _ := ctx.Value(metricNamespaceParamsKey).(map[string]string)
+	missing := []string{}
+	namespace := metricNamespacePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := params[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for metric namespace placeholders %v", missing)
+	}
+	if !metricNamespacePattern.MatchString(namespace) || strings.HasPrefix(namespace, "AWS/") {
+		return "", fmt.Errorf("'%s' is not a valid metric namespace", namespace)
+	}
+	return namespace, nil
+}
+
+// EmitMetric writes a metric to stdout in CloudWatch embedded metric format (EMF). The namespace is read from the
+// METRIC_NAMESPACE environment variable - if it is not set the metric is not written. The namespace can contain
+// {name} placeholders which are filled from ContextWithMetricNamespaceParam, e.g. "Orders/{tenant}"
+func EmitMetric(ctx context.Context, name string, value float64, unit string, dimensions map[string]string) {
+	namespace, err := getMetricNamespace(ctx)
+	if err != nil {
+		GetLogger(ctx).Error("invalid metric namespace", "metric", name, "error", err.Error())
+		return
+	}
+	if namespace == "" {
+		return
+	}
+
+	dimensionKeys := []string{}
+	entry := map[string]interface{}{}
+	for k, v := range dimensions {
+		dimensionKeys = append(dimensionKeys, k)
+		entry[k] = v
+	}
+	sort.Strings(dimensionKeys)
+	entry[name] = value
+	entry["_aws"] = map[string]interface{}{
+		"Timestamp": GetClock(ctx).Now().UnixMilli(),
+		"CloudWatchMetrics": []interface{}{
+			map[string]interface{}{
+				"Namespace":  namespace,
+				"Dimensions": [][]string{dimensionKeys},
+				"Metrics":    []interface{}{map[string]string{"Name": name, "Unit": unit}},
+			},
+		},
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		GetLogger(ctx).Error("failed to marshal metric", "metric", name, "error", err.Error())
+		return
+	}
+	_, _ = metricsWriter.Write(append(b, '\n'))
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmitMetric(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		namespace   string
+		checkResult func(t *testing.T, output []byte)
+	}{
+		{
+			name:      "Metric written in EMF",
+			namespace: "MyService",
+			checkResult: func(t *testing.T, output []byte) {
+				entry := map[string]interface{}{}
+				assert.Nil(t, json.Unmarshal(output, &entry))
+				assert.Equal(t, 1.0, entry["Processed"])
+				assert.Equal(t, "orders", entry["Queue"])
+				metrics := entry["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+				assert.Equal(t, "MyService", metrics["Namespace"])
+				assert.Equal(t, []interface{}{[]interface{}{"Queue"}}, metrics["Dimensions"])
+			},
+		},
+		{
+			name: "No namespace configured",
+			checkResult: func(t *testing.T, output []byte) {
+				assert.Empty(t, output)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("METRIC_NAMESPACE", tc.namespace)
+			buf := captureMetrics(t)
+
+			EmitMetric(context.Background(), "Processed", 1, UnitCount, map[string]string{"Queue": "orders"})
+			tc.checkResult(t, buf.Bytes())
+		})
+	}
+}
+
+func TestGetMetricNamespace(t *testing.T) {
+
+	testcases := []struct {
+		name      string
+		template  string
+		params    map[string]string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "Plain namespace",
+			template: "MyService",
+			expected: "MyService",
+		},
+		{
+			name:     "Templated namespace",
+			template: "MyService/{tenant}",
+			params:   map[string]string{"tenant": "acme"},
+			expected: "MyService/acme",
+		},
+		{
+			name:      "Missing placeholder value",
+			template:  "MyService/{tenant}",
+			expectErr: true,
+		},
+		{
+			name:      "Invalid characters",
+			template:  "MyService/{tenant}",
+			params:    map[string]string{"tenant": "acme$"},
+			expectErr: true,
+		},
+		{
+			name:      "Reserved prefix",
+			template:  "{prefix}/Lambda",
+			params:    map[string]string{"prefix": "AWS"},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("METRIC_NAMESPACE", tc.template)
+			ctx := context.Background()
+			for k, v := range tc.params {
+				ctx = ContextWithMetricNamespaceParam(ctx, k, v)
+			}
+			namespace, err := getMetricNamespace(ctx)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, namespace)
+		})
+	}
+}
+
+func captureMetrics(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	original := metricsWriter
+	metricsWriter = buf
+	t.Cleanup(func() {
+		metricsWriter = original
+	})
+	return buf
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// MQMessage is an Amazon MQ message with its data base64 decoded and unmarshalled into T
+type MQMessage[T interface{}] struct {
+	Payload       T
+	MessageID     string
+	CorrelationID string
+	//Destination is the ActiveMQ physical destination name, or the RabbitMQ queue name (in the form "queue::vhost")
+	Destination string
+	Redelivered bool
+	Priority    int
+	//Properties are the ActiveMQ message properties or the RabbitMQ message headers
+	Properties map[string]interface{}
+}
+
+type MQMessageProcessor[T interface{}] func(ctx context.Context, message MQMessage[T]) error
+
+type ActiveMQHandler = Handler[events.ActiveMQEvent, struct{}]
+
+type RabbitMQHandler = Handler[events.RabbitMQEvent, struct{}]
+
+// GetActiveMQHandler returns a lambda handler that will base64 decode and unmarshal the data of each ActiveMQ message into
+// T and process the messages in parallel using the provided processMessage function. Amazon MQ has no partial batch
+// response, so if any message fails or times out the handler returns an error and the whole batch is redelivered
+func GetActiveMQHandler[T interface{}](processMessage MQMessageProcessor[T]) Handler[events.ActiveMQEvent, struct{}] {
+	return func(ctx context.Context, event events.ActiveMQEvent) (struct{}, error) {
+		messages := make([]mqEventMessage[T], len(event.Messages))
+		for i, m := range event.Messages {
+			properties := make(map[string]interface{}, len(m.Properties))
+			for k, v := range m.Properties {
+				properties[k] = v
+			}
+			messages[i] = mqEventMessage[T]{data: m.Data, message: MQMessage[T]{
+				MessageID:     m.MessageID,
+				CorrelationID: m.CorrelationID,
+				Destination:   m.Destination.PhysicalName,
+				Redelivered:   m.Redelivered,
+				Priority:      m.Priority,
+				Properties:    properties,
+			}}
+		}
+		return struct{}{}, processMQMessages(ctx, "activemq", messages, processMessage)
+	}
+}
+
+// GetRabbitMQHandler returns a lambda handler that will base64 decode and unmarshal the data of each RabbitMQ message
+// into T and process the messages (from all queues in the event) in parallel using the provided processMessage function.
+// Amazon MQ has no partial batch response, so if any message fails or times out the handler returns an error and the
+// whole batch is redelivered
+func GetRabbitMQHandler[T interface{}](processMessage MQMessageProcessor[T]) Handler[events.RabbitMQEvent, struct{}] {
+	return func(ctx context.Context, event events.RabbitMQEvent) (struct{}, error) {
+		queues := make([]string, 0, len(event.MessagesByQueue))
+		for queue := range event.MessagesByQueue {
+			queues = append(queues, queue)
+		}
+		sort.Strings(queues)
+
+		messages := []mqEventMessage[T]{}
+		for _, queue := range queues {
+			for _, m := range event.MessagesByQueue[queue] {
+				messages = append(messages, mqEventMessage[T]{data: m.Data, message: MQMessage[T]{
+					MessageID:     aws.ToString(m.BasicProperties.MessageID),
+					CorrelationID: aws.ToString(m.BasicProperties.CorrelationID),
+					Destination:   queue,
+					Redelivered:   m.Redelivered,
+					Priority:      int(m.BasicProperties.Priority),
+					Properties:    m.BasicProperties.Headers,
+				}})
+			}
+		}
+		return struct{}{}, processMQMessages(ctx, "rabbitmq", messages, processMessage)
+	}
+}
+
+type mqEventMessage[T interface{}] struct {
+	data    string
+	message MQMessage[T]
+}
+
+func processMQMessages[T interface{}](ctx context.Context, source string, messages []mqEventMessage[T], processMessage MQMessageProcessor[T]) error {
+
+	results, err := processBatch(ctx, messages, batchSource[mqEventMessage[T]]{
+		name: source + " message",
+		logAttrs: func(m mqEventMessage[T]) []any {
+			return []any{"messageId", m.message.MessageID, "destination", m.message.Destination}
+		},
+		process: func(ctx context.Context, m mqEventMessage[T]) error {
+			message := m.message
+			data, err := base64.StdEncoding.DecodeString(m.data)
+			if err != nil {
+				return StageErr(ctx, "decode data", err)
+			}
+			AddStage(ctx, "decode data")
+			err = json.Unmarshal(data, &message.Payload)
+			if err != nil {
+				return StageErr(ctx, "unmarshal data", err)
+			}
+			AddStage(ctx, "unmarshal data")
+			return processMessage(ctx, message)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if failed := countFailed(results); failed > 0 {
+		return fmt.Errorf("%d of %d %s messages failed", failed, len(results), source)
+	}
+	return nil
+}
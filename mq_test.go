@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetActiveMQHandler(t *testing.T) {
+
+	testcases := []struct {
+		name           string
+		processMessage MQMessageProcessor[inputEvent]
+		event          events.ActiveMQEvent
+		expectErr      string
+	}{
+		{
+			name: "Data decoded and unmarshalled",
+			processMessage: func(ctx context.Context, message MQMessage[inputEvent]) error {
+				assert.Equal(t, 1, message.Payload.Foo)
+				assert.Equal(t, "ID:1", message.MessageID)
+				assert.Equal(t, "orders", message.Destination)
+				assert.Equal(t, map[string]interface{}{"source": "test"}, message.Properties)
+				return nil
+			},
+			event: events.ActiveMQEvent{Messages: []events.ActiveMQMessage{{
+				MessageID:   "ID:1",
+				Data:        "eyJGb28iOjF9",
+				Destination: events.ActiveMQDestination{PhysicalName: "orders"},
+				Properties:  map[string]string{"source": "test"},
+			}}},
+		},
+		{
+			name: "Message fails",
+			processMessage: func(ctx context.Context, message MQMessage[inputEvent]) error {
+				if message.MessageID == "ID:2" {
+					return errors.New("something bad happened")
+				}
+				return nil
+			},
+			event: events.ActiveMQEvent{Messages: []events.ActiveMQMessage{
+				{MessageID: "ID:1", Data: "eyJGb28iOjF9"},
+				{MessageID: "ID:2", Data: "eyJGb28iOjF9"},
+			}},
+			expectErr: "1 of 2 activemq messages failed",
+		},
+		{
+			name: "Data isn't base64",
+			processMessage: func(ctx context.Context, message MQMessage[inputEvent]) error {
+				t.Error("should not be called")
+				return nil
+			},
+			event:     events.ActiveMQEvent{Messages: []events.ActiveMQMessage{{MessageID: "ID:1", Data: "{not base64}"}}},
+			expectErr: "1 of 1 activemq messages failed",
+		},
+		{
+			name: "Processor panics",
+			processMessage: func(ctx context.Context, message MQMessage[inputEvent]) error {
+				panic("something bad happened")
+			},
+			event:     events.ActiveMQEvent{Messages: []events.ActiveMQMessage{{MessageID: "ID:1", Data: "eyJGb28iOjF9"}}},
+			expectErr: "1 of 1 activemq messages failed",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+
+			handler := GetActiveMQHandler(tc.processMessage)
+			_, err := handler(ctx, tc.event)
+			if tc.expectErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectErr)
+			}
+		})
+	}
+}
+
+func TestGetRabbitMQHandler(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+
+	event := events.RabbitMQEvent{MessagesByQueue: map[string][]events.RabbitMQMessage{
+		"orders::/": {{
+			Data:            "eyJGb28iOjF9",
+			Redelivered:     true,
+			BasicProperties: events.RabbitMQBasicProperties{MessageID: aws.String("m-1"), Priority: 5, Headers: map[string]interface{}{"source": "test"}},
+		}},
+		"refunds::/": {{Data: "eyJGb28iOjJ9"}},
+	}}
+
+	handler := GetRabbitMQHandler(func(ctx context.Context, message MQMessage[inputEvent]) error {
+		if message.Destination == "orders::/" {
+			assert.Equal(t, 1, message.Payload.Foo)
+			assert.Equal(t, "m-1", message.MessageID)
+			assert.Equal(t, 5, message.Priority)
+			assert.True(t, message.Redelivered)
+			assert.Equal(t, map[string]interface{}{"source": "test"}, message.Properties)
+			return nil
+		}
+		return errors.New("something bad happened")
+	})
+	_, err := handler(ctx, event)
+	assert.EqualError(t, err, "1 of 2 rabbitmq messages failed")
+}
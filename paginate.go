@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"time"
+)
+
+// Page is a single page of results from a paginated API. Next is the cursor for the following page
+// (e.g. an S3 continuation token or a DynamoDB LastEvaluatedKey) and is only used if HasMore is true
+type Page[I interface{}, C interface{}] struct {
+	Items   []I
+	Next    C
+	HasMore bool
+}
+
+// PageFetcher fetches the page of results identified by cursor
+type PageFetcher[I interface{}, C interface{}] func(ctx context.Context, cursor C) (Page[I, C], error)
+
+// ItemProcessor is called for each item returned by a PageFetcher
+type ItemProcessor[I interface{}] func(ctx context.Context, item I) error
+
+// Paginate fetches pages starting at cursor and calls processItem for each item. Before each page is fetched the
+// remaining time before the context deadline is checked; if it is less than the time taken by the slowest page so far
+// plus the deadline margin (see WithTimeoutMargin), Paginate stops and returns the cursor for the next page with
+// complete set to false, so that the job can be resumed by a later invocation
+func Paginate[I interface{}, C interface{}](ctx context.Context, cursor C, fetchPage PageFetcher[I, C], processItem ItemProcessor[I]) (next C, complete bool, err error) {
+	clock := GetClock(ctx)
+	deadline, hasDeadline := ctx.Deadline()
+	var slowestPage time.Duration
+
+	for {
+		//Leave time for the slowest page and for returning (or saving) the cursor afterwards
+		if hasDeadline && deadline.Sub(clock.Now()) < slowestPage+getDeadlineMargin(ctx) {
+			GetLogger(ctx).Info("stopping pagination before deadline", "remainingMs", deadline.Sub(clock.Now()).Milliseconds())
+			return cursor, false, nil
+		}
+
+		start := clock.Now()
+		page, err := fetchPage(ctx, cursor)
+		if err != nil {
+			return cursor, false, err
+		}
+		for _, item := range page.Items {
+			err := processItem(ctx, item)
+			if err != nil {
+				//The page will be fetched again if the job is resumed from the returned cursor
+				return cursor, false, err
+			}
+		}
+		if elapsed := clock.Now().Sub(start); elapsed > slowestPage {
+			slowestPage = elapsed
+		}
+
+		if !page.HasMore {
+			return page.Next, true, nil
+		}
+		cursor = page.Next
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+
+	pages := map[int]Page[string, int]{
+		0: {Items: []string{"a", "b"}, Next: 1, HasMore: true},
+		1: {Items: []string{"c"}, Next: 2, HasMore: true},
+		2: {Items: []string{"d"}},
+	}
+
+	testcases := []struct {
+		name        string
+		timeout     time.Duration
+		margin      time.Duration
+		pageDelay   time.Duration
+		processItem ItemProcessor[string]
+		checkResult func(t *testing.T, items []string, next int, complete bool, err error)
+	}{
+		{
+			name:    "All pages processed",
+			timeout: 5 * time.Second,
+			checkResult: func(t *testing.T, items []string, next int, complete bool, err error) {
+				assert.Nil(t, err)
+				assert.True(t, complete)
+				assert.Equal(t, []string{"a", "b", "c", "d"}, items)
+			},
+		},
+		{
+			name:      "Stops before deadline",
+			timeout:   1200 * time.Millisecond,
+			pageDelay: 700 * time.Millisecond,
+			checkResult: func(t *testing.T, items []string, next int, complete bool, err error) {
+				assert.Nil(t, err)
+				assert.False(t, complete)
+				assert.Equal(t, 1, next)
+				assert.Equal(t, []string{"a", "b"}, items)
+			},
+		},
+		{
+			name:      "Stops before deadline margin",
+			timeout:   5 * time.Second,
+			margin:    4500 * time.Millisecond,
+			pageDelay: 300 * time.Millisecond,
+			checkResult: func(t *testing.T, items []string, next int, complete bool, err error) {
+				assert.Nil(t, err)
+				assert.False(t, complete)
+				assert.Equal(t, 1, next)
+				assert.Equal(t, []string{"a", "b"}, items)
+			},
+		},
+		{
+			name:    "Item processing fails",
+			timeout: 5 * time.Second,
+			processItem: func(ctx context.Context, item string) error {
+				if item == "c" {
+					return errors.New("something bad happened")
+				}
+				return nil
+			},
+			checkResult: func(t *testing.T, items []string, next int, complete bool, err error) {
+				assert.NotNil(t, err)
+				assert.False(t, complete)
+				assert.Equal(t, 1, next)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			if tc.margin > 0 {
+				ctx = context.WithValue(ctx, deadlineMarginKey, tc.margin)
+			}
+
+			items := []string{}
+			fetchPage := func(ctx context.Context, cursor int) (Page[string, int], error) {
+				time.Sleep(tc.pageDelay)
+				return pages[cursor], nil
+			}
+			processItem := func(ctx context.Context, item string) error {
+				items = append(items, item)
+				if tc.processItem != nil {
+					return tc.processItem(ctx, item)
+				}
+				return nil
+			}
+
+			next, complete, err := Paginate(ctx, 0, fetchPage, processItem)
+			tc.checkResult(t, items, next, complete, err)
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const maxWorkersKey = "maxWorkers"
+
+// runParallel calls process for each index in its own goroutine and returns the errors in index order (nil for
+// successes). It has no deadline or panic handling, so it is only for fan-out that isn't a batch of records (e.g.
+// shutdown hooks) - records are processed with processBatch
+func runParallel(ctx context.Context, count int, process func(ctx context.Context, i int) error) []error {
+	errs := make([]error, count)
+	wg := sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = process(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// processWithDeadline calls process for each record index using a pool of worker goroutines (see getMaxWorkers), with a
+// context whose deadline is the invocation deadline less the deadline margin. Records are started in index order. It
+// returns whether each record failed. Records that haven't finished by the deadline are reported as failed (and
+// onTimeout is called for them) so that the batch response can still be returned; records that haven't started by then
+// aren't processed. A record that panics is logged and reported as failed. The cancellation cause of the record context
+// is ErrDeadlineMarginReached or ErrBatchComplete. A record is counted in the DeadlineExceeded metric once, whether it
+// timed out here or returned a deadline error
+func processWithDeadline(ctx context.Context, count int, process func(ctx context.Context, i int) bool, onTimeout func(i int)) ([]bool, error) {
+	clock := GetClock(ctx)
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline {
+		return nil, errors.New("context must have a deadline set")
+	}
+	deadline = deadline.Add(-getDeadlineMargin(ctx))
+	//Records that are still running once the batch response is returned are cancelled with ErrBatchComplete
+	batchCtx, cancelBatch := context.WithCancelCause(ctx)
+	subCtx, cancel := context.WithDeadlineCause(batchCtx, deadline, ErrDeadlineMarginReached)
+	defer cancel()
+	defer cancelBatch(ErrBatchComplete)
+
+	indices := make(chan int, count)
+	for i := 0; i < count; i++ {
+		indices <- i
+	}
+	close(indices)
+	//Buffered so that workers which finish after the deadline don't block forever
+	results := make(chan recordResult, count)
+	deadlineCounted := make([]atomic.Bool, count)
+	recordContext := func(i int) context.Context {
+		return context.WithValue(subCtx, deadlineCountedKey, &deadlineCounted[i])
+	}
+	//The timer is started before the workers, so that it fires even if the clock moves past the deadline as they start
+	timer := clock.NewTimer(deadline.Sub(clock.Now()))
+	defer timer.Stop()
+	for w := 0; w < getMaxWorkers(ctx, count); w++ {
+		go func() {
+			for i := range indices {
+				if subCtx.Err() != nil {
+					//The record's time ran out before it started
+					results <- recordResult{index: i, success: false}
+					continue
+				}
+				results <- recordResult{index: i, success: processRecovered(ctx, recordContext(i), i, process)}
+			}
+		}()
+	}
+
+	finished := make([]bool, count)
+	failed := make([]bool, count)
+	collect := func(r recordResult) {
+		finished[r.index] = true
+		failed[r.index] = !r.success
+	}
+	for remaining := count; remaining > 0; remaining-- {
+		select {
+		case r := <-results:
+			collect(r)
+		case <-timer.C():
+			//Collect any results that arrived at the same time as the deadline
+			for len(results) > 0 {
+				collect(<-results)
+			}
+			for i := range finished {
+				if !finished[i] {
+					onTimeout(i)
+					emitDeadlineExceeded(recordContext(i))
+					failed[i] = true
+				}
+			}
+			return failed, nil
+		}
+	}
+	return failed, nil
+}
+
+// processRecovered calls process for the record, reporting a panic as a failure. A panic would otherwise crash the
+// runtime and fail the whole batch
+func processRecovered(ctx context.Context, subCtx context.Context, i int, process func(ctx context.Context, i int) bool) (success bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			GetLogger(ctx).Error("record processing panicked", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			EmitMetric(ctx, "Panic", 1, UnitCount, nil)
+			success = false
+		}
+	}()
+	return process(subCtx, i)
+}
+
+type recordResult struct {
+	index   int
+	success bool
+}
+
+// getMaxWorkers returns the number of workers used to process a batch of count records: the limit set on the context
+// (e.g. by the SQS WithMaxConcurrency option), otherwise the MAX_WORKERS environment variable, otherwise one per record
+func getMaxWorkers(ctx context.Context, count int) int {
+	workers, ok := ctx.Value(maxWorkersKey).(int)
+	if !ok {
+		workers, _ = strconv.Atoi(os.Getenv("MAX_WORKERS"))
+	}
+	if workers <= 0 {
+		return count
+	}
+	return min(workers, count)
+}
+
+// batchSource describes how processBatch processes the items of an event source
+type batchSource[R interface{}] struct {
+	//name identifies the items in log messages, e.g. "kinesis record"
+	name string
+	//logAttrs returns the log attributes identifying an item
+	logAttrs func(item R) []any
+	//failureLogAttrs returns extra log attributes for an item that failed, e.g. its masked body (optional)
+	failureLogAttrs func(item R) []any
+	//process processes an item, returning an error if it failed. It isn't used by processBatchOutcomes, which is given
+	//a process function that also returns a value
+	process func(ctx context.Context, item R) error
+}
+
+// processBatch processes the items of a batch event in parallel with processWithDeadline, and returns whether each item
+// failed. Each item's context has its own stages and a logger with the item's log attributes. An error caused by
+// running out of time is flagged as deadline exceeded, and failures and timeouts are logged
+func processBatch[R interface{}](ctx context.Context, items []R, source batchSource[R]) ([]bool, error) {
+	outcomes, err := processBatchOutcomes(ctx, items, source, func(ctx context.Context, item R) (struct{}, error) {
+		return struct{}{}, source.process(ctx, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+	failed := make([]bool, len(outcomes))
+	for i, outcome := range outcomes {
+		failed[i] = outcome.err != nil
+	}
+	return failed, nil
+}
+
+// batchOutcome is the value returned by processing an item of a batch, or the error it failed with
+type batchOutcome[V interface{}] struct {
+	value V
+	err   error
+}
+
+// processBatchOutcomes is like processBatch, but returns the outcome of each item. Items that didn't finish before the
+// deadline margin fail with a DeadlineExceeded error, and items that panicked fail with a non-retryable "panic: ..." error. Items that
+// finish after they've timed out don't change their outcome
+func processBatchOutcomes[R interface{}, V interface{}](ctx context.Context, items []R, source batchSource[R], process func(ctx context.Context, item R) (V, error)) ([]batchOutcome[V], error) {
+	mu := sync.Mutex{}
+	outcomes := make([]*batchOutcome[V], len(items))
+	record := func(i int, outcome *batchOutcome[V], override bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if outcomes[i] == nil || override {
+			outcomes[i] = outcome
+		}
+	}
+	timedOut := func() *batchOutcome[V] {
+		return &batchOutcome[V]{err: NewHandlerError(ErrorCodeDeadlineExceeded, ErrDeadlineMarginReached)}
+	}
+
+	_, err := processWithDeadline(ctx, len(items), func(ctx context.Context, i int) bool {
+		defer func() {
+			if r := recover(); r != nil {
+				record(i, &batchOutcome[V]{err: NonRetryable(fmt.Errorf("panic: %v", r))}, false)
+				panic(r)
+			}
+		}()
+		outcome := processBatchItem(ctx, items[i], source, process)
+		record(i, outcome, false)
+		return outcome.err == nil
+	}, func(i int) {
+		//The item may have finished since the deadline, but it's reported as timed-out by processWithDeadline
+		record(i, timedOut(), true)
+		GetLogger(ctx).Error(source.name+" processing timed-out", source.logAttrs(items[i])...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	result := make([]batchOutcome[V], len(items))
+	for i, outcome := range outcomes {
+		if outcome == nil {
+			//The item's time ran out before it started
+			outcome = timedOut()
+		}
+		result[i] = *outcome
+	}
+	return result, nil
+}
+
+// processBatchItem processes an item of a batch with its own stages and logger, flagging an error caused by running out
+// of time as deadline exceeded and logging a failure
+func processBatchItem[R interface{}, V interface{}](ctx context.Context, item R, source batchSource[R], process func(ctx context.Context, item R) (V, error)) *batchOutcome[V] {
+	ctx = ContextWithStages(ctx)
+	ctx = GetNewContextWithLogger(ctx, GetLogger(ctx).With(source.logAttrs(item)...))
+	defer trackRecord(ctx, source.logAttrs(item)...)()
+
+	value, err := process(ctx, item)
+	err = withCancelCause(ctx, err)
+	if IsDeadlineExceeded(ctx, err) {
+		err = flagDeadlineExceeded(ctx, err)
+	}
+	if err != nil {
+		attrs := []any{"errStr", err.Error(), "errObj", err, "stages", getStagesLogValue(ctx)}
+		if source.failureLogAttrs != nil {
+			attrs = append(attrs, source.failureLogAttrs(item)...)
+		}
+		GetLogger(ctx).Error(source.name+" processing failed", attrs...)
+	}
+	return &batchOutcome[V]{value: value, err: err}
+}
+
+// getBatchItemFailures returns the (validated) identifiers of the failed items of a batch, for a partial batch response
+func getBatchItemFailures(ctx context.Context, ids []string, failed []bool) []string {
+	failedIDs := []string{}
+	for i, id := range ids {
+		if failed[i] {
+			failedIDs = append(failedIDs, id)
+		}
+	}
+	return validateItemIdentifiers(ctx, failedIDs, ids)
+}
+
+// countFailed returns the number of items that failed
+func countFailed(failed []bool) int {
+	count := 0
+	for _, f := range failed {
+		if f {
+			count++
+		}
+	}
+	return count
+}
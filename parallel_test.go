@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunParallel(t *testing.T) {
+	mu := sync.Mutex{}
+	seen := map[int]bool{}
+	errs := runParallel(context.Background(), 3, func(ctx context.Context, i int) error {
+		mu.Lock()
+		seen[i] = true
+		mu.Unlock()
+		if i == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	assert.Equal(t, map[int]bool{0: true, 1: true, 2: true}, seen)
+	assert.Equal(t, []error{nil, errors.New("failed"), nil}, errs)
+}
+
+func TestProcessWithDeadline(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	clock := NewFakeClock(time.Now())
+	ctx = ContextWithClock(ctx, clock)
+	//Process the records one at a time, so the other records have finished before the last one times out
+	ctx = context.WithValue(ctx, maxWorkersKey, 1)
+
+	mu := sync.Mutex{}
+	timedOut := []int{}
+	failed, err := processWithDeadline(ctx, 3, func(ctx context.Context, i int) bool {
+		if i == 2 {
+			clock.Advance(10 * time.Second)
+			<-ctx.Done()
+		}
+		return i != 1
+	}, func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		timedOut = append(timedOut, i)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2}, timedOut)
+	assert.Equal(t, []bool{false, true, true}, failed)
+
+	_, err = processWithDeadline(context.Background(), 1, func(ctx context.Context, i int) bool { return true }, func(i int) {})
+	assert.EqualError(t, err, "context must have a deadline set")
+}
+
+func TestProcessWithDeadlineWorkers(t *testing.T) {
+	testcases := []struct {
+		name       string
+		setWorkers func(t *testing.T, ctx context.Context) context.Context
+		expected   int
+	}{
+		{
+			name:       "One worker per record by default",
+			setWorkers: func(t *testing.T, ctx context.Context) context.Context { return ctx },
+			expected:   10,
+		},
+		{
+			name: "Environment variable",
+			setWorkers: func(t *testing.T, ctx context.Context) context.Context {
+				t.Setenv("MAX_WORKERS", "4")
+				return ctx
+			},
+			expected: 4,
+		},
+		{
+			name: "Context overrides environment variable",
+			setWorkers: func(t *testing.T, ctx context.Context) context.Context {
+				t.Setenv("MAX_WORKERS", "4")
+				return context.WithValue(ctx, maxWorkersKey, 2)
+			},
+			expected: 2,
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			ctx = tc.setWorkers(t, ctx)
+
+			mu := sync.Mutex{}
+			running, maxRunning := 0, 0
+			failed, err := processWithDeadline(ctx, 10, func(ctx context.Context, i int) bool {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return true
+			}, func(i int) {})
+			assert.Nil(t, err)
+			assert.Equal(t, make([]bool, 10), failed)
+			assert.Equal(t, tc.expected, maxRunning)
+		})
+	}
+}
+
+func TestProcessWithDeadlinePanic(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+
+	failed, err := processWithDeadline(ctx, 2, func(ctx context.Context, i int) bool {
+		if i == 0 {
+			panic("something bad happened")
+		}
+		return true
+	}, func(i int) {})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false}, failed)
+}
+
+func TestProcessBatch(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(2*time.Second))
+	defer cancel()
+
+	failed, err := processBatch(ctx, []string{"a", "b"}, batchSource[string]{
+		name: "test item",
+		logAttrs: func(item string) []any {
+			return []any{"item", item}
+		},
+		process: func(ctx context.Context, item string) error {
+			AddStage(ctx, "process "+item)
+			if item == "b" {
+				return errors.New("oops")
+			}
+			return nil
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false, true}, failed)
+
+	line := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "test item processing failed", line["msg"])
+	assert.Equal(t, "b", line["item"])
+	assert.Equal(t, []interface{}{"process b"}, line["stages"])
+}
+
+func TestProcessBatchDeadlineExceededMetric(t *testing.T) {
+	t.Setenv("METRIC_NAMESPACE", "MyService")
+	metrics := &lockedBuffer{}
+	original := metricsWriter
+	metricsWriter = metrics
+	t.Cleanup(func() {
+		metricsWriter = original
+	})
+	logs := &lockedBuffer{}
+	ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(logs, nil)))
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(deadlineMargin+100*time.Millisecond))
+	defer cancel()
+
+	failed, err := processBatch(ctx, []string{"a"}, batchSource[string]{
+		name: "test item",
+		logAttrs: func(item string) []any {
+			return []any{"item", item}
+		},
+		process: func(ctx context.Context, item string) error {
+			<-ctx.Done()
+			//Finish with a deadline error after the batch has timed out the item
+			time.Sleep(50 * time.Millisecond)
+			return fmt.Errorf("get object: %w", context.DeadlineExceeded)
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, failed)
+
+	//The metric is emitted before the failure is logged
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "test item processing failed")
+	}, time.Second, 10*time.Millisecond)
+	count := 0
+	for _, line := range strings.Split(strings.TrimSpace(metrics.String()), "\n") {
+		entry := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		if _, ok := entry["DeadlineExceeded"]; ok {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+}
+
+// lockedBuffer is a bytes.Buffer that can be written by goroutines that outlive the code under test
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestGetBatchItemFailures(t *testing.T) {
+	ids := []string{"1", "2", "3", "2"}
+	assert.Equal(t, []string{"2", "3"}, getBatchItemFailures(context.Background(), ids, []bool{false, true, true, true}))
+	assert.Equal(t, []string{}, getBatchItemFailures(context.Background(), ids, []bool{false, false, false, false}))
+	assert.Equal(t, 3, countFailed([]bool{false, true, true, true}))
+}
+
+func TestProcessBatchOutcomes(t *testing.T) {
+	buf := &lockedBuffer{}
+	ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(2*time.Second))
+	defer cancel()
+
+	outcomes, err := processBatchOutcomes(ctx, []string{"a", "b", "c"}, batchSource[string]{
+		name: "test item",
+		logAttrs: func(item string) []any {
+			return []any{"item", item}
+		},
+	}, func(ctx context.Context, item string) (string, error) {
+		switch item {
+		case "b":
+			return "", fmt.Errorf("call api: %w", context.DeadlineExceeded)
+		case "c":
+			panic("something bad happened")
+		}
+		return strings.ToUpper(item), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "A", outcomes[0].value)
+	assert.Nil(t, outcomes[0].err)
+	assert.Equal(t, ErrorCodeDeadlineExceeded, GetErrorCode(outcomes[1].err))
+	assert.EqualError(t, outcomes[2].err, "panic: something bad happened")
+	assert.True(t, IsNonRetryable(outcomes[2].err))
+
+	//The deadline error is only flagged once
+	stages := []interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		entry := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == "test item processing failed" && entry["item"] == "b" {
+			stages = entry["stages"].([]interface{})
+		}
+	}
+	assert.Equal(t, []interface{}{"deadline exceeded"}, stages)
+}
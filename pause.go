@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// ErrorCodePaused is the HandlerError code returned while the handler is paused
+const ErrorCodePaused = "HandlerPaused"
+
+// PauseSignal reports whether operators have paused processing
+type PauseSignal func(ctx context.Context) (bool, error)
+
+// SSMGetParameterAPI is the subset of the SSM client used to read parameters
+type SSMGetParameterAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// DynamoDBGetItemAPI is the subset of the DynamoDB client used to read single items
+type DynamoDBGetItemAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+}
+
+// NewSSMPauseSignal returns a PauseSignal that reports paused while the SSM parameter's value is "true". The value is
+// cached for ttl so that busy functions don't hit the SSM rate limit
+func NewSSMPauseSignal(client SSMGetParameterAPI, name string, ttl time.Duration) PauseSignal {
+	return cachePauseSignal(ttl, func(ctx context.Context) (bool, error) {
+		output, err := client.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name)})
+		if err != nil {
+			return false, err
+		}
+		return strconv.ParseBool(aws.ToString(output.Parameter.Value))
+	})
+}
+
+// NewDynamoDBPauseSignal returns a PauseSignal that reports paused while the item with the string partition key "id"
+// has a "paused" attribute set to true. The value is cached for ttl
+func NewDynamoDBPauseSignal(client DynamoDBGetItemAPI, table string, id string, ttl time.Duration) PauseSignal {
+	return cachePauseSignal(ttl, func(ctx context.Context) (bool, error) {
+		output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(table),
+			Key:       map[string]ddbtypes.AttributeValue{"id": &ddbtypes.AttributeValueMemberS{Value: id}},
+		})
+		if err != nil {
+			return false, err
+		}
+		paused, ok := output.Item["paused"].(*ddbtypes.AttributeValueMemberBOOL)
+		return ok && paused.Value, nil
+	})
+}
+
+func cachePauseSignal(ttl time.Duration, signal PauseSignal) PauseSignal {
+	mu := sync.Mutex{}
+	var paused bool
+	var fetched time.Time
+
+	return func(ctx context.Context) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := GetClock(ctx).Now()
+		if !fetched.IsZero() && now.Sub(fetched) < ttl {
+			return paused, nil
+		}
+		value, err := signal(ctx)
+		if err != nil {
+			return false, err
+		}
+		paused = value
+		fetched = now
+		return paused, nil
+	}
+}
+
+// WithPauseSignal checks the signal before each invocation and, while paused, returns a HandlerError with code
+// ErrorCodePaused without calling the handler. The failed invocation is retried by Lambda (async invokes) or the
+// batch is returned to the queue (event source mappings). If the signal can't be read the handler runs as normal
+func WithPauseSignal[T interface{}, U interface{}](signal PauseSignal, handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		paused, err := signal(ctx)
+		if err != nil {
+			GetLogger(ctx).Error("failed to read pause signal", "error", err.Error())
+		}
+		if paused {
+			GetLogger(ctx).Warn("handler is paused - returning event for retry")
+			var zero U
+			return zero, NewHandlerError(ErrorCodePaused, errors.New("handler is paused"))
+		}
+		return handlerFunc(ctx, event)
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPauseSignal(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		value       string
+		err         error
+		checkResult func(t *testing.T, called bool, err error)
+	}{
+		{
+			name:  "Not paused",
+			value: "false",
+			checkResult: func(t *testing.T, called bool, err error) {
+				assert.Nil(t, err)
+				assert.True(t, called)
+			},
+		},
+		{
+			name:  "Paused",
+			value: "true",
+			checkResult: func(t *testing.T, called bool, err error) {
+				assert.Equal(t, ErrorCodePaused, GetErrorCode(err))
+				assert.False(t, called)
+			},
+		},
+		{
+			name: "Signal unavailable",
+			err:  errors.New("throttled"),
+			checkResult: func(t *testing.T, called bool, err error) {
+				assert.Nil(t, err)
+				assert.True(t, called)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSSMClient{value: tc.value, err: tc.err}
+			signal := NewSSMPauseSignal(client, "/my-function/paused", time.Minute)
+
+			called := false
+			h := WithPauseSignal(signal, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				called = true
+				return outputEvent{}, nil
+			})
+			_, err := h(context.Background(), inputEvent{})
+			tc.checkResult(t, called, err)
+
+			//The second invocation should use the cached value
+			_, _ = h(context.Background(), inputEvent{})
+			if tc.err == nil {
+				assert.Equal(t, 1, client.calls)
+			}
+		})
+	}
+}
+
+type mockSSMClient struct {
+	value string
+	err   error
+	calls int
+}
+
+func (m *mockSSMClient) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &ssm.GetParameterOutput{Parameter: &types.Parameter{Value: aws.String(m.value)}}, nil
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// WithProfiling records a CPU profile of every Nth invocation (or of every invocation if PROFILE_ALL_INVOCATIONS is
+// "true") and uploads it to S3 under <prefix>profiles/<function name>/<date>/<request ID>.cpu.pprof, so that hot
+// functions can be profiled in production. everyN less than 1 disables sampling, leaving only the environment flag
+func WithProfiling[T interface{}, U interface{}](client S3PutObjectAPI, bucket string, prefix string, everyN int, handlerFunc Handler[T, U]) Handler[T, U] {
+	var invocations atomic.Int64
+	return func(ctx context.Context, event T) (U, error) {
+		count := invocations.Add(1)
+		profile := os.Getenv("PROFILE_ALL_INVOCATIONS") == "true" || (everyN > 0 && count%int64(everyN) == 0)
+		if !profile {
+			return handlerFunc(ctx, event)
+		}
+
+		buf := &bytes.Buffer{}
+		//Only one CPU profile can run at a time, so concurrent invocations (e.g. in local mode) are not profiled
+		if err := pprof.StartCPUProfile(buf); err != nil {
+			GetLogger(ctx).Warn("failed to start cpu profile", "error", err.Error())
+			return handlerFunc(ctx, event)
+		}
+		response, err := handlerFunc(ctx, event)
+		pprof.StopCPUProfile()
+
+		uploadErr := uploadProfile(ctx, client, bucket, prefix, buf)
+		if uploadErr != nil {
+			GetLogger(ctx).Error("failed to upload cpu profile", "error", uploadErr.Error())
+		}
+		return response, err
+	}
+}
+
+func uploadProfile(ctx context.Context, client S3PutObjectAPI, bucket string, prefix string, profile *bytes.Buffer) error {
+	functionName := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if functionName == "" {
+		functionName = "local"
+	}
+	key := fmt.Sprintf("%sprofiles/%s/%s/%s.cpu.pprof", prefix, functionName, GetClock(ctx).Now().UTC().Format("2006-01-02"), getRequestID(ctx))
+
+	//The invocation may have used all of its time, so don't use the handler's context
+	ctx, cancel := withReportTimeout(ctx)
+	defer cancel()
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(profile.Bytes()),
+		ContentType: aws.String("application/octet-stream"),
+	})
+	if err != nil {
+		return err
+	}
+	GetLogger(ctx).Info("uploaded cpu profile", "bucket", bucket, "key", key)
+	return nil
+}
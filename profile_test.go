@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProfiling(t *testing.T) {
+
+	testcases := []struct {
+		name         string
+		everyN       int
+		allEnv       string
+		expectUpload []bool
+	}{
+		{
+			name:         "Every second invocation profiled",
+			everyN:       2,
+			expectUpload: []bool{false, true, false, true},
+		},
+		{
+			name:         "Profiling disabled",
+			expectUpload: []bool{false, false},
+		},
+		{
+			name:         "All invocations profiled",
+			allEnv:       "true",
+			expectUpload: []bool{true, true},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("PROFILE_ALL_INVOCATIONS", tc.allEnv)
+			t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "my-function")
+
+			client := &mockS3PutClient{}
+			h := WithProfiling(client, "profile-bucket", "", tc.everyN, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				return outputEvent{Bar: event.Foo}, nil
+			})
+			ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "request-1"})
+
+			for _, expectUpload := range tc.expectUpload {
+				client.input = nil
+				output, err := h(ctx, inputEvent{Foo: 3})
+				assert.Nil(t, err)
+				assert.Equal(t, outputEvent{Bar: 3}, output)
+				if !expectUpload {
+					assert.Nil(t, client.input)
+					continue
+				}
+				assert.Equal(t, "profile-bucket", aws.ToString(client.input.Bucket))
+				assert.Regexp(t, `^profiles/my-function/\d{4}-\d{2}-\d{2}/request-1\.cpu\.pprof$`, aws.ToString(client.input.Key))
+				assert.NotEmpty(t, client.body)
+				assert.WithinDuration(t, time.Now().Add(deadlineMargin), client.deadline, time.Second)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const queueDepthKey = "queueDepth"
+
+// SQSGetQueueAttributesAPI is the subset of the SQS client used to read queue attributes
+type SQSGetQueueAttributesAPI interface {
+	GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+}
+
+// WithQueueDepth fetches ApproximateNumberOfMessages for the source queue before each batch, so that the record
+// processor can adapt under backlog pressure (e.g. skip expensive enrichment). The value is cached for ttl, made
+// available with GetQueueDepth and emitted as the QueueDepth metric. If it can't be fetched the batch is processed
+// without it
+func WithQueueDepth(client SQSGetQueueAttributesAPI, ttl time.Duration) SQSOption {
+	type cached struct {
+		depth   int
+		fetched time.Time
+	}
+	mu := sync.Mutex{}
+	cache := map[string]cached{}
+
+	return func(o *sqsOptions) {
+		o.queueDepth = func(ctx context.Context, queueARN string) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			now := GetClock(ctx).Now()
+			if c, ok := cache[queueARN]; ok && now.Sub(c.fetched) < ttl {
+				return c.depth, nil
+			}
+			depth, err := fetchQueueDepth(ctx, client, queueARN)
+			if err != nil {
+				return 0, err
+			}
+			cache[queueARN] = cached{depth: depth, fetched: now}
+			EmitMetric(ctx, "QueueDepth", float64(depth), UnitCount, map[string]string{"QueueName": getQueueName(queueARN)})
+			return depth, nil
+		}
+	}
+}
+
+// GetQueueDepth returns the approximate number of messages on the source queue, if the handler was created with
+// WithQueueDepth and the value could be fetched
+func GetQueueDepth(ctx context.Context) (int, bool) {
+	depth, ok := ctx.Value(queueDepthKey).(int)
+	return depth, ok
+}
+
+func fetchQueueDepth(ctx context.Context, client SQSGetQueueAttributesAPI, queueARN string) (int, error) {
+	queueURL, err := getQueueURL(queueARN)
+	if err != nil {
+		return 0, err
+	}
+	output, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueURL),
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(output.Attributes[string(sqstypes.QueueAttributeNameApproximateNumberOfMessages)])
+}
+
+// sqsDomains are the DNS suffixes of the SQS endpoints in each AWS partition
+var sqsDomains = map[string]string{
+	"aws":        "amazonaws.com",
+	"aws-cn":     "amazonaws.com.cn",
+	"aws-us-gov": "amazonaws.com",
+	"aws-iso":    "c2s.ic.gov",
+	"aws-iso-b":  "sc2s.sgov.gov",
+	"aws-iso-e":  "cloud.adc-e.uk",
+	"aws-iso-f":  "csp.hci.ic.gov",
+}
+
+// getQueueURL converts a queue ARN (arn:<partition>:sqs:<region>:<account>:<name>) to its URL, using the endpoint
+// domain of the ARN's partition. The URL only identifies the queue, so it also works with clients that use VPC or FIPS
+// endpoints
+func getQueueURL(queueARN string) (string, error) {
+	parts := strings.Split(queueARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sqs" {
+		return "", fmt.Errorf("invalid sqs queue arn '%s'", queueARN)
+	}
+	domain, ok := sqsDomains[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("unknown partition in sqs queue arn '%s'", queueARN)
+	}
+	return fmt.Sprintf("https://sqs.%s.%s/%s/%s", parts[3], domain, parts[4], parts[5]), nil
+}
+
+func getQueueName(queueARN string) string {
+	return queueARN[strings.LastIndex(queueARN, ":")+1:]
+}
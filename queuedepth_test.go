@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithQueueDepth(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		queueARN    string
+		err         error
+		checkResult func(t *testing.T, client *mockSQSAttributesClient, depths []int, found []bool)
+	}{
+		{
+			name:     "Queue depth fetched and cached",
+			queueARN: "arn:aws:sqs:eu-west-2:123456789012:orders",
+			checkResult: func(t *testing.T, client *mockSQSAttributesClient, depths []int, found []bool) {
+				assert.Equal(t, []int{42, 42}, depths)
+				assert.Equal(t, []bool{true, true}, found)
+				assert.Equal(t, 1, client.calls)
+				assert.Equal(t, "https://sqs.eu-west-2.amazonaws.com/123456789012/orders", aws.ToString(client.input.QueueUrl))
+			},
+		},
+		{
+			name:     "Fetch failure ignored",
+			queueARN: "arn:aws:sqs:eu-west-2:123456789012:orders",
+			err:      errors.New("access denied"),
+			checkResult: func(t *testing.T, client *mockSQSAttributesClient, depths []int, found []bool) {
+				assert.Equal(t, []bool{false, false}, found)
+				assert.Equal(t, 2, client.calls)
+			},
+		},
+		{
+			name:     "Invalid queue ARN",
+			queueARN: "not-an-arn",
+			checkResult: func(t *testing.T, client *mockSQSAttributesClient, depths []int, found []bool) {
+				assert.Equal(t, []bool{false, false}, found)
+				assert.Equal(t, 0, client.calls)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSQSAttributesClient{depth: "42", err: tc.err}
+			depths := []int{}
+			found := []bool{}
+			h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+				depth, ok := GetQueueDepth(ctx)
+				depths = append(depths, depth)
+				found = append(found, ok)
+				return nil
+			}, WithQueueDepth(client, time.Minute))
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			event := events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1", EventSourceARN: tc.queueARN}}}
+			for i := 0; i < 2; i++ {
+				_, err := h(ctx, event)
+				assert.Nil(t, err)
+			}
+			tc.checkResult(t, client, depths, found)
+		})
+	}
+}
+
+type mockSQSAttributesClient struct {
+	input *sqs.GetQueueAttributesInput
+	calls int
+	depth string
+	err   error
+}
+
+func (m *mockSQSAttributesClient) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	m.input = params
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{"ApproximateNumberOfMessages": m.depth}}, nil
+}
+
+func TestGetQueueURL(t *testing.T) {
+	testcases := []struct {
+		arn       string
+		expected  string
+		expectErr bool
+	}{
+		{arn: "arn:aws:sqs:eu-west-2:123456789012:orders", expected: "https://sqs.eu-west-2.amazonaws.com/123456789012/orders"},
+		{arn: "arn:aws-cn:sqs:cn-north-1:123456789012:orders", expected: "https://sqs.cn-north-1.amazonaws.com.cn/123456789012/orders"},
+		{arn: "arn:aws-us-gov:sqs:us-gov-west-1:123456789012:orders", expected: "https://sqs.us-gov-west-1.amazonaws.com/123456789012/orders"},
+		{arn: "arn:aws-iso:sqs:us-iso-east-1:123456789012:orders", expected: "https://sqs.us-iso-east-1.c2s.ic.gov/123456789012/orders"},
+		{arn: "arn:other:sqs:eu-west-2:123456789012:orders", expectErr: true},
+		{arn: "https://sqs.eu-west-2.amazonaws.com/123456789012/orders", expectErr: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.arn, func(t *testing.T) {
+			url, err := getQueueURL(tc.arn)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, url)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+)
+
+const randKey = "rand"
+
+type invocationRand struct {
+	seed uint64
+	rand *rand.Rand
+	//explicit is true if the seed was set with ContextWithRand rather than chosen by WithLogger
+	explicit bool
+}
+
+// lockedSource makes a PCG source safe to share between the goroutines of an invocation
+type lockedSource struct {
+	mu  sync.Mutex
+	src *rand.PCG
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+// ContextWithRand attaches a random number generator with the given seed to the context. WithLogger attaches one with a
+// random seed, and logs the seed when the invocation fails, unless the context already has one - so a test can replay
+// a failed invocation by passing the logged seed
+func ContextWithRand(ctx context.Context, seed uint64) context.Context {
+	return contextWithRand(ctx, seed, true)
+}
+
+func contextWithRand(ctx context.Context, seed uint64, explicit bool) context.Context {
+	r := rand.New(&lockedSource{src: rand.NewPCG(seed, seed)})
+	return context.WithValue(ctx, randKey, &invocationRand{seed: seed, rand: r, explicit: explicit})
+}
+
+// GetRand returns the invocation's random number generator, which is safe for concurrent use. Outside an invocation it
+// returns a randomly seeded generator
+func GetRand(ctx context.Context) *rand.Rand {
+	val := ctx.Value(randKey)
+	if val != nil {
+		return val.(*invocationRand).rand
+	}
+	return rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+}
+
+// ensureRand attaches a randomly seeded generator to the context if it doesn't already have one
+func ensureRand(ctx context.Context) context.Context {
+	if ctx.Value(randKey) != nil {
+		return ctx
+	}
+	return contextWithRand(ctx, rand.Uint64(), false)
+}
+
+// getExplicitRand returns the generator set on the context with ContextWithRand, or false if there isn't one (the
+// generator attached by WithLogger doesn't count, as its seed is logged)
+func getExplicitRand(ctx context.Context) (*rand.Rand, bool) {
+	val, ok := ctx.Value(randKey).(*invocationRand)
+	if !ok || !val.explicit {
+		return nil, false
+	}
+	return val.rand, true
+}
+
+func getRandSeed(ctx context.Context) uint64 {
+	val := ctx.Value(randKey)
+	if val != nil {
+		return val.(*invocationRand).seed
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRand(t *testing.T) {
+	values := func(ctx context.Context) []int {
+		r := GetRand(ctx)
+		return []int{r.IntN(1000), r.IntN(1000), r.IntN(1000)}
+	}
+
+	//The same seed gives the same values
+	assert.Equal(t, values(ContextWithRand(context.Background(), 42)), values(ContextWithRand(context.Background(), 42)))
+
+	//WithLogger keeps a generator that is already on the context, so an invocation can be replayed from its seed
+	var replayed []int
+	h := WithLogger(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		replayed = values(ctx)
+		assert.Equal(t, uint64(42), getRandSeed(ctx))
+		return outputEvent{}, nil
+	})
+	_, err := h(ContextWithRand(context.Background(), 42), inputEvent{})
+	assert.Nil(t, err)
+	assert.Equal(t, values(ContextWithRand(context.Background(), 42)), replayed)
+
+	//Otherwise each invocation gets its own seed
+	seeds := []uint64{}
+	h = WithLogger(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		seeds = append(seeds, getRandSeed(ctx))
+		return outputEvent{}, nil
+	})
+	_, _ = h(context.Background(), inputEvent{})
+	_, _ = h(context.Background(), inputEvent{})
+	assert.NotEqual(t, seeds[0], seeds[1])
+}
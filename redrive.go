@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// RedriveReceiveCountAttribute is set on redriven messages to the number of times the message was received before it
+// was moved to the dead-letter queue. It isn't set if the message already has the maximum number of attributes
+const RedriveReceiveCountAttribute = "RedriveReceiveCount"
+
+// SQSSendMessageAPI is the subset of the SQS client used to send messages
+type SQSSendMessageAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// SQSRedriveAPI is the subset of the SQS client used to poll and redrive a dead-letter queue
+type SQSRedriveAPI interface {
+	SQSSendMessageAPI
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// RedriveTransform can modify the body of a message before it is redriven. Returning false for keep drops the message
+type RedriveTransform func(ctx context.Context, record events.SQSMessage) (body string, keep bool, err error)
+
+type RedriveOptions struct {
+	// TargetQueueURL is the queue that messages are sent back to
+	TargetQueueURL string
+	// Transform is optional
+	Transform RedriveTransform
+	// MessagesPerSecond limits the rate that messages are sent to the target queue, so that the consumer isn't flooded.
+	// Zero means no limit
+	MessagesPerSecond float64
+}
+
+// GetSQSRedriveProcessor returns an SQSRecordProcessor that sends each record to the target queue. Use it with
+// GetSQSHandler for a function that has the dead-letter queue as its event source
+func GetSQSRedriveProcessor(client SQSSendMessageAPI, options RedriveOptions) SQSRecordProcessor {
+	limiter := newRateLimiter(options.MessagesPerSecond)
+	return func(ctx context.Context, record events.SQSMessage) error {
+		return redriveMessage(ctx, client, options, limiter, record)
+	}
+}
+
+// RedriveFromQueue polls the dead-letter queue and sends its messages to the target queue until the queue is empty or
+// the context deadline approaches. It returns the number of messages redriven
+func RedriveFromQueue(ctx context.Context, client SQSRedriveAPI, dlqURL string, options RedriveOptions) (int, error) {
+	limiter := newRateLimiter(options.MessagesPerSecond)
+	deadline, hasDeadline := ctx.Deadline()
+	count := 0
+
+	for {
+		//Allow time for the visibility timeout to be respected and the final batch to be deleted
+		if hasDeadline && deadline.Sub(GetClock(ctx).Now()) < 5*time.Second {
+			GetLogger(ctx).Info("stopping redrive before deadline", "redriven", count)
+			return count, nil
+		}
+
+		output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(dlqURL),
+			MaxNumberOfMessages:         10,
+			WaitTimeSeconds:             1,
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{sqstypes.MessageSystemAttributeNameAll},
+		})
+		if err != nil {
+			return count, err
+		}
+		if len(output.Messages) == 0 {
+			GetLogger(ctx).Info("dead-letter queue is empty", "redriven", count)
+			return count, nil
+		}
+
+		for _, message := range output.Messages {
+			err := redriveMessage(ctx, client, options, limiter, toSQSEventMessage(message))
+			if err != nil {
+				return count, err
+			}
+			_, err = client.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(dlqURL), ReceiptHandle: message.ReceiptHandle})
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+func redriveMessage(ctx context.Context, client SQSSendMessageAPI, options RedriveOptions, limiter *rateLimiter, record events.SQSMessage) error {
+	body := record.Body
+	if options.Transform != nil {
+		transformed, keep, err := options.Transform(ctx, record)
+		if err != nil {
+			return StageErr(ctx, "transform message", err)
+		}
+		if !keep {
+			GetLogger(ctx).Info("dropping message from dead-letter queue", "messageId", record.MessageId)
+			return nil
+		}
+		body = transformed
+	}
+
+	attributes := toSQSMessageAttributes(record.MessageAttributes)
+	if _, err := strconv.Atoi(record.Attributes["ApproximateReceiveCount"]); err == nil {
+		_, replace := attributes[RedriveReceiveCountAttribute]
+		if replace || len(attributes) < maxSQSMessageAttributes {
+			attributes[RedriveReceiveCountAttribute] = sqstypes.MessageAttributeValue{
+				DataType:    aws.String("Number"),
+				StringValue: aws.String(record.Attributes["ApproximateReceiveCount"]),
+			}
+		} else {
+			GetLogger(ctx).Warn("not adding redrive receive count to stay within the sqs attribute limit", "messageId", record.MessageId)
+		}
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(options.TargetQueueURL),
+		MessageBody:       aws.String(body),
+		MessageAttributes: attributes,
+	}
+	//FIFO queues require the group ID
+	if groupID := record.Attributes["MessageGroupId"]; groupID != "" {
+		input.MessageGroupId = aws.String(groupID)
+		input.MessageDeduplicationId = aws.String(record.MessageId)
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+	_, err := client.SendMessage(ctx, input)
+	if err != nil {
+		return StageErr(ctx, "redrive message", err)
+	}
+	AddStage(ctx, "redrive message")
+	return nil
+}
+
+func toSQSMessageAttributes(attributes map[string]events.SQSMessageAttribute) map[string]sqstypes.MessageAttributeValue {
+	converted := make(map[string]sqstypes.MessageAttributeValue, len(attributes))
+	for name, attribute := range attributes {
+		converted[name] = sqstypes.MessageAttributeValue{
+			DataType:    aws.String(attribute.DataType),
+			StringValue: attribute.StringValue,
+			BinaryValue: attribute.BinaryValue,
+		}
+	}
+	return converted
+}
+
+// toSQSEventMessage converts a received message into an event record. The record has no EventSourceARN, as only the
+// queue URL is known
+func toSQSEventMessage(message sqstypes.Message) events.SQSMessage {
+	record := events.SQSMessage{
+		MessageId:         aws.ToString(message.MessageId),
+		ReceiptHandle:     aws.ToString(message.ReceiptHandle),
+		Body:              aws.ToString(message.Body),
+		Md5OfBody:         aws.ToString(message.MD5OfBody),
+		Attributes:        message.Attributes,
+		MessageAttributes: map[string]events.SQSMessageAttribute{},
+		EventSource:       "aws:sqs",
+	}
+	for name, attribute := range message.MessageAttributes {
+		record.MessageAttributes[name] = events.SQSMessageAttribute{
+			DataType:    aws.ToString(attribute.DataType),
+			StringValue: attribute.StringValue,
+			BinaryValue: attribute.BinaryValue,
+		}
+	}
+	return record
+}
+
+// rateLimiter spaces out calls so that there are no more than the given number per second
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the next call is allowed, returning an error if the context is done first
+func (r *rateLimiter) Wait(ctx context.Context) error {
+	if r.interval == 0 {
+		return nil
+	}
+	clock := GetClock(ctx)
+	r.mu.Lock()
+	now := clock.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	timer := clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
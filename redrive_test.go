@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSQSRedriveProcessor(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		record      events.SQSMessage
+		transform   RedriveTransform
+		sendErr     error
+		expectErr   bool
+		checkResult func(t *testing.T, client *mockSQSClient)
+	}{
+		{
+			name: "Message redriven with receive count",
+			record: events.SQSMessage{
+				MessageId:         "message-1",
+				Body:              "hello",
+				Attributes:        map[string]string{"ApproximateReceiveCount": "3"},
+				MessageAttributes: map[string]events.SQSMessageAttribute{"Foo": {DataType: "String", StringValue: aws.String("bar")}},
+			},
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				assert.Len(t, client.sent, 1)
+				input := client.sent[0]
+				assert.Equal(t, "https://target", aws.ToString(input.QueueUrl))
+				assert.Equal(t, "hello", aws.ToString(input.MessageBody))
+				assert.Equal(t, "3", aws.ToString(input.MessageAttributes[RedriveReceiveCountAttribute].StringValue))
+				assert.Equal(t, "bar", aws.ToString(input.MessageAttributes["Foo"].StringValue))
+				assert.Nil(t, input.MessageGroupId)
+			},
+		},
+		{
+			name: "FIFO message keeps group ID",
+			record: events.SQSMessage{
+				MessageId:  "message-1",
+				Body:       "hello",
+				Attributes: map[string]string{"MessageGroupId": "group-1"},
+			},
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				assert.Equal(t, "group-1", aws.ToString(client.sent[0].MessageGroupId))
+				assert.Equal(t, "message-1", aws.ToString(client.sent[0].MessageDeduplicationId))
+				assert.NotContains(t, client.sent[0].MessageAttributes, RedriveReceiveCountAttribute)
+			},
+		},
+		{
+			name: "Receive count not added when attributes are full",
+			record: events.SQSMessage{
+				MessageId:  "message-1",
+				Body:       "hello",
+				Attributes: map[string]string{"ApproximateReceiveCount": "3"},
+				MessageAttributes: map[string]events.SQSMessageAttribute{
+					"A1": {DataType: "String", StringValue: aws.String("1")}, "A2": {DataType: "String", StringValue: aws.String("2")},
+					"A3": {DataType: "String", StringValue: aws.String("3")}, "A4": {DataType: "String", StringValue: aws.String("4")},
+					"A5": {DataType: "String", StringValue: aws.String("5")}, "A6": {DataType: "String", StringValue: aws.String("6")},
+					"A7": {DataType: "String", StringValue: aws.String("7")}, "A8": {DataType: "String", StringValue: aws.String("8")},
+					"A9": {DataType: "String", StringValue: aws.String("9")}, "A10": {DataType: "String", StringValue: aws.String("10")},
+				},
+			},
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				assert.Len(t, client.sent[0].MessageAttributes, maxSQSMessageAttributes)
+				assert.NotContains(t, client.sent[0].MessageAttributes, RedriveReceiveCountAttribute)
+			},
+		},
+		{
+			name: "Receive count replaced when redriven again",
+			record: events.SQSMessage{
+				MessageId:  "message-1",
+				Body:       "hello",
+				Attributes: map[string]string{"ApproximateReceiveCount": "5"},
+				MessageAttributes: map[string]events.SQSMessageAttribute{
+					RedriveReceiveCountAttribute: {DataType: "Number", StringValue: aws.String("3")},
+				},
+			},
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				assert.Equal(t, "5", aws.ToString(client.sent[0].MessageAttributes[RedriveReceiveCountAttribute].StringValue))
+			},
+		},
+		{
+			name:   "Message transformed",
+			record: events.SQSMessage{Body: "hello"},
+			transform: func(ctx context.Context, record events.SQSMessage) (string, bool, error) {
+				return record.Body + " world", true, nil
+			},
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				assert.Equal(t, "hello world", aws.ToString(client.sent[0].MessageBody))
+			},
+		},
+		{
+			name:   "Message dropped by transform",
+			record: events.SQSMessage{Body: "hello"},
+			transform: func(ctx context.Context, record events.SQSMessage) (string, bool, error) {
+				return "", false, nil
+			},
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				assert.Empty(t, client.sent)
+			},
+		},
+		{
+			name:      "Send fails",
+			record:    events.SQSMessage{Body: "hello"},
+			sendErr:   errors.New("send failed"),
+			expectErr: true,
+			checkResult: func(t *testing.T, client *mockSQSClient) {
+				assert.Len(t, client.sent, 1)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSQSClient{sendErr: tc.sendErr}
+			processor := GetSQSRedriveProcessor(client, RedriveOptions{TargetQueueURL: "https://target", Transform: tc.transform})
+			err := processor(context.Background(), tc.record)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			tc.checkResult(t, client)
+		})
+	}
+}
+
+func TestRedriveFromQueue(t *testing.T) {
+	client := &mockSQSClient{
+		receive: [][]sqstypes.Message{
+			{
+				{MessageId: aws.String("1"), ReceiptHandle: aws.String("r1"), Body: aws.String("a")},
+				{MessageId: aws.String("2"), ReceiptHandle: aws.String("r2"), Body: aws.String("b")},
+			},
+			{
+				{MessageId: aws.String("3"), ReceiptHandle: aws.String("r3"), Body: aws.String("c")},
+			},
+		},
+	}
+
+	start := time.Now()
+	count, err := RedriveFromQueue(context.Background(), client, "https://dlq", RedriveOptions{TargetQueueURL: "https://target", MessagesPerSecond: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []string{"r1", "r2", "r3"}, client.deleted)
+	assert.Len(t, client.sent, 3)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+type mockSQSClient struct {
+	sent    []*sqs.SendMessageInput
+	sendErr error
+	receive [][]sqstypes.Message
+	deleted []string
+}
+
+func (m *mockSQSClient) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	m.sent = append(m.sent, params)
+	if m.sendErr != nil {
+		return nil, m.sendErr
+	}
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (m *mockSQSClient) ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if len(m.receive) == 0 {
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	messages := m.receive[0]
+	m.receive = m.receive[1:]
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (m *mockSQSClient) DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	m.deleted = append(m.deleted, aws.ToString(params.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
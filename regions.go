@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// RegionalConfigs provides copies of an aws.Config for other regions, for handlers that write to a replica region or
+// call services pinned to a region (e.g. us-east-1 for global services) while running elsewhere
+type RegionalConfigs struct {
+	base      aws.Config
+	mu        sync.Mutex
+	overrides map[string]string
+	configs   map[string]aws.Config
+}
+
+// NewRegionalConfigs returns RegionalConfigs for the base config. Service region overrides are read from the
+// AWS_REGION_OVERRIDES environment variable (comma-separated service=region pairs, e.g. "sesv2=eu-west-1")
+func NewRegionalConfigs(base aws.Config) *RegionalConfigs {
+	r := &RegionalConfigs{base: base, overrides: map[string]string{}, configs: map[string]aws.Config{}}
+	for _, pair := range strings.Split(os.Getenv("AWS_REGION_OVERRIDES"), ",") {
+		service, region, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && service != "" && region != "" {
+			r.overrides[service] = region
+		}
+	}
+	return r
+}
+
+// WithRegionOverride sets the region returned by ForService for the service
+func (r *RegionalConfigs) WithRegionOverride(service, region string) *RegionalConfigs {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overrides[service] = region
+	return r
+}
+
+// ForRegion returns a copy of the base config for the region. Copies are cached and share the base config's
+// credentials provider
+func (r *RegionalConfigs) ForRegion(region string) aws.Config {
+	if region == "" || region == r.base.Region {
+		return r.base
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cfg, ok := r.configs[region]; ok {
+		return cfg
+	}
+	cfg := r.base.Copy()
+	cfg.Region = region
+	r.configs[region] = cfg
+	return cfg
+}
+
+// ForService returns the config for the service's override region, or the base config if it has no override
+func (r *RegionalConfigs) ForService(service string) aws.Config {
+	r.mu.Lock()
+	region := r.overrides[service]
+	r.mu.Unlock()
+	return r.ForRegion(region)
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionalConfigs(t *testing.T) {
+	t.Setenv("AWS_REGION_OVERRIDES", "sesv2=eu-west-1, bad,cloudfront=us-east-1")
+
+	base := aws.Config{Region: "eu-west-2", Credentials: aws.AnonymousCredentials{}}
+	configs := NewRegionalConfigs(base).WithRegionOverride("s3", "eu-central-1")
+
+	assert.Equal(t, "eu-west-1", configs.ForService("sesv2").Region)
+	assert.Equal(t, "us-east-1", configs.ForService("cloudfront").Region)
+	assert.Equal(t, "eu-central-1", configs.ForService("s3").Region)
+	assert.Equal(t, "eu-west-2", configs.ForService("dynamodb").Region)
+
+	replica := configs.ForRegion("us-west-2")
+	assert.Equal(t, "us-west-2", replica.Region)
+	assert.Equal(t, base.Credentials, replica.Credentials)
+	assert.Equal(t, "eu-west-2", base.Region)
+	assert.Len(t, configs.configs, 4)
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// maxVisibilityTimeoutSeconds is the longest visibility timeout SQS allows (12 hours)
+const maxVisibilityTimeoutSeconds = 43200
+
+// RetryAfterError is returned for an HTTP response asking the caller to slow down (429 Too Many Requests or 503 Service
+// Unavailable). RetryAfter is the delay from the Retry-After header, or zero if there wasn't one
+type RetryAfterError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("http status %d: retry after %s", e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("http status %d", e.StatusCode)
+}
+
+// Retryable is always true - the downstream asked for the request to be made again later
+func (e *RetryAfterError) Retryable() bool {
+	return true
+}
+
+// RetryDelay returns the delay from the Retry-After header
+func (e *RetryAfterError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// RetryAfter wraps err to request that the work is retried after delay. An SQS handler with WithRetryAfterVisibility
+// delays the redelivery of a record that fails with this error
+func RetryAfter(delay time.Duration, err error) error {
+	return &retryAfterDelayError{delay: delay, err: err}
+}
+
+type retryAfterDelayError struct {
+	delay time.Duration
+	err   error
+}
+
+func (e *retryAfterDelayError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", e.err.Error(), e.delay)
+}
+
+func (e *retryAfterDelayError) Unwrap() error {
+	return e.err
+}
+
+func (e *retryAfterDelayError) Retryable() bool {
+	return true
+}
+
+func (e *retryAfterDelayError) RetryDelay() time.Duration {
+	return e.delay
+}
+
+// CheckHTTPResponse returns a RetryAfterError if the response has status 429 or 503, and nil otherwise
+func CheckHTTPResponse(ctx context.Context, resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil
+	}
+	return &RetryAfterError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(GetClock(ctx).Now(), resp.Header.Get("Retry-After"))}
+}
+
+// GetRetryAfter returns the delay requested by the first error in err's chain with a RetryDelay method (such as
+// RetryAfterError, or an error wrapped with RetryAfter)
+func GetRetryAfter(err error) (time.Duration, bool) {
+	var retryAfterError interface{ RetryDelay() time.Duration }
+	if errors.As(err, &retryAfterError) && retryAfterError.RetryDelay() > 0 {
+		return retryAfterError.RetryDelay(), true
+	}
+	return 0, false
+}
+
+// Retry calls fn until it succeeds or maxAttempts have been made, waiting between attempts for the delay requested by a
+// RetryAfterError or otherwise the policy's backoff. It stops early (returning the last error) if waiting would cross
+// the context deadline
+func Retry(ctx context.Context, maxAttempts int, policy BackoffPolicy, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt == maxAttempts-1 {
+			return err
+		}
+		delay, ok := GetRetryAfter(err)
+		if !ok {
+			delay = policy.Delay(ctx, attempt)
+		}
+		GetLogger(ctx).Warn("retrying after failure", "attempt", attempt+1, "delayMs", delay.Milliseconds(), "error", err.Error())
+		if sleepErr := Sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date
+func parseRetryAfter(now time.Time, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// SQSChangeMessageVisibilityAPI is the subset of the SQS client used to change the visibility timeout of a message
+type SQSChangeMessageVisibilityAPI interface {
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+// WithRetryAfterVisibility extends the visibility timeout of records that fail with an error requesting a delay (a
+// RetryAfterError, or an error wrapped with RetryAfter), so that they aren't received again until the delay has passed.
+// The record is still reported as a batch item failure
+func WithRetryAfterVisibility(client SQSChangeMessageVisibilityAPI) SQSOption {
+	return func(o *sqsOptions) {
+		o.visibilityClient = client
+	}
+}
+
+// DefaultSQSBackoffPolicy is a backoff policy for WithReceiveCountBackoff, doubling from 30 seconds to 15 minutes
+var DefaultSQSBackoffPolicy = BackoffPolicy{
+	Initial:    30 * time.Second,
+	Max:        15 * time.Minute,
+	Multiplier: 2,
+	Jitter:     true,
+}
+
+// WithReceiveCountBackoff extends the visibility timeout of failed records by a delay that grows with the number of
+// times the message has been received (see GetSQSReceiveCount), so that repeatedly failing messages don't hammer a
+// downstream service. The delay after the first receive is policy.Delay(ctx, 0). Records that fail with a non-retryable
+// error aren't delayed, and the delay requested by a RetryAfter error takes precedence if WithRetryAfterVisibility is
+// also used
+func WithReceiveCountBackoff(client SQSChangeMessageVisibilityAPI, policy BackoffPolicy) SQSOption {
+	return func(o *sqsOptions) {
+		o.backoffClient = client
+		o.backoffPolicy = policy
+	}
+}
+
+// delayMessage sets the visibility timeout of the record so that it is received again after delay
+func delayMessage(ctx context.Context, client SQSChangeMessageVisibilityAPI, record events.SQSMessage, delay time.Duration) error {
+	err := changeMessageVisibility(ctx, client, record, delay)
+	if err != nil {
+		return StageErr(ctx, "delay message", err)
+	}
+	AddStage(ctx, "delay message")
+	return nil
+}
+
+// changeMessageVisibility sets the visibility timeout of the record's message (rounded up to the second, and capped at
+// the SQS maximum) starting from now
+func changeMessageVisibility(ctx context.Context, client SQSChangeMessageVisibilityAPI, record events.SQSMessage, timeout time.Duration) error {
+	queueURL, err := getQueueURL(record.EventSourceARN)
+	if err != nil {
+		return err
+	}
+	seconds := int32(math.Min(math.Ceil(timeout.Seconds()), maxVisibilityTimeoutSeconds))
+	_, err = client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(queueURL),
+		ReceiptHandle:     aws.String(record.ReceiptHandle),
+		VisibilityTimeout: seconds,
+	})
+	return err
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHTTPResponse(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name        string
+		statusCode  int
+		retryAfter  string
+		checkResult func(t *testing.T, err error)
+	}{
+		{
+			name:       "Retry-After in seconds",
+			statusCode: http.StatusTooManyRequests,
+			retryAfter: "30",
+			checkResult: func(t *testing.T, err error) {
+				delay, ok := GetRetryAfter(fmt.Errorf("call api: %w", err))
+				assert.True(t, ok)
+				assert.Equal(t, 30*time.Second, delay)
+				assert.EqualError(t, err, "http status 429: retry after 30s")
+			},
+		},
+		{
+			name:       "Retry-After as a date",
+			statusCode: http.StatusServiceUnavailable,
+			retryAfter: "Wed, 01 May 2024 12:02:00 GMT",
+			checkResult: func(t *testing.T, err error) {
+				delay, ok := GetRetryAfter(err)
+				assert.True(t, ok)
+				assert.Equal(t, 2*time.Minute, delay)
+			},
+		},
+		{
+			name:       "No Retry-After header",
+			statusCode: http.StatusTooManyRequests,
+			checkResult: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "http status 429")
+				_, ok := GetRetryAfter(err)
+				assert.False(t, ok)
+			},
+		},
+		{
+			name:       "Successful response",
+			statusCode: http.StatusOK,
+			retryAfter: "30",
+			checkResult: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tc.statusCode, Header: http.Header{}}
+			if tc.retryAfter != "" {
+				resp.Header.Set("Retry-After", tc.retryAfter)
+			}
+			ctx := ContextWithClock(context.Background(), NewFakeClock(now))
+			tc.checkResult(t, CheckHTTPResponse(ctx, resp))
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	policy := BackoffPolicy{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2}
+
+	attempts := 0
+	err := Retry(context.Background(), 3, policy, func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return &RetryAfterError{StatusCode: 429, RetryAfter: 10 * time.Millisecond}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
+	attempts = 0
+	err = Retry(context.Background(), 3, policy, func(ctx context.Context) error {
+		attempts++
+		return errors.New("something bad happened")
+	})
+	assert.EqualError(t, err, "something bad happened")
+	assert.Equal(t, 3, attempts)
+
+	//Stops when the requested delay would cross the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	attempts = 0
+	err = Retry(ctx, 3, policy, func(ctx context.Context) error {
+		attempts++
+		return &RetryAfterError{StatusCode: 429, RetryAfter: time.Minute}
+	})
+	assert.EqualError(t, err, "http status 429: retry after 1m0s")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWithRetryAfterVisibility(t *testing.T) {
+	client := &mockSQSVisibilityClient{}
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		return fmt.Errorf("call api: %w", &RetryAfterError{StatusCode: 429, RetryAfter: 1500 * time.Millisecond})
+	}, WithRetryAfterVisibility(client))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "receipt-1", EventSourceARN: "arn:aws:sqs:eu-west-2:123456789012:orders"},
+	}})
+	assert.Nil(t, err)
+	assert.Len(t, result.BatchItemFailures, 1)
+	assert.Equal(t, "https://sqs.eu-west-2.amazonaws.com/123456789012/orders", aws.ToString(client.input.QueueUrl))
+	assert.Equal(t, "receipt-1", aws.ToString(client.input.ReceiptHandle))
+	assert.Equal(t, int32(2), client.input.VisibilityTimeout)
+}
+
+func TestRetryAfter(t *testing.T) {
+	cause := errors.New("downstream busy")
+	err := fmt.Errorf("process record: %w", RetryAfter(30*time.Second, cause))
+	assert.EqualError(t, err, "process record: downstream busy: retry after 30s")
+	assert.ErrorIs(t, err, cause)
+	assert.True(t, IsRetryable(err))
+	delay, ok := GetRetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, delay)
+
+	client := &mockSQSVisibilityClient{}
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		return RetryAfter(5*time.Minute, cause)
+	}, WithRetryAfterVisibility(client))
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "receipt-1", EventSourceARN: "arn:aws:sqs:eu-west-2:123456789012:orders"},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "receipt-1"}}, result.BatchItemFailures)
+	assert.Equal(t, int32(300), client.input.VisibilityTimeout)
+}
+
+func TestWithReceiveCountBackoff(t *testing.T) {
+	testcases := []struct {
+		name     string
+		count    string
+		err      error
+		expected int32
+	}{
+		{name: "First receive", count: "1", err: errors.New("oops"), expected: 10},
+		{name: "Third receive", count: "3", err: errors.New("oops"), expected: 40},
+		{name: "Capped", count: "10", err: errors.New("oops"), expected: 60},
+		{name: "Non-retryable", count: "1", err: NonRetryable(errors.New("oops")), expected: 0},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockSQSVisibilityClient{}
+			h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+				return tc.err
+			}, WithReceiveCountBackoff(client, BackoffPolicy{Initial: 10 * time.Second, Max: time.Minute, Multiplier: 2}))
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+				{ReceiptHandle: "receipt-1", EventSourceARN: "arn:aws:sqs:eu-west-2:123456789012:orders", Attributes: map[string]string{"ApproximateReceiveCount": tc.count}},
+			}})
+			assert.Nil(t, err)
+			if tc.expected == 0 {
+				//Non-retryable messages are acknowledged without being delayed
+				assert.Empty(t, result.BatchItemFailures)
+				assert.Nil(t, client.input)
+				return
+			}
+			assert.Len(t, result.BatchItemFailures, 1)
+			assert.Equal(t, tc.expected, client.input.VisibilityTimeout)
+		})
+	}
+}
+
+type mockSQSVisibilityClient struct {
+	input *sqs.ChangeMessageVisibilityInput
+}
+
+func (m *mockSQSVisibilityClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	m.input = params
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
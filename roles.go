@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// maxRoleSessionNameLength is the longest session name accepted by sts:AssumeRole
+const maxRoleSessionNameLength = 64
+
+// RoleConfigs provides copies of an aws.Config that use credentials from assuming other IAM roles, for handlers that
+// operate across multiple AWS accounts. Credentials are cached and refreshed before they expire
+type RoleConfigs struct {
+	base    aws.Config
+	client  stscreds.AssumeRoleAPIClient
+	mu      sync.Mutex
+	roles   map[string]string
+	configs map[string]aws.Config
+}
+
+// NewRoleConfigs returns RoleConfigs that assume roles using the STS client. Named roles are read from the ASSUME_ROLES
+// environment variable (comma-separated name=roleArn pairs)
+func NewRoleConfigs(base aws.Config, client stscreds.AssumeRoleAPIClient) *RoleConfigs {
+	r := &RoleConfigs{base: base, client: client, roles: map[string]string{}, configs: map[string]aws.Config{}}
+	for _, pair := range strings.Split(os.Getenv("ASSUME_ROLES"), ",") {
+		name, roleARN, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && name != "" && roleARN != "" {
+			r.roles[name] = roleARN
+		}
+	}
+	return r
+}
+
+// GetRoleConfigs returns RoleConfigs using an STS client created from awsConfig
+func GetRoleConfigs(awsConfig aws.Config) *RoleConfigs {
+	return NewRoleConfigs(awsConfig, sts.NewFromConfig(awsConfig))
+}
+
+// WithRole sets the role ARN used by ForName for the name
+func (r *RoleConfigs) WithRole(name, roleARN string) *RoleConfigs {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roles[name] = roleARN
+	return r
+}
+
+// ForRole returns a copy of the base config that assumes the role
+func (r *RoleConfigs) ForRole(roleARN string) aws.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cfg, ok := r.configs[roleARN]; ok {
+		return cfg
+	}
+	provider := stscreds.NewAssumeRoleProvider(r.client, roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = getRoleSessionName()
+	})
+	cfg := r.base.Copy()
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	r.configs[roleARN] = cfg
+	return cfg
+}
+
+// ForName returns a copy of the base config that assumes the named role. It returns an error if no role has been
+// configured with the name
+func (r *RoleConfigs) ForName(name string) (aws.Config, error) {
+	r.mu.Lock()
+	roleARN, ok := r.roles[name]
+	r.mu.Unlock()
+	if !ok {
+		return aws.Config{}, fmt.Errorf("no role configured with name '%s'", name)
+	}
+	return r.ForRole(roleARN), nil
+}
+
+// getRoleSessionName uses the function name so that CloudTrail entries in the other account identify the caller
+func getRoleSessionName() string {
+	name := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if name == "" {
+		name = "handler"
+	}
+	if len(name) > maxRoleSessionNameLength {
+		name = name[:maxRoleSessionNameLength]
+	}
+	return name
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleConfigs(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "my-function")
+	t.Setenv("ASSUME_ROLES", "audit=arn:aws:iam::111111111111:role/audit")
+
+	client := &mockSTSClient{}
+	base := aws.Config{Region: "eu-west-2", Credentials: aws.AnonymousCredentials{}}
+	configs := NewRoleConfigs(base, client).WithRole("billing", "arn:aws:iam::222222222222:role/billing")
+
+	cfg, err := configs.ForName("audit")
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-2", cfg.Region)
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "AKID", creds.AccessKeyID)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/audit", aws.ToString(client.input.RoleArn))
+	assert.Equal(t, "my-function", aws.ToString(client.input.RoleSessionName))
+
+	//Credentials are cached
+	cfg, _ = configs.ForName("audit")
+	_, _ = cfg.Credentials.Retrieve(context.Background())
+	assert.Equal(t, 1, client.calls)
+
+	_, err = configs.ForName("billing")
+	assert.Nil(t, err)
+	_, err = configs.ForName("unknown")
+	assert.EqualError(t, err, "no role configured with name 'unknown'")
+}
+
+type mockSTSClient struct {
+	input *sts.AssumeRoleInput
+	calls int
+}
+
+func (m *mockSTSClient) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	m.input = params
+	m.calls++
+	return &sts.AssumeRoleOutput{Credentials: &ststypes.Credentials{
+		AccessKeyId:     aws.String("AKID"),
+		SecretAccessKey: aws.String("SECRET"),
+		SessionToken:    aws.String("TOKEN"),
+		Expiration:      aws.Time(time.Now().Add(time.Hour)),
+	}}, nil
+}
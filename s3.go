@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// S3Record is an S3 notification record with its object key URL-decoded
+type S3Record struct {
+	Bucket    string
+	Key       string
+	EventName string
+	EventTime time.Time
+	Size      int64
+	ETag      string
+	VersionID string
+}
+
+type S3RecordProcessor func(ctx context.Context, record S3Record) error
+
+type S3Handler = Handler[events.S3Event, struct{}]
+
+// GetS3Handler returns a lambda handler that will process each S3 notification record in parallel using the provided
+// processRecord function. Object keys are URL-decoded (S3 encodes them in notifications, with spaces as "+"). S3 has no
+// partial batch response, so if any record fails or times out the handler returns an error and the whole event is
+// retried
+func GetS3Handler(processRecord S3RecordProcessor) Handler[events.S3Event, struct{}] {
+
+	source := batchSource[events.S3EventRecord]{
+		name: "s3 record",
+		logAttrs: func(record events.S3EventRecord) []any {
+			return []any{"bucket", record.S3.Bucket.Name, "key", record.S3.Object.Key, "eventName", record.EventName}
+		},
+		process: func(ctx context.Context, record events.S3EventRecord) error {
+			key, err := url.QueryUnescape(record.S3.Object.Key)
+			if err != nil {
+				return StageErr(ctx, "decode key", err)
+			}
+			AddStage(ctx, "decode key")
+			return processRecord(ctx, S3Record{
+				Bucket:    record.S3.Bucket.Name,
+				Key:       key,
+				EventName: record.EventName,
+				EventTime: record.EventTime,
+				Size:      record.S3.Object.Size,
+				ETag:      record.S3.Object.ETag,
+				VersionID: record.S3.Object.VersionID,
+			})
+		},
+	}
+
+	return func(ctx context.Context, event events.S3Event) (struct{}, error) {
+		results, err := processBatch(ctx, event.Records, source)
+		if err != nil {
+			return struct{}{}, err
+		}
+		if failed := countFailed(results); failed > 0 {
+			return struct{}{}, fmt.Errorf("%d of %d s3 records failed", failed, len(results))
+		}
+		return struct{}{}, nil
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetS3Handler(t *testing.T) {
+
+	twoRecordEvent := events.S3Event{Records: []events.S3EventRecord{
+		{EventName: "ObjectCreated:Put", S3: events.S3Entity{Bucket: events.S3Bucket{Name: "my-bucket"}, Object: events.S3Object{Key: "reports/monthly+report%282024%29.csv", Size: 10}}},
+		{EventName: "ObjectRemoved:Delete", S3: events.S3Entity{Bucket: events.S3Bucket{Name: "my-bucket"}, Object: events.S3Object{Key: "other.csv"}}},
+	}}
+
+	testcases := []struct {
+		name          string
+		processRecord S3RecordProcessor
+		event         events.S3Event
+		checkResult   func(t *testing.T, err error)
+	}{
+		{
+			name: "All records processed",
+			processRecord: func(ctx context.Context, record S3Record) error {
+				assert.Equal(t, "my-bucket", record.Bucket)
+				if record.EventName == "ObjectCreated:Put" {
+					assert.Equal(t, "reports/monthly report(2024).csv", record.Key)
+					assert.Equal(t, int64(10), record.Size)
+				}
+				return nil
+			},
+			event: twoRecordEvent,
+			checkResult: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "Some records fail",
+			processRecord: func(ctx context.Context, record S3Record) error {
+				if record.Key == "other.csv" {
+					return errors.New("something bad happened")
+				}
+				return nil
+			},
+			event: twoRecordEvent,
+			checkResult: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "1 of 2 s3 records failed")
+			},
+		},
+		{
+			name: "Invalid key encoding",
+			processRecord: func(ctx context.Context, record S3Record) error {
+				return nil
+			},
+			event: events.S3Event{Records: []events.S3EventRecord{{S3: events.S3Entity{Object: events.S3Object{Key: "bad%zzkey"}}}}},
+			checkResult: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "1 of 1 s3 records failed")
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+
+			handler := GetS3Handler(tc.processRecord)
+			_, err := handler(ctx, tc.event)
+			tc.checkResult(t, err)
+		})
+	}
+}
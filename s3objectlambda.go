@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3WriteGetObjectResponseAPI is the subset of the S3 client used to return the result of an S3 Object Lambda
+type S3WriteGetObjectResponseAPI interface {
+	WriteGetObjectResponse(ctx context.Context, params *s3.WriteGetObjectResponseInput, optFns ...func(*s3.Options)) (*s3.WriteGetObjectResponseOutput, error)
+}
+
+// S3ObjectTransform transforms the original object into the object returned to the caller
+type S3ObjectTransform func(ctx context.Context, event events.S3ObjectLambdaEvent, original io.Reader) (io.Reader, error)
+
+type S3ObjectLambdaHandler = Handler[events.S3ObjectLambdaEvent, struct{}]
+
+// GetS3ObjectLambdaHandler returns a lambda handler for S3 Object Lambda GetObject requests. It fetches the original
+// object from the event's inputS3Url, passes it to transform, and returns the transformed object with
+// WriteGetObjectResponse. If the original object can't be fetched its status code is passed on to the caller (e.g. 404
+// for a missing key), and if transform fails the caller gets a 500 response. The handler only returns an error if the
+// response can't be written
+func GetS3ObjectLambdaHandler(client S3WriteGetObjectResponseAPI, transform S3ObjectTransform) Handler[events.S3ObjectLambdaEvent, struct{}] {
+	return func(ctx context.Context, event events.S3ObjectLambdaEvent) (struct{}, error) {
+		ctx = ContextWithStages(ctx)
+		if event.GetObjectContext == nil {
+			return struct{}{}, errors.New("s3 object lambda event is not a GetObject request")
+		}
+		getObjectContext := event.GetObjectContext
+
+		writeError := func(statusCode int, errorCode string, err error) error {
+			GetLogger(ctx).Error("s3 object lambda request failed", "errStr", err.Error(), "url", event.UserRequest.URL, "statusCode", statusCode, "errObj", err, "stages", getStagesLogValue(ctx))
+			_, writeErr := client.WriteGetObjectResponse(ctx, &s3.WriteGetObjectResponseInput{
+				RequestRoute: aws.String(getObjectContext.OutputRoute),
+				RequestToken: aws.String(getObjectContext.OutputToken),
+				StatusCode:   aws.Int32(int32(statusCode)),
+				ErrorCode:    aws.String(errorCode),
+				ErrorMessage: aws.String(http.StatusText(statusCode)),
+			})
+			return StageErr(ctx, "write error response", writeErr)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, getObjectContext.InputS3URL, nil)
+		if err != nil {
+			return struct{}{}, writeError(http.StatusInternalServerError, "InternalError", StageErr(ctx, "fetch original", err))
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return struct{}{}, writeError(http.StatusInternalServerError, "InternalError", StageErr(ctx, "fetch original", err))
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			err = fmt.Errorf("unexpected status %d", response.StatusCode)
+			return struct{}{}, writeError(response.StatusCode, getS3ErrorCode(response.StatusCode), StageErr(ctx, "fetch original", err))
+		}
+		AddStage(ctx, "fetch original")
+
+		transformed, err := transform(ctx, event, response.Body)
+		if err != nil {
+			return struct{}{}, writeError(http.StatusInternalServerError, "TransformFailed", StageErr(ctx, "transform", err))
+		}
+		AddStage(ctx, "transform")
+
+		_, err = client.WriteGetObjectResponse(ctx, &s3.WriteGetObjectResponseInput{
+			RequestRoute: aws.String(getObjectContext.OutputRoute),
+			RequestToken: aws.String(getObjectContext.OutputToken),
+			StatusCode:   aws.Int32(http.StatusOK),
+			Body:         transformed,
+		})
+		if err != nil {
+			return struct{}{}, StageErr(ctx, "write response", err)
+		}
+		AddStage(ctx, "write response")
+		return struct{}{}, nil
+	}
+}
+
+// getS3ErrorCode returns the S3 error code for the status of a failed request for the original object
+func getS3ErrorCode(statusCode int) string {
+	switch statusCode {
+	case http.StatusForbidden:
+		return "AccessDenied"
+	case http.StatusNotFound:
+		return "NoSuchKey"
+	case http.StatusRequestedRangeNotSatisfiable:
+		return "InvalidRange"
+	default:
+		return "InternalError"
+	}
+}
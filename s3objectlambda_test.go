@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetS3ObjectLambdaHandler(t *testing.T) {
+
+	upper := func(ctx context.Context, event events.S3ObjectLambdaEvent, original io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(original)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(strings.ToUpper(string(b))), nil
+	}
+
+	testcases := []struct {
+		name        string
+		path        string
+		transform   S3ObjectTransform
+		checkResult func(t *testing.T, client *mockS3ObjectLambdaClient)
+	}{
+		{
+			name:      "Object transformed",
+			path:      "/object",
+			transform: upper,
+			checkResult: func(t *testing.T, client *mockS3ObjectLambdaClient) {
+				assert.Equal(t, int32(200), aws.ToInt32(client.input.StatusCode))
+				assert.Equal(t, "route", aws.ToString(client.input.RequestRoute))
+				assert.Equal(t, "token", aws.ToString(client.input.RequestToken))
+				assert.Equal(t, "HELLO WORLD", client.body)
+			},
+		},
+		{
+			name:      "Original object missing",
+			path:      "/missing",
+			transform: upper,
+			checkResult: func(t *testing.T, client *mockS3ObjectLambdaClient) {
+				assert.Equal(t, int32(404), aws.ToInt32(client.input.StatusCode))
+				assert.Equal(t, "NoSuchKey", aws.ToString(client.input.ErrorCode))
+			},
+		},
+		{
+			name: "Transform fails",
+			path: "/object",
+			transform: func(ctx context.Context, event events.S3ObjectLambdaEvent, original io.Reader) (io.Reader, error) {
+				return nil, errors.New("something bad happened")
+			},
+			checkResult: func(t *testing.T, client *mockS3ObjectLambdaClient) {
+				assert.Equal(t, int32(500), aws.ToInt32(client.input.StatusCode))
+				assert.Equal(t, "TransformFailed", aws.ToString(client.input.ErrorCode))
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/object" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mockS3ObjectLambdaClient{}
+			handler := GetS3ObjectLambdaHandler(client, tc.transform)
+			_, err := handler(context.Background(), events.S3ObjectLambdaEvent{GetObjectContext: &events.S3ObjectLambdaGetObjectContext{
+				InputS3URL:  server.URL + tc.path,
+				OutputRoute: "route",
+				OutputToken: "token",
+			}})
+			assert.NoError(t, err)
+			tc.checkResult(t, client)
+		})
+	}
+}
+
+type mockS3ObjectLambdaClient struct {
+	input *s3.WriteGetObjectResponseInput
+	body  string
+}
+
+func (m *mockS3ObjectLambdaClient) WriteGetObjectResponse(ctx context.Context, params *s3.WriteGetObjectResponseInput, optFns ...func(*s3.Options)) (*s3.WriteGetObjectResponseOutput, error) {
+	m.input = params
+	if params.Body != nil {
+		b, err := io.ReadAll(params.Body)
+		if err != nil {
+			return nil, err
+		}
+		m.body = string(b)
+	}
+	return &s3.WriteGetObjectResponseOutput{}, nil
+}
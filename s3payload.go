@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3PointerClasses are the class names used by the SQS extended client libraries to mark a body as an S3 pointer
+var s3PointerClasses = map[string]bool{
+	"software.amazon.payloadoffloading.PayloadS3Pointer": true,
+	"com.amazon.sqs.javamessaging.MessageS3Pointer":      true,
+}
+
+// S3PayloadAPI is the subset of the S3 client used to fetch (and delete) message payloads stored in S3
+type S3PayloadAPI interface {
+	S3GetObjectAPI
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+type s3Pointer struct {
+	Bucket string `json:"s3BucketName"`
+	Key    string `json:"s3Key"`
+}
+
+// WithS3Payloads supports large messages sent with the SQS extended client libraries. If a record's body is an S3
+// pointer, the payload is fetched from S3 and used as the body before the record is processed. If deleteAfterProcessing
+// is true the object is deleted once the record has been processed successfully; a redelivered copy of the message
+// will then fail, so only use it if duplicates are rare and can be dropped
+func WithS3Payloads(client S3PayloadAPI, deleteAfterProcessing bool) SQSOption {
+	return func(o *sqsOptions) {
+		o.s3Payloads = client
+		o.deleteS3Payloads = deleteAfterProcessing
+	}
+}
+
+// fetchS3Payload returns the payload that pointer points to
+func fetchS3Payload(ctx context.Context, client S3PayloadAPI, pointer s3Pointer) (string, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(pointer.Bucket), Key: aws.String(pointer.Key)})
+	if err != nil {
+		return "", StageErr(ctx, "fetch s3 payload", err)
+	}
+	b, err := io.ReadAll(output.Body)
+	_ = output.Body.Close()
+	if err != nil {
+		return "", StageErr(ctx, "fetch s3 payload", err)
+	}
+	AddStage(ctx, "fetch s3 payload")
+	return string(b), nil
+}
+
+// deleteS3Payload deletes the payload of a record that has been processed successfully. The record isn't failed if
+// this fails - the object is left for a lifecycle rule to remove
+func deleteS3Payload(ctx context.Context, client S3PayloadAPI, pointer s3Pointer) {
+	_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(pointer.Bucket), Key: aws.String(pointer.Key)})
+	if err != nil {
+		GetLogger(ctx).Warn("failed to delete s3 payload", "error", err.Error(), "bucket", pointer.Bucket, "key", pointer.Key)
+		return
+	}
+	AddStage(ctx, "delete s3 payload")
+}
+
+// parseS3Pointer parses a body of the form ["software.amazon.payloadoffloading.PayloadS3Pointer", {"s3BucketName": "...",
+// "s3Key": "..."}]
+func parseS3Pointer(body string) (s3Pointer, bool) {
+	var parts []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &parts); err != nil || len(parts) != 2 {
+		return s3Pointer{}, false
+	}
+	var class string
+	if err := json.Unmarshal(parts[0], &class); err != nil || !s3PointerClasses[class] {
+		return s3Pointer{}, false
+	}
+	var pointer s3Pointer
+	if err := json.Unmarshal(parts[1], &pointer); err != nil || pointer.Bucket == "" || pointer.Key == "" {
+		return s3Pointer{}, false
+	}
+	return pointer, true
+}
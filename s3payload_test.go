@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithS3Payloads(t *testing.T) {
+	pointer := `["software.amazon.payloadoffloading.PayloadS3Pointer", {"s3BucketName": "payloads", "s3Key": "message-1"}]`
+
+	testcases := []struct {
+		name             string
+		body             string
+		deleteAfter      bool
+		getErr           error
+		processErr       error
+		expectedBody     string
+		expectedFailures []events.SQSBatchItemFailure
+		expectedDeleted  []string
+	}{
+		{
+			name:             "Payload fetched and deleted",
+			body:             pointer,
+			deleteAfter:      true,
+			expectedBody:     `{"Foo": 1}`,
+			expectedFailures: []events.SQSBatchItemFailure{},
+			expectedDeleted:  []string{"payloads/message-1"},
+		},
+		{
+			name:             "Payload kept",
+			body:             pointer,
+			expectedBody:     `{"Foo": 1}`,
+			expectedFailures: []events.SQSBatchItemFailure{},
+		},
+		{
+			name:             "Payload not deleted when processing fails",
+			body:             pointer,
+			deleteAfter:      true,
+			processErr:       errors.New("something bad happened"),
+			expectedBody:     `{"Foo": 1}`,
+			expectedFailures: []events.SQSBatchItemFailure{{ItemIdentifier: "r1"}},
+		},
+		{
+			name:             "Payload can't be fetched",
+			body:             pointer,
+			getErr:           errors.New("access denied"),
+			expectedFailures: []events.SQSBatchItemFailure{{ItemIdentifier: "r1"}},
+		},
+		{
+			name:             "Inline body unchanged",
+			body:             `{"Foo": 2}`,
+			deleteAfter:      true,
+			expectedBody:     `{"Foo": 2}`,
+			expectedFailures: []events.SQSBatchItemFailure{},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+
+			client := &mockS3PayloadClient{content: `{"Foo": 1}`, getErr: tc.getErr}
+			body := ""
+			handler := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+				body = record.Body
+				return tc.processErr
+			}, WithS3Payloads(client, tc.deleteAfter))
+			result, err := handler(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "r1", Body: tc.body}}})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedBody, body)
+			assert.Equal(t, tc.expectedFailures, result.BatchItemFailures)
+			assert.Equal(t, tc.expectedDeleted, client.deleted)
+		})
+	}
+}
+
+type mockS3PayloadClient struct {
+	content string
+	getErr  error
+	deleted []string
+	gets    int
+}
+
+func (m *mockS3PayloadClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	m.gets++
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(m.content))}, nil
+}
+
+func (m *mockS3PayloadClient) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	m.deleted = append(m.deleted, aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
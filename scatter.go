@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message attributes used to route scatter-gather requests and replies
+const (
+	CorrelationIDAttribute = "CorrelationId"
+	ScatterIndexAttribute  = "ScatterIndex"
+	ReplyQueueURLAttribute = "ReplyQueueUrl"
+)
+
+// NewCorrelationID returns a random ID for a scatter-gather request
+func NewCorrelationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Scatter sends each request to queueURL as JSON, with the correlation ID, the index of the request and the reply queue
+// as message attributes. The workers should send their responses with Reply. Gatherer.Start must be called before
+// Scatter so that replies have somewhere to be recorded
+func Scatter[T interface{}](ctx context.Context, client SQSSendMessageAPI, queueURL string, replyQueueURL string, correlationID string, requests []T) error {
+	for i, request := range requests {
+		b, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("marshal request %d: %w", i, err)
+		}
+		_, err = client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:          aws.String(queueURL),
+			MessageBody:       aws.String(string(b)),
+			MessageAttributes: scatterAttributes(correlationID, i, replyQueueURL),
+		})
+		if err != nil {
+			return fmt.Errorf("send request %d: %w", i, err)
+		}
+	}
+	GetLogger(ctx).Info("scattered requests", "correlationId", correlationID, "count", len(requests))
+	return nil
+}
+
+// Reply sends response as JSON to the reply queue of a request sent by Scatter, with the same correlation ID and index
+func Reply[U interface{}](ctx context.Context, client SQSSendMessageAPI, request events.SQSMessage, response U) error {
+	correlationID, index, err := getScatterAttributes(request)
+	if err != nil {
+		return err
+	}
+	replyQueueURL := aws.ToString(request.MessageAttributes[ReplyQueueURLAttribute].StringValue)
+	if replyQueueURL == "" {
+		return errors.New("message has no reply queue")
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(replyQueueURL),
+		MessageBody:       aws.String(string(b)),
+		MessageAttributes: scatterAttributes(correlationID, index, ""),
+	})
+	return err
+}
+
+func scatterAttributes(correlationID string, index int, replyQueueURL string) map[string]sqstypes.MessageAttributeValue {
+	attributes := map[string]sqstypes.MessageAttributeValue{
+		CorrelationIDAttribute: {DataType: aws.String("String"), StringValue: aws.String(correlationID)},
+		ScatterIndexAttribute:  {DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(index))},
+	}
+	if replyQueueURL != "" {
+		attributes[ReplyQueueURLAttribute] = sqstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(replyQueueURL)}
+	}
+	return attributes
+}
+
+func getScatterAttributes(message events.SQSMessage) (string, int, error) {
+	correlationID := aws.ToString(message.MessageAttributes[CorrelationIDAttribute].StringValue)
+	if correlationID == "" {
+		return "", 0, errors.New("message has no correlation ID")
+	}
+	index, err := strconv.Atoi(aws.ToString(message.MessageAttributes[ScatterIndexAttribute].StringValue))
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid scatter index: %w", err)
+	}
+	return correlationID, index, nil
+}
+
+// DynamoDBGatherAPI is the subset of the DynamoDB client used by Gatherer
+type DynamoDBGatherAPI interface {
+	DynamoDBGetItemAPI
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+// GatherResult is the state of a scatter-gather request. Complete is true for exactly one call to Gatherer.Add or
+// Gatherer.Check: the one that found the quorum reached or the timeout passed
+type GatherResult[U interface{}] struct {
+	CorrelationID string
+	Responses     map[int]U
+	Expected      int
+	Quorum        int
+	Complete      bool
+	TimedOut      bool
+}
+
+// Gatherer aggregates the replies to a scatter-gather request in a DynamoDB table with a string partition key named
+// "correlationId", so that the partial state survives across invocations of the reply queue handler
+type Gatherer[U interface{}] struct {
+	client DynamoDBGatherAPI
+	table  string
+}
+
+func NewGatherer[U interface{}](client DynamoDBGatherAPI, table string) *Gatherer[U] {
+	return &Gatherer[U]{client: client, table: table}
+}
+
+// Start records a scatter-gather request that expects the given number of replies and completes once quorum replies
+// have arrived or the timeout has passed
+func (g *Gatherer[U]) Start(ctx context.Context, correlationID string, expected int, quorum int, timeout time.Duration) error {
+	_, err := g.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(g.table),
+		Item: map[string]ddbtypes.AttributeValue{
+			"correlationId": &ddbtypes.AttributeValueMemberS{Value: correlationID},
+			"expected":      &ddbtypes.AttributeValueMemberN{Value: strconv.Itoa(expected)},
+			"quorum":        &ddbtypes.AttributeValueMemberN{Value: strconv.Itoa(quorum)},
+			"expiresAt":     &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(GetClock(ctx).Now().Add(timeout).Unix(), 10)},
+			"responses":     &ddbtypes.AttributeValueMemberM{Value: map[string]ddbtypes.AttributeValue{}},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(correlationId)"),
+	})
+	return err
+}
+
+// Add records a reply sent with Reply. Duplicate deliveries of the same reply overwrite each other
+func (g *Gatherer[U]) Add(ctx context.Context, reply events.SQSMessage) (GatherResult[U], error) {
+	correlationID, index, err := getScatterAttributes(reply)
+	if err != nil {
+		return GatherResult[U]{}, err
+	}
+
+	output, err := g.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(g.table),
+		Key:                       g.key(correlationID),
+		UpdateExpression:          aws.String("SET responses.#index = :response"),
+		ConditionExpression:       aws.String("attribute_exists(correlationId)"),
+		ExpressionAttributeNames:  map[string]string{"#index": strconv.Itoa(index)},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{":response": &ddbtypes.AttributeValueMemberB{Value: []byte(reply.Body)}},
+		ReturnValues:              ddbtypes.ReturnValueAllNew,
+	})
+	if err != nil {
+		return GatherResult[U]{}, fmt.Errorf("record reply: %w", err)
+	}
+	return g.evaluate(ctx, correlationID, output.Attributes)
+}
+
+// Check returns the state of a scatter-gather request, completing it if the timeout has passed. It should be called on a
+// schedule (or after a delay) so that requests which don't get enough replies still complete
+func (g *Gatherer[U]) Check(ctx context.Context, correlationID string) (GatherResult[U], error) {
+	output, err := g.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(g.table),
+		Key:            g.key(correlationID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return GatherResult[U]{}, err
+	}
+	if output.Item == nil {
+		return GatherResult[U]{}, fmt.Errorf("no scatter-gather request with correlation ID %s", correlationID)
+	}
+	return g.evaluate(ctx, correlationID, output.Item)
+}
+
+func (g *Gatherer[U]) evaluate(ctx context.Context, correlationID string, item map[string]ddbtypes.AttributeValue) (GatherResult[U], error) {
+	result := GatherResult[U]{CorrelationID: correlationID, Responses: map[int]U{}}
+	result.Expected, _ = strconv.Atoi(getNumberAttribute(item, "expected"))
+	result.Quorum, _ = strconv.Atoi(getNumberAttribute(item, "quorum"))
+	expiresAt, _ := strconv.ParseInt(getNumberAttribute(item, "expiresAt"), 10, 64)
+
+	if responses, ok := item["responses"].(*ddbtypes.AttributeValueMemberM); ok {
+		for k, v := range responses.Value {
+			index, err := strconv.Atoi(k)
+			b, ok := v.(*ddbtypes.AttributeValueMemberB)
+			if err != nil || !ok {
+				continue
+			}
+			var response U
+			err = json.Unmarshal(b.Value, &response)
+			if err != nil {
+				return result, fmt.Errorf("unmarshal reply %d: %w", index, err)
+			}
+			result.Responses[index] = response
+		}
+	}
+
+	if _, completed := item["completedAt"]; completed {
+		return result, nil
+	}
+	quorumReached := len(result.Responses) >= result.Quorum
+	result.TimedOut = !quorumReached && !GetClock(ctx).Now().Before(time.Unix(expiresAt, 0))
+	if !quorumReached && !result.TimedOut {
+		return result, nil
+	}
+
+	//Only one caller marks the request as complete, even if replies are processed concurrently
+	_, err := g.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(g.table),
+		Key:                       g.key(correlationID),
+		UpdateExpression:          aws.String("SET completedAt = :now"),
+		ConditionExpression:       aws.String("attribute_not_exists(completedAt)"),
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{":now": &ddbtypes.AttributeValueMemberS{Value: GetClock(ctx).Now().UTC().Format(time.RFC3339)}},
+	})
+	if err != nil {
+		var conditionFailed *ddbtypes.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return result, nil
+		}
+		return result, fmt.Errorf("complete request: %w", err)
+	}
+	result.Complete = true
+	GetLogger(ctx).Info("scatter-gather request complete", "correlationId", correlationID, "responses", len(result.Responses), "expected", result.Expected, "timedOut", result.TimedOut)
+	return result, nil
+}
+
+func (g *Gatherer[U]) key(correlationID string) map[string]ddbtypes.AttributeValue {
+	return map[string]ddbtypes.AttributeValue{"correlationId": &ddbtypes.AttributeValueMemberS{Value: correlationID}}
+}
+
+func getNumberAttribute(item map[string]ddbtypes.AttributeValue, name string) string {
+	if n, ok := item[name].(*ddbtypes.AttributeValueMemberN); ok {
+		return n.Value
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScatterGather(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(now)
+	ctx := ContextWithClock(context.Background(), clock)
+
+	sqsClient := &mockSQSClient{}
+	ddbClient := &mockDynamoDBGatherClient{}
+	gatherer := NewGatherer[outputEvent](ddbClient, "gather")
+
+	correlationID := NewCorrelationID()
+	err := gatherer.Start(ctx, correlationID, 3, 2, time.Minute)
+	assert.Nil(t, err)
+	err = Scatter(ctx, sqsClient, "https://requests", "https://replies", correlationID, []inputEvent{{Foo: 1}, {Foo: 2}, {Foo: 3}})
+	assert.Nil(t, err)
+	assert.Len(t, sqsClient.sent, 3)
+	assert.Equal(t, `{"Foo":2}`, aws.ToString(sqsClient.sent[1].MessageBody))
+
+	//Workers reply to the first two requests
+	requests := sqsClient.sent
+	sqsClient.sent = nil
+	for _, request := range requests[:2] {
+		err = Reply(ctx, sqsClient, toSQSMessage(request), outputEvent{Bar: 10})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, "https://replies", aws.ToString(sqsClient.sent[0].QueueUrl))
+
+	result, err := gatherer.Add(ctx, toSQSMessage(sqsClient.sent[0]))
+	assert.Nil(t, err)
+	assert.False(t, result.Complete)
+	assert.Equal(t, map[int]outputEvent{0: {Bar: 10}}, result.Responses)
+
+	result, err = gatherer.Add(ctx, toSQSMessage(sqsClient.sent[1]))
+	assert.Nil(t, err)
+	assert.True(t, result.Complete)
+	assert.False(t, result.TimedOut)
+	assert.Len(t, result.Responses, 2)
+
+	//Completes only once
+	result, err = gatherer.Check(ctx, correlationID)
+	assert.Nil(t, err)
+	assert.False(t, result.Complete)
+}
+
+func TestGathererTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	ctx := ContextWithClock(context.Background(), clock)
+	gatherer := NewGatherer[outputEvent](&mockDynamoDBGatherClient{}, "gather")
+
+	err := gatherer.Start(ctx, "abc", 3, 3, time.Minute)
+	assert.Nil(t, err)
+
+	result, err := gatherer.Check(ctx, "abc")
+	assert.Nil(t, err)
+	assert.False(t, result.Complete)
+
+	clock.Advance(time.Minute)
+	result, err = gatherer.Check(ctx, "abc")
+	assert.Nil(t, err)
+	assert.True(t, result.Complete)
+	assert.True(t, result.TimedOut)
+}
+
+func toSQSMessage(input *sqs.SendMessageInput) events.SQSMessage {
+	attributes := map[string]events.SQSMessageAttribute{}
+	for k, v := range input.MessageAttributes {
+		attributes[k] = events.SQSMessageAttribute{DataType: aws.ToString(v.DataType), StringValue: v.StringValue}
+	}
+	return events.SQSMessage{Body: aws.ToString(input.MessageBody), MessageAttributes: attributes}
+}
+
+// mockDynamoDBGatherClient holds a single item and understands the update expressions used by Gatherer
+type mockDynamoDBGatherClient struct {
+	item map[string]ddbtypes.AttributeValue
+}
+
+func (m *mockDynamoDBGatherClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.item}, nil
+}
+
+func (m *mockDynamoDBGatherClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.item = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockDynamoDBGatherClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if index, ok := params.ExpressionAttributeNames["#index"]; ok {
+		m.item["responses"].(*ddbtypes.AttributeValueMemberM).Value[index] = params.ExpressionAttributeValues[":response"]
+		return &dynamodb.UpdateItemOutput{Attributes: m.item}, nil
+	}
+	if _, ok := m.item["completedAt"]; ok {
+		return nil, &ddbtypes.ConditionalCheckFailedException{}
+	}
+	m.item["completedAt"] = params.ExpressionAttributeValues[":now"]
+	return &dynamodb.UpdateItemOutput{}, nil
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ScheduledHandler is a handler for cron-triggered lambdas, which have no meaningful input or output
+type ScheduledHandler func(ctx context.Context) error
+
+// GetScheduledHandler adapts a ScheduledHandler to a Handler. The event is accepted whatever its shape (an EventBridge
+// scheduled event, or any input configured on an EventBridge Scheduler schedule) and ignored
+func GetScheduledHandler(scheduledHandler ScheduledHandler) Handler[json.RawMessage, struct{}] {
+	return func(ctx context.Context, event json.RawMessage) (struct{}, error) {
+		return struct{}{}, scheduledHandler(ctx)
+	}
+}
+
+// BuildAndStartScheduled is BuildAndStart for a ScheduledHandler
+func BuildAndStartScheduled(getHandler func(awsConfig aws.Config) ScheduledHandler) {
+	BuildAndStart(func(awsConfig aws.Config) Handler[json.RawMessage, struct{}] {
+		return GetScheduledHandler(getHandler(awsConfig))
+	})
+}
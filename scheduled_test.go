@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetScheduledHandler(t *testing.T) {
+	calls := 0
+	h := GetScheduledHandler(func(ctx context.Context) error {
+		calls++
+		if calls > 1 {
+			return errors.New("something bad happened")
+		}
+		return nil
+	})
+
+	event := json.RawMessage(`{"detail-type":"Scheduled Event","source":"aws.events","detail":{}}`)
+	_, err := h(context.Background(), event)
+	assert.Nil(t, err)
+	_, err = h(context.Background(), nil)
+	assert.EqualError(t, err, "something bad happened")
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+)
+
+// SchedulerAPI is the subset of the EventBridge Scheduler client used by Scheduler
+type SchedulerAPI interface {
+	CreateSchedule(ctx context.Context, params *scheduler.CreateScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.CreateScheduleOutput, error)
+	DeleteSchedule(ctx context.Context, params *scheduler.DeleteScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.DeleteScheduleOutput, error)
+}
+
+// Scheduler creates one-off EventBridge Scheduler entries for follow-up actions
+type Scheduler struct {
+	client SchedulerAPI
+	// RoleARN is the role the scheduler assumes to invoke the target. It defaults to SCHEDULER_ROLE_ARN
+	RoleARN string
+	// Group is the schedule group. It defaults to SCHEDULER_GROUP, or "default" if that isn't set
+	Group string
+	// FlexibleWindow lets the scheduler invoke the target up to this long after the requested time. Zero means the
+	// target is invoked at the requested time
+	FlexibleWindow time.Duration
+}
+
+func NewScheduler(client SchedulerAPI) *Scheduler {
+	group := os.Getenv("SCHEDULER_GROUP")
+	if group == "" {
+		group = "default"
+	}
+	return &Scheduler{client: client, RoleARN: os.Getenv("SCHEDULER_ROLE_ARN"), Group: group}
+}
+
+// At schedules the target (e.g. a lambda function or SQS queue ARN) to be invoked once at t with payload marshalled to
+// JSON, and returns the name of the schedule. The schedule deletes itself after it has run
+func (s *Scheduler) At(ctx context.Context, t time.Time, targetARN string, payload interface{}) (string, error) {
+	if s.RoleARN == "" {
+		return "", errors.New("scheduler role ARN is not set")
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	window := &schedulertypes.FlexibleTimeWindow{Mode: schedulertypes.FlexibleTimeWindowModeOff}
+	if minutes := int32(s.FlexibleWindow / time.Minute); minutes > 0 {
+		window = &schedulertypes.FlexibleTimeWindow{Mode: schedulertypes.FlexibleTimeWindowModeFlexible, MaximumWindowInMinutes: aws.Int32(minutes)}
+	}
+
+	name := "job-" + NewID(ctx)
+	_, err = s.client.CreateSchedule(ctx, &scheduler.CreateScheduleInput{
+		Name:                       aws.String(name),
+		GroupName:                  aws.String(s.Group),
+		ScheduleExpression:         aws.String(fmt.Sprintf("at(%s)", t.UTC().Format("2006-01-02T15:04:05"))),
+		ScheduleExpressionTimezone: aws.String("UTC"),
+		FlexibleTimeWindow:         window,
+		ActionAfterCompletion:      schedulertypes.ActionAfterCompletionDelete,
+		Target: &schedulertypes.Target{
+			Arn:     aws.String(targetARN),
+			RoleArn: aws.String(s.RoleARN),
+			Input:   aws.String(string(b)),
+		},
+	})
+	if err != nil {
+		return "", StageErr(ctx, "create schedule", err)
+	}
+	AddStage(ctx, "create schedule "+name)
+	GetLogger(ctx).Info("created schedule", "name", name, "at", t.UTC(), "target", targetARN)
+	return name, nil
+}
+
+// Cancel deletes a schedule created by At. It isn't an error if the schedule has already run or been deleted
+func (s *Scheduler) Cancel(ctx context.Context, name string) error {
+	_, err := s.client.DeleteSchedule(ctx, &scheduler.DeleteScheduleInput{Name: aws.String(name), GroupName: aws.String(s.Group)})
+	if err != nil {
+		var notFound *schedulertypes.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			return StageErr(ctx, "delete schedule", err)
+		}
+	}
+	AddStage(ctx, "delete schedule "+name)
+	GetLogger(ctx).Info("deleted schedule", "name", name)
+	return nil
+}
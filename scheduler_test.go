@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	t.Setenv("SCHEDULER_ROLE_ARN", "arn:aws:iam::123456789012:role/scheduler")
+	t.Setenv("SCHEDULER_GROUP", "")
+
+	client := &mockSchedulerClient{}
+	s := NewScheduler(client)
+	s.FlexibleWindow = 5 * time.Minute
+	ctx := ContextWithStages(context.Background())
+
+	at := time.Date(2024, 5, 1, 13, 30, 0, 0, time.FixedZone("BST", 3600))
+	name, err := s.At(ctx, at, "arn:aws:lambda:eu-west-2:123456789012:function:follow-up", inputEvent{Foo: 1})
+	assert.Nil(t, err)
+	assert.Regexp(t, "^job-[0-9A-Z]{26}$", name)
+
+	input := client.created
+	assert.Equal(t, name, aws.ToString(input.Name))
+	assert.Equal(t, "default", aws.ToString(input.GroupName))
+	assert.Equal(t, "at(2024-05-01T12:30:00)", aws.ToString(input.ScheduleExpression))
+	assert.Equal(t, schedulertypes.FlexibleTimeWindowModeFlexible, input.FlexibleTimeWindow.Mode)
+	assert.Equal(t, int32(5), aws.ToInt32(input.FlexibleTimeWindow.MaximumWindowInMinutes))
+	assert.Equal(t, schedulertypes.ActionAfterCompletionDelete, input.ActionAfterCompletion)
+	assert.Equal(t, `{"Foo":1}`, aws.ToString(input.Target.Input))
+
+	//Deleting a schedule that has already run isn't an error
+	client.deleteErr = &schedulertypes.ResourceNotFoundException{}
+	err = s.Cancel(ctx, name)
+	assert.Nil(t, err)
+	assert.Equal(t, name, client.deleted)
+	assert.Contains(t, getStageDescriptions(ctx), "delete schedule "+name)
+}
+
+type mockSchedulerClient struct {
+	created   *scheduler.CreateScheduleInput
+	deleted   string
+	deleteErr error
+}
+
+func (m *mockSchedulerClient) CreateSchedule(ctx context.Context, params *scheduler.CreateScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.CreateScheduleOutput, error) {
+	m.created = params
+	return &scheduler.CreateScheduleOutput{}, nil
+}
+
+func (m *mockSchedulerClient) DeleteSchedule(ctx context.Context, params *scheduler.DeleteScheduleInput, optFns ...func(*scheduler.Options)) (*scheduler.DeleteScheduleOutput, error) {
+	m.deleted = aws.ToString(params.Name)
+	return &scheduler.DeleteScheduleOutput{}, m.deleteErr
+}
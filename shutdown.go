@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownTimeout bounds the shutdown hooks. Lambda allows the runtime 500ms to exit after SIGTERM
+const shutdownTimeout = 400 * time.Millisecond
+
+// ShutdownHook releases resources (e.g. flushing buffered telemetry) when the sandbox is shut down
+type ShutdownHook func(ctx context.Context) error
+
+var sandbox = struct {
+	start       time.Time
+	invocations atomic.Int64
+	inFlight    atomic.Int64
+
+	mu    sync.Mutex
+	hooks []ShutdownHook
+}{start: time.Now()}
+
+// OnShutdown registers a hook that runs when the runtime receives SIGTERM. Lambda only sends SIGTERM if the function has
+// an extension registered, so BuildAndStart registers an internal one. Hooks run in parallel and their context is
+// cancelled after shutdownTimeout
+func OnShutdown(hook ShutdownHook) {
+	sandbox.mu.Lock()
+	defer sandbox.mu.Unlock()
+	sandbox.hooks = append(sandbox.hooks, hook)
+}
+
+// trackInvocation counts an invocation in the sandbox summary. The returned function must be called when it finishes
+func trackInvocation() func() {
+	sandbox.invocations.Add(1)
+	sandbox.inFlight.Add(1)
+	return func() {
+		sandbox.inFlight.Add(-1)
+	}
+}
+
+func runShutdownHooks(ctx context.Context) {
+	ctx = ContextWithStages(ctx)
+	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+
+	sandbox.mu.Lock()
+	hooks := append([]ShutdownHook{}, sandbox.hooks...)
+	sandbox.mu.Unlock()
+
+	AddStage(ctx, "sigterm received")
+	errs := runParallel(ctx, len(hooks), func(ctx context.Context, i int) error {
+		err := hooks[i](ctx)
+		if err != nil {
+			return StageErr(ctx, fmt.Sprintf("shutdown hook %d", i), err)
+		}
+		AddStage(ctx, fmt.Sprintf("shutdown hook %d", i))
+		return nil
+	})
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			GetLogger(ctx).Warn("shutdown hook failed", "error", err.Error())
+		}
+	}
+
+	GetLogger(ctx).Info("sandbox shutdown",
+		"invocations", sandbox.invocations.Load(),
+		"inFlight", sandbox.inFlight.Load(),
+		"uptimeMs", time.Since(sandbox.start).Milliseconds(),
+		"hooks", len(hooks),
+		"failedHooks", failed,
+		"stages", getStagesLogValue(ctx))
+}
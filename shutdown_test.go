@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunShutdownHooks(t *testing.T) {
+	t.Cleanup(func() {
+		sandbox.hooks = nil
+	})
+
+	OnShutdown(func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		return nil
+	})
+	OnShutdown(func(ctx context.Context) error {
+		return errors.New("something bad happened")
+	})
+
+	h := WithLogger(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		return outputEvent{}, nil
+	})
+	invocations := sandbox.invocations.Load()
+	_, _ = h(context.Background(), inputEvent{})
+
+	buf := &bytes.Buffer{}
+	ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+	runShutdownHooks(ctx)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	summary := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[len(lines)-1]), &summary))
+	assert.Equal(t, "sandbox shutdown", summary["msg"])
+	assert.Equal(t, float64(invocations+1), summary["invocations"])
+	assert.Equal(t, float64(0), summary["inFlight"])
+	assert.Equal(t, float64(2), summary["hooks"])
+	assert.Equal(t, float64(1), summary["failedHooks"])
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInsufficientTime is returned by Sleep and Backoff when waiting would take the invocation past its deadline
+var ErrInsufficientTime = errors.New("insufficient time remaining before deadline")
+
+// BackoffPolicy defines an exponential backoff between retry attempts
+type BackoffPolicy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter randomises each delay between half and all of its value, so that concurrent retries are spread out
+	Jitter bool
+}
+
+var DefaultBackoffPolicy = BackoffPolicy{
+	Initial:    100 * time.Millisecond,
+	Max:        5 * time.Second,
+	Multiplier: 2,
+	Jitter:     true,
+}
+
+// Delay returns the delay before the given retry attempt (starting at 0). The jitter is taken from the invocation's
+// random number generator (see GetRand), so it can be reproduced from the logged seed
+func (p BackoffPolicy) Delay(ctx context.Context, attempt int) time.Duration {
+	delay := float64(p.Initial)
+	for i := 0; i < attempt && delay < float64(p.Max); i++ {
+		delay *= p.Multiplier
+	}
+	if p.Max > 0 && delay > float64(p.Max) {
+		delay = float64(p.Max)
+	}
+	if p.Jitter && delay > 0 {
+		delay = delay/2 + GetRand(ctx).Float64()*delay/2
+	}
+	return time.Duration(delay)
+}
+
+// Sleep pauses for d. It returns ErrInsufficientTime without sleeping if the pause would cross the context deadline
+// (less the deadline margin), or the context's error if the context is done before d has elapsed
+func Sleep(ctx context.Context, d time.Duration) error {
+	clock := GetClock(ctx)
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline && clock.Now().Add(d).After(deadline.Add(-getDeadlineMargin(ctx))) {
+		return ErrInsufficientTime
+	}
+
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Backoff sleeps for the delay the policy gives for attempt. See Sleep for the errors returned
+func Backoff(ctx context.Context, attempt int, policy BackoffPolicy) error {
+	return Sleep(ctx, policy.Delay(ctx, attempt))
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSleep(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		getContext  func() (context.Context, context.CancelFunc)
+		duration    time.Duration
+		checkResult func(t *testing.T, err error, elapsed time.Duration)
+	}{
+		{
+			name: "Sleeps for duration",
+			getContext: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 5*time.Second)
+			},
+			duration: 50 * time.Millisecond,
+			checkResult: func(t *testing.T, err error, elapsed time.Duration) {
+				assert.Nil(t, err)
+				assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+			},
+		},
+		{
+			name: "Would cross deadline",
+			getContext: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Second)
+			},
+			duration: 800 * time.Millisecond,
+			checkResult: func(t *testing.T, err error, elapsed time.Duration) {
+				assert.ErrorIs(t, err, ErrInsufficientTime)
+				assert.Less(t, elapsed, 10*time.Millisecond)
+			},
+		},
+		{
+			name: "Context cancelled",
+			getContext: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			duration: time.Minute,
+			checkResult: func(t *testing.T, err error, elapsed time.Duration) {
+				assert.ErrorIs(t, err, context.Canceled)
+				assert.Less(t, elapsed, time.Second)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := tc.getContext()
+			defer cancel()
+
+			start := time.Now()
+			err := Sleep(ctx, tc.duration)
+			tc.checkResult(t, err, time.Since(start))
+		})
+	}
+}
+
+func TestBackoffPolicy_Delay(t *testing.T) {
+	policy := BackoffPolicy{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(context.Background(), 0))
+	assert.Equal(t, 400*time.Millisecond, policy.Delay(context.Background(), 2))
+	assert.Equal(t, time.Second, policy.Delay(context.Background(), 10))
+
+	//Jitter is reproducible from the invocation's seed
+	policy.Jitter = true
+	first := policy.Delay(ContextWithRand(context.Background(), 42), 3)
+	assert.Equal(t, first, policy.Delay(ContextWithRand(context.Background(), 42), 3))
+	assert.GreaterOrEqual(t, first, 400*time.Millisecond)
+	assert.LessOrEqual(t, first, 800*time.Millisecond)
+}
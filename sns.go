@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// SNSMessage is an SNS record with its message unmarshalled into T
+type SNSMessage[T interface{}] struct {
+	Payload           T
+	MessageID         string
+	TopicArn          string
+	Subject           string
+	Timestamp         time.Time
+	MessageAttributes map[string]interface{}
+}
+
+type SNSRecordProcessor[T interface{}] func(ctx context.Context, message SNSMessage[T]) error
+
+type SNSHandler = Handler[events.SNSEvent, struct{}]
+
+// GetSNSHandler returns a lambda handler that will unmarshal the message of each SNS record into T and process the
+// records in parallel using the provided processRecord function, with the same worker pool, deadline margin and panic
+// handling as the other batch handlers (see MAX_WORKERS). SNS has no partial batch response, so if any record fails or
+// times-out the handler returns the failures joined into one error and Lambda retries the whole event
+func GetSNSHandler[T interface{}](processRecord SNSRecordProcessor[T]) Handler[events.SNSEvent, struct{}] {
+
+	return func(ctx context.Context, event events.SNSEvent) (struct{}, error) {
+		outcomes, err := processBatchOutcomes(ctx, event.Records, batchSource[events.SNSEventRecord]{
+			name: "sns message",
+			logAttrs: func(record events.SNSEventRecord) []any {
+				return []any{"messageId", record.SNS.MessageID}
+			},
+			failureLogAttrs: func(record events.SNSEventRecord) []any {
+				return []any{"body", maskLogBody(record.SNS.Message)}
+			},
+		}, func(ctx context.Context, record events.SNSEventRecord) (struct{}, error) {
+			return struct{}{}, processSNSRecord(ctx, record, processRecord)
+		})
+		if err != nil {
+			return struct{}{}, err
+		}
+
+		errs := make([]error, len(outcomes))
+		for i, outcome := range outcomes {
+			errs[i] = outcome.err
+		}
+		return struct{}{}, errors.Join(errs...)
+	}
+}
+
+// processSNSRecord unmarshals the record's message into T and processes it
+func processSNSRecord[T interface{}](ctx context.Context, record events.SNSEventRecord, processRecord SNSRecordProcessor[T]) error {
+	message := SNSMessage[T]{
+		MessageID:         record.SNS.MessageID,
+		TopicArn:          record.SNS.TopicArn,
+		Subject:           record.SNS.Subject,
+		Timestamp:         record.SNS.Timestamp,
+		MessageAttributes: record.SNS.MessageAttributes,
+	}
+	err := json.Unmarshal([]byte(record.SNS.Message), &message.Payload)
+	if err != nil {
+		return StageErr(ctx, "unmarshal message", err)
+	}
+	AddStage(ctx, "unmarshal message")
+	return processRecord(ctx, message)
+}
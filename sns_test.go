@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSNSHandler(t *testing.T) {
+
+	twoRecordEvent := events.SNSEvent{Records: []events.SNSEventRecord{
+		{SNS: events.SNSEntity{MessageID: "1", Subject: "created", Message: `{"Foo":1}`, MessageAttributes: map[string]interface{}{"Type": map[string]interface{}{"Value": "order"}}}},
+		{SNS: events.SNSEntity{MessageID: "2", Subject: "created", Message: `{"Foo":2}`}},
+	}}
+
+	testcases := []struct {
+		name          string
+		processRecord SNSRecordProcessor[inputEvent]
+		event         events.SNSEvent
+		checkResult   func(t *testing.T, err error)
+	}{
+		{
+			name: "All messages processed",
+			processRecord: func(ctx context.Context, message SNSMessage[inputEvent]) error {
+				if message.Payload.Foo == 1 {
+					assert.Equal(t, "created", message.Subject)
+					assert.Contains(t, message.MessageAttributes, "Type")
+				}
+				return nil
+			},
+			event: twoRecordEvent,
+			checkResult: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "Some messages fail",
+			processRecord: func(ctx context.Context, message SNSMessage[inputEvent]) error {
+				if message.Payload.Foo == 2 {
+					return errors.New("something bad happened")
+				}
+				return nil
+			},
+			event: twoRecordEvent,
+			checkResult: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "something bad happened")
+			},
+		},
+		{
+			name: "Invalid message",
+			processRecord: func(ctx context.Context, message SNSMessage[inputEvent]) error {
+				return nil
+			},
+			event: events.SNSEvent{Records: []events.SNSEventRecord{{SNS: events.SNSEntity{MessageID: "1", Message: `not json`}}}},
+			checkResult: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "unmarshal message: ")
+			},
+		},
+		{
+			name: "Message panics",
+			processRecord: func(ctx context.Context, message SNSMessage[inputEvent]) error {
+				if message.Payload.Foo == 2 {
+					panic("something bad happened")
+				}
+				return nil
+			},
+			event: twoRecordEvent,
+			checkResult: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "panic: something bad happened")
+			},
+		},
+	}
+	t.Run("Message times-out", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(600*time.Millisecond))
+		defer cancel()
+		handler := GetSNSHandler(func(ctx context.Context, message SNSMessage[inputEvent]) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		_, err := handler(ctx, twoRecordEvent)
+		assert.Error(t, err)
+	})
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			handler := GetSNSHandler(tc.processRecord)
+			_, err := handler(ctx, tc.event)
+			tc.checkResult(t, err)
+		})
+	}
+}
+
+func TestGetSNSHandlerLogsMaskedBody(t *testing.T) {
+	t.Setenv("LOG_MASK_PATHS", "$.Secret")
+	buf := &lockedBuffer{}
+	ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(2*time.Second))
+	defer cancel()
+
+	handler := GetSNSHandler(func(ctx context.Context, message SNSMessage[inputEvent]) error {
+		return errors.New("something bad happened")
+	})
+	_, err := handler(ctx, events.SNSEvent{Records: []events.SNSEventRecord{
+		{SNS: events.SNSEntity{MessageID: "1", Message: `{"Foo":1,"Secret":"hunter2"}`}},
+	}})
+	assert.Error(t, err)
+
+	line := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(buf.String()), &line))
+	assert.Equal(t, "sns message processing failed", line["msg"])
+	assert.Equal(t, "1", line["messageId"])
+	assert.Equal(t, `{"Foo":1,"Secret":"****"}`, line["body"])
+}
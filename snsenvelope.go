@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// snsEnvelope is the body of an SQS message delivered by an SNS subscription without raw message delivery
+type snsEnvelope struct {
+	Type              string                          `json:"Type"`
+	MessageID         string                          `json:"MessageId"`
+	TopicArn          string                          `json:"TopicArn"`
+	Message           *string                         `json:"Message"`
+	MessageAttributes map[string]snsEnvelopeAttribute `json:"MessageAttributes"`
+}
+
+type snsEnvelopeAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// unwrapSNSEnvelope returns a copy of the record with the SNS message as its body and the SNS message attributes added
+// to its message attributes. It returns false if the body isn't an SNS notification
+func unwrapSNSEnvelope(record events.SQSMessage) (events.SQSMessage, bool) {
+	var envelope snsEnvelope
+	err := json.Unmarshal([]byte(record.Body), &envelope)
+	if err != nil || envelope.Type != "Notification" || envelope.TopicArn == "" || envelope.Message == nil {
+		return record, false
+	}
+
+	attributes := make(map[string]events.SQSMessageAttribute, len(record.MessageAttributes)+len(envelope.MessageAttributes))
+	for k, v := range record.MessageAttributes {
+		attributes[k] = v
+	}
+	for k, v := range envelope.MessageAttributes {
+		attribute := events.SQSMessageAttribute{DataType: v.Type}
+		if v.Type == "Binary" {
+			b, err := base64.StdEncoding.DecodeString(v.Value)
+			if err != nil {
+				continue
+			}
+			attribute.BinaryValue = b
+		} else {
+			value := v.Value
+			attribute.StringValue = &value
+		}
+		attributes[k] = attribute
+	}
+
+	record.Body = *envelope.Message
+	record.MessageAttributes = attributes
+	return record, true
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnwrapSNSEnvelope(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		body        string
+		expectOK    bool
+		checkResult func(t *testing.T, record events.SQSMessage)
+	}{
+		{
+			name:     "Envelope unwrapped",
+			body:     `{"Type": "Notification", "MessageId": "m-1", "TopicArn": "arn:aws:sns:eu-west-1:123456789012:topic", "Message": "{\"Foo\": 1}", "MessageAttributes": {"tenant": {"Type": "String", "Value": "t-1"}, "blob": {"Type": "Binary", "Value": "aGk="}}}`,
+			expectOK: true,
+			checkResult: func(t *testing.T, record events.SQSMessage) {
+				assert.Equal(t, `{"Foo": 1}`, record.Body)
+				assert.Equal(t, "t-1", aws.ToString(record.MessageAttributes["tenant"].StringValue))
+				assert.Equal(t, []byte("hi"), record.MessageAttributes["blob"].BinaryValue)
+				assert.Equal(t, "existing", aws.ToString(record.MessageAttributes["source"].StringValue))
+			},
+		},
+		{
+			name: "Raw message unchanged",
+			body: `{"Foo": 1}`,
+			checkResult: func(t *testing.T, record events.SQSMessage) {
+				assert.Equal(t, `{"Foo": 1}`, record.Body)
+			},
+		},
+		{
+			name: "Not JSON",
+			body: "hello",
+			checkResult: func(t *testing.T, record events.SQSMessage) {
+				assert.Equal(t, "hello", record.Body)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := events.SQSMessage{Body: tc.body, MessageAttributes: map[string]events.SQSMessageAttribute{"source": {DataType: "String", StringValue: aws.String("existing")}}}
+			result, ok := unwrapSNSEnvelope(record)
+			assert.Equal(t, tc.expectOK, ok)
+			tc.checkResult(t, result)
+		})
+	}
+}
+
+func TestWithSNSEnvelope(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+
+	handler := GetTypedSQSHandler(func(ctx context.Context, body inputEvent, record events.SQSMessage) error {
+		assert.Equal(t, 1, body.Foo)
+		assert.Equal(t, "t-1", aws.ToString(record.MessageAttributes["tenant"].StringValue))
+		return nil
+	}, WithSNSEnvelope())
+	result, err := handler(ctx, events.SQSEvent{Records: []events.SQSMessage{{
+		ReceiptHandle: "r1",
+		Body:          `{"Type": "Notification", "TopicArn": "arn:aws:sns:eu-west-1:123456789012:topic", "Message": "{\"Foo\": 1}", "MessageAttributes": {"tenant": {"Type": "String", "Value": "t-1"}}}`,
+	}}})
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+}
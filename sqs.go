@@ -2,7 +2,10 @@ package handler
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -13,85 +16,506 @@ type SQSRecordProcessor func(ctx context.Context, record events.SQSMessage) erro
 
 type SQSHandler = Handler[events.SQSEvent, events.SQSEventResponse]
 
+// SQSOption configures the handler returned by GetSQSHandler
+type SQSOption func(*sqsOptions)
+
+type sqsOptions struct {
+	maxMessageAge time.Duration
+	onExpired     SQSRecordProcessor
+	queueDepth    func(ctx context.Context, queueARN string) (int, error)
+	//visibilityClient is used to delay the retry of records that fail with a RetryAfterError
+	visibilityClient SQSChangeMessageVisibilityAPI
+	startJitter      time.Duration
+	priority         func(record events.SQSMessage) int
+	fifoOrdering     bool
+	maxConcurrency   int
+	snsEnvelope      bool
+	s3Payloads       S3PayloadAPI
+	deleteS3Payloads bool
+	//visibilityHeartbeat extends the visibility timeout of in-flight records
+	visibilityHeartbeat *visibilityHeartbeat
+	loggerParams        func(record events.SQSMessage) []any
+	timeoutMargin       time.Duration
+	logInputEvents      bool
+	codec               Codec
+	failWholeBatch      bool
+	deduplicationKey    func(ctx context.Context, record events.SQSMessage) string
+	deadLetterClient    SQSSendMessageAPI
+	deadLetterQueueURL  string
+	recordTimeout       time.Duration
+	bodyValidators      []func(body interface{}) error
+	beforeBatch         func(ctx context.Context, records []events.SQSMessage) (context.Context, error)
+	backoffClient       SQSChangeMessageVisibilityAPI
+	backoffPolicy       BackoffPolicy
+	maxReceiveCount     int
+	gzipMaxBytes        int64
+	//sourceName identifies the records in the error returned by WithFailWholeBatch (default "sqs")
+	sourceName string
+}
+
+// WithMaxMessageAge acknowledges records that were sent more than maxAge ago (according to the SentTimestamp attribute)
+// without processing them. onExpired is called instead, if it is not nil; an error from onExpired is logged but the
+// record is still acknowledged
+func WithMaxMessageAge(maxAge time.Duration, onExpired SQSRecordProcessor) SQSOption {
+	return func(o *sqsOptions) {
+		o.maxMessageAge = maxAge
+		o.onExpired = onExpired
+	}
+}
+
+// WithStartJitter delays the start of processing each record by a random duration up to maxJitter, so that a batch of
+// records doesn't make simultaneous calls to a downstream service. The delay is skipped if it would cross the deadline
+func WithStartJitter(maxJitter time.Duration) SQSOption {
+	return func(o *sqsOptions) {
+		o.startJitter = maxJitter
+	}
+}
+
+// WithPriorityLanes processes the records of a batch in lanes by descending priority (a higher number is a higher
+// priority). Records in higher priority lanes are started first and get a larger share of the time remaining before the
+// deadline: with n lanes, lane k (counting from 0 for the highest priority) has until (n-k)/n of the remaining time. A
+// record that is still running when its lane's deadline is reached has its context cancelled with
+// ErrPriorityLaneDeadline and is returned to the queue, so that low priority stragglers don't hold up the batch
+func WithPriorityLanes(priority func(record events.SQSMessage) int) SQSOption {
+	return func(o *sqsOptions) {
+		o.priority = priority
+	}
+}
+
+// WithFIFOOrdering processes the records of each message group (the MessageGroupId attribute) sequentially, in the order
+// they were received, with different groups processed in parallel. Once a record in a group fails, the rest of the
+// group isn't processed and is reported as failed too, so that the group is redelivered in order. Records without a
+// group ID are processed in parallel as normal. Priority lanes are ignored when FIFO ordering is enabled
+func WithFIFOOrdering() SQSOption {
+	return func(o *sqsOptions) {
+		o.fifoOrdering = true
+	}
+}
+
+// WithMaxConcurrency limits how many records of a batch are processed at the same time, so that a large batch doesn't
+// overwhelm a rate-limited downstream service. Records that are still waiting to start when the deadline is reached are
+// returned to the queue without being processed
+func WithMaxConcurrency(n int) SQSOption {
+	return func(o *sqsOptions) {
+		o.maxConcurrency = n
+	}
+}
+
+// WithSNSEnvelope unwraps the bodies of messages delivered by an SNS subscription without raw message delivery. If a
+// body is an SNS notification envelope, the record's body is replaced with the SNS message and the SNS message
+// attributes are added to the record's message attributes before it is processed. Other bodies are left unchanged
+func WithSNSEnvelope() SQSOption {
+	return func(o *sqsOptions) {
+		o.snsEnvelope = true
+	}
+}
+
+// WithRecordTimeout limits the time each record can take to process. A record that is still running after timeout has
+// its context cancelled with ErrRecordTimeout and is reported as failed, without waiting for the invocation deadline.
+// The timeout starts after any start jitter and doesn't include time spent waiting for WithMaxConcurrency
+func WithRecordTimeout(timeout time.Duration) SQSOption {
+	return func(o *sqsOptions) {
+		o.recordTimeout = timeout
+	}
+}
+
+// WithBeforeBatch calls hook once per batch, with all the records to be processed, before any are processed. The context
+// returned by hook is used to process the records, so the hook can prefetch data shared by the batch (e.g. with one
+// DynamoDB BatchGetItem rather than a GetItem per record) and add it to the context. If hook fails, every record that
+// would have been processed fails (duplicates removed by WithDeduplication are still successes). The records have the bodies they will be processed with (see WithSNSEnvelope, WithS3Payloads and
+// WithGzipBodies), and records whose bodies can't be transformed are left out. See WithTypedBeforeBatch for a hook that
+// gets the decoded bodies
+func WithBeforeBatch(hook func(ctx context.Context, records []events.SQSMessage) (context.Context, error)) SQSOption {
+	return func(o *sqsOptions) {
+		o.beforeBatch = hook
+	}
+}
+
+// WithMaxReceiveCount sets the maxReceiveCount of the queue's redrive policy. When a record fails on its final attempt
+// (so it will be moved to the dead-letter queue, or dropped if the queue doesn't have one), it is logged at error level
+// with its body and a MessageExhausted metric is emitted
+func WithMaxReceiveCount(maxReceiveCount int) SQSOption {
+	return func(o *sqsOptions) {
+		o.maxReceiveCount = maxReceiveCount
+	}
+}
+
+// WithFailWholeBatch returns an error from the handler if any record fails, instead of reporting the failed records in
+// the batch response. Use this for event source mappings that aren't configured with ReportBatchItemFailures, where
+// the batch response is ignored and every record of the batch is retried after an error
+func WithFailWholeBatch() SQSOption {
+	return func(o *sqsOptions) {
+		o.failWholeBatch = true
+	}
+}
+
 // GetSQSHandler returns a lambda handler that will process each SQS message in parallel using the provided processRecord function
-func GetSQSHandler(processRecord SQSRecordProcessor) Handler[events.SQSEvent, events.SQSEventResponse] {
+func GetSQSHandler(processRecord SQSRecordProcessor, opts ...SQSOption) Handler[events.SQSEvent, events.SQSEventResponse] {
+	options := sqsOptions{sourceName: "sqs"}
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-	process := func(ctx context.Context, record events.SQSMessage, successChannel chan bool) {
-		err := processRecord(ctx, record)
+	processRecordWithDeadline := func(ctx context.Context, record events.SQSMessage, body *sqsBody, laneDeadline time.Time) (succeeded bool) {
+		ctx = ContextWithSQSRecordInfo(ContextWithStages(ctx), record)
+		defer trackRecord(ctx, "messageId", record.MessageId)()
+		if options.loggerParams != nil {
+			ctx = GetNewContextWithLogger(ctx, GetLogger(ctx).With(options.loggerParams(record)...))
+		}
+		start := GetClock(ctx).Now()
+		defer func() {
+			emitRecordDuration(ctx, GetClock(ctx).Now().Sub(start), succeeded)
+			if !succeeded && options.maxReceiveCount > 0 && GetSQSReceiveCount(record) >= options.maxReceiveCount {
+				logMessageExhausted(ctx, record)
+			}
+		}()
+		received := record
+		if options.snsEnvelope {
+			//The unwrap stage is added when the body is loaded
+			record, _ = unwrapSNSEnvelope(record)
+		}
+		if options.logInputEvents {
+			GetLogger(ctx).Info("processing sqs message", "messageId", record.MessageId, "body", maskLogBody(record.Body), "attributes", record.Attributes)
+		}
+		cost := startCostTimer(ctx)
+		defer func() {
+			cost.report(ctx, "sqs message cost", false, "messageId", record.MessageId)
+		}()
+		hopCtx, loopErr := ContextWithHopCount(ctx, getSQSHopCount(record))
+		if loopErr != nil {
+			//Retrying a looping message would only loop again, so it goes to the dead-letter queue (or is dropped)
+			loopErr = NonRetryable(StageErr(ctx, "loop guard", loopErr))
+		}
+		ctx = hopCtx
+
+		if loopErr == nil && options.maxMessageAge > 0 && isSQSMessageExpired(GetClock(ctx).Now(), record, options.maxMessageAge) {
+			AddStage(ctx, "message expired")
+			GetLogger(ctx).Warn("skipping expired sqs message", "messageId", record.MessageId, "sentTimestamp", record.Attributes["SentTimestamp"])
+			if options.onExpired != nil {
+				err := options.onExpired(ctx, record)
+				if err != nil {
+					GetLogger(ctx).Error("expired sqs message callback failed", "errStr", err.Error(), "errObj", err, "stages", getStagesLogValue(ctx))
+				}
+			}
+			return true
+		}
+
+		if loopErr == nil && options.startJitter > 0 {
+			_ = Sleep(ctx, time.Duration(GetRand(ctx).Int64N(int64(options.startJitter))))
+		}
+
+		if !laneDeadline.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadlineCause(ctx, laneDeadline, ErrPriorityLaneDeadline)
+			defer cancel()
+		}
+		if options.recordTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, options.recordTimeout, ErrRecordTimeout)
+			defer cancel()
+		}
+
+		var err error
+		if loopErr != nil {
+			err = loopErr
+		} else if ctx.Err() != nil {
+			//The record's time ran out before it started (e.g. during the start jitter)
+			err = ctx.Err()
+		} else {
+			stopHeartbeat := func() {}
+			if options.visibilityHeartbeat != nil {
+				stopHeartbeat = options.visibilityHeartbeat.start(ctx, record)
+			}
+			record, err = body.load(ctx, &options, received)
+			if err == nil {
+				err = processRecord(ctx, record)
+			}
+			if err == nil && body.pointer != nil && options.deleteS3Payloads {
+				deleteS3Payload(ctx, options.s3Payloads, *body.pointer)
+			}
+			stopHeartbeat()
+		}
+		err = withCancelCause(ctx, err)
+		if IsDeadlineExceeded(ctx, err) {
+			//Not a problem with the message, so it is always left on the queue to be retried
+			err = flagDeadlineExceeded(ctx, err)
+			GetLogger(ctx).Warn("sqs message processing deadline exceeded", "errStr", err.Error(), "body", maskLogBody(record.Body), "retryable", true, "stages", getStagesLogValue(ctx))
+			return false
+		}
+		if err != nil && options.deadLetterClient != nil && IsNonRetryable(err) {
+			dlqErr := SendToDeadLetterQueue(ctx, options.deadLetterClient, options.deadLetterQueueURL, record, err)
+			if dlqErr == nil {
+				GetLogger(ctx).Warn("sent non-retryable sqs message to dead-letter queue", "errStr", err.Error(), "body", maskLogBody(record.Body), "errObj", err, "stages", getStagesLogValue(ctx))
+				return true
+			}
+			GetLogger(ctx).Warn("failed to send message to dead-letter queue", "error", dlqErr.Error())
+		} else if err != nil && IsNonRetryable(err) {
+			//Retrying would fail in the same way, so the message is acknowledged rather than redelivered until the redrive
+			//policy moves it (or forever, if the queue doesn't have one)
+			AddStage(ctx, "drop non-retryable message")
+			GetLogger(ctx).Error("dropping non-retryable sqs message", "errStr", err.Error(), "body", maskLogBody(record.Body), "errObj", err, "stages", getStagesLogValue(ctx))
+			EmitMetric(ctx, "MessageDropped", 1, UnitCount, map[string]string{"Handler": os.Getenv("AWS_LAMBDA_FUNCTION_NAME")})
+			return true
+		}
 		if err != nil {
+			if delay, ok := GetRetryAfter(err); ok && options.visibilityClient != nil {
+				delayErr := delayMessage(ctx, options.visibilityClient, record, delay)
+				if delayErr != nil {
+					GetLogger(ctx).Warn("failed to delay message", "error", delayErr.Error())
+				}
+			} else if options.backoffClient != nil && !IsNonRetryable(err) {
+				delay := options.backoffPolicy.Delay(ctx, max(GetSQSReceiveCount(record)-1, 0))
+				delayErr := delayMessage(ctx, options.backoffClient, record, delay)
+				if delayErr != nil {
+					GetLogger(ctx).Warn("failed to delay message", "error", delayErr.Error())
+				}
+			}
 			logger := GetLogger(ctx)
-			logger.Error("sqs messaging processing failed", "errStr", err.Error(), "body", record.Body, "errObj", err)
-			successChannel <- false
-			return
+			logger.Error("sqs messaging processing failed", "errStr", err.Error(), "body", maskLogBody(record.Body), "errObj", err, "stages", getStagesLogValue(ctx))
+			return false
 		}
-		successChannel <- true
+		return true
 	}
 
 	return func(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+		if options.timeoutMargin > 0 {
+			ctx = context.WithValue(ctx, deadlineMarginKey, options.timeoutMargin)
+		}
+		if options.codec != nil {
+			ctx = context.WithValue(ctx, codecKey, options.codec)
+		}
+		if len(options.bodyValidators) > 0 {
+			ctx = context.WithValue(ctx, bodyValidatorsKey, options.bodyValidators)
+		}
+		if options.maxConcurrency > 0 {
+			ctx = context.WithValue(ctx, maxWorkersKey, options.maxConcurrency)
+		}
 
-		deadline, hasDeadline := ctx.Deadline()
-		if !hasDeadline {
-			return events.SQSEventResponse{}, errors.New("context must have a deadline set")
+		if options.queueDepth != nil && len(event.Records) > 0 {
+			depth, err := options.queueDepth(ctx, event.Records[0].EventSourceARN)
+			if err != nil {
+				GetLogger(ctx).Warn("failed to fetch queue depth", "error", err.Error())
+			} else {
+				ctx = context.WithValue(ctx, queueDepthKey, depth)
+			}
 		}
-		deadline = deadline.Add(-500 * time.Millisecond)
-		subCtx, cancel := context.WithDeadline(ctx, deadline)
-		defer cancel()
 
-		//Process each SQS message in its own go routine
-		routines := []*routineData{}
-		for _, record := range event.Records {
-			c := make(chan bool)
-			data := routineData{
-				SuccessChannel: c,
-				Record:         record,
-				TimeoutTimer:   time.NewTimer(time.Until(deadline)),
+		records := event.Records
+		bodies := newSQSBodies(records)
+		if options.deduplicationKey != nil {
+			unique := deduplicateSQSRecords(ctx, records, func(i int) string {
+				record, err := bodies[i].get(ctx, &options, records[i])
+				if err != nil {
+					//The record will fail when it is processed
+					return ""
+				}
+				return options.deduplicationKey(ctx, record)
+			})
+			records, bodies = selectSQSRecords(records, unique), selectSQSRecords(bodies, unique)
+		}
+		if options.beforeBatch != nil && len(records) > 0 {
+			hookCtx, err := options.beforeBatch(ctx, getSQSHookRecords(ctx, &options, records, bodies))
+			if err != nil {
+				err = StageErr(ctx, "before batch", err)
+				GetLogger(ctx).Error("sqs batch pre-processing failed", "errStr", err.Error(), "errObj", err, "stages", getStagesLogValue(ctx))
+				if options.failWholeBatch {
+					return events.SQSEventResponse{}, err
+				}
+				//Duplicates that weren't processed are successes, so only the records to be processed fail
+				return SQSAllFail(events.SQSEvent{Records: records}), nil
 			}
-			routines = append(routines, &data)
-			go process(subCtx, record, c)
+			ctx = hookCtx
 		}
 
-		//For each go routine, start another routine to wait for the result or the timeout
-		wg := sync.WaitGroup{}
-		for _, routine := range routines {
-			wg.Add(1)
-			go asyncWaitForResult(ctx, routine, &wg)
+		var results []bool
+		if options.fifoOrdering {
+			var err error
+			results, err = processSQSGroups(ctx, records, func(ctx context.Context, i int) bool {
+				return processRecordWithDeadline(ctx, records[i], bodies[i], time.Time{})
+			})
+			if err != nil {
+				return events.SQSEventResponse{}, err
+			}
+		} else {
+			//Records are started in order, which is only changed by priority lanes
+			order := make([]int, len(records))
+			for i := range order {
+				order[i] = i
+			}
+			laneDeadlines := make([]time.Time, len(records))
+			if deadline, ok := ctx.Deadline(); ok && options.priority != nil {
+				priorities := make([]int, len(records))
+				for i, record := range records {
+					priorities[i] = options.priority(record)
+				}
+				sort.SliceStable(order, func(a, b int) bool {
+					return priorities[order[a]] > priorities[order[b]]
+				})
+				laneDeadlines = getPriorityLaneDeadlines(GetClock(ctx).Now(), deadline.Add(-getDeadlineMargin(ctx)), priorities)
+			}
+
+			//Process the SQS messages on a pool of workers (see WithMaxConcurrency), starting in priority order
+			ordered, err := processWithDeadline(ctx, len(records), func(ctx context.Context, i int) bool {
+				return processRecordWithDeadline(ctx, records[order[i]], bodies[order[i]], laneDeadlines[order[i]])
+			}, func(i int) {
+				GetLogger(ctx).Error("sqs message processing timed-out", "body", maskLogBody(records[order[i]].Body))
+			})
+			if err != nil {
+				return events.SQSEventResponse{}, err
+			}
+			results = make([]bool, len(records))
+			for i, failed := range ordered {
+				results[order[i]] = failed
+			}
 		}
 
-		//Collect the failures
-		wg.Wait()
-		failures := []events.SQSBatchItemFailure{}
-		for _, r := range routines {
-			if r.failed || r.timedOut {
-				failures = append(failures, events.SQSBatchItemFailure{ItemIdentifier: r.Record.ReceiptHandle})
+		if options.failWholeBatch {
+			if failed := countFailed(results); failed > 0 {
+				return events.SQSEventResponse{}, fmt.Errorf("%d of %d %s messages failed", failed, len(results), options.sourceName)
 			}
 		}
 
+		//Duplicates that weren't processed are successes, so only the processed records can be failures
+		ids := make([]string, len(records))
+		for i, record := range records {
+			ids[i] = record.ReceiptHandle
+		}
+		failures := []events.SQSBatchItemFailure{}
+		for _, id := range getBatchItemFailures(ctx, ids, results) {
+			failures = append(failures, events.SQSBatchItemFailure{ItemIdentifier: id})
+		}
 		return events.SQSEventResponse{BatchItemFailures: failures}, nil
 	}
 }
 
-func asyncWaitForResult(ctx context.Context, routine *routineData, wg *sync.WaitGroup) {
-	select {
-	case success := <-routine.SuccessChannel:
-		routine.TimeoutTimer.Stop()
-		if !success {
-			routine.failed = true
+// getSQSHookRecords returns the records passed to the before batch hook, with their bodies transformed as they will be
+// when they are processed. Records whose bodies can't be transformed are logged and left out, as they will fail
+func getSQSHookRecords(ctx context.Context, options *sqsOptions, records []events.SQSMessage, bodies []*sqsBody) []events.SQSMessage {
+	hookRecords := make([]events.SQSMessage, 0, len(records))
+	for i := range records {
+		record, err := bodies[i].get(ctx, options, records[i])
+		if err != nil {
+			GetLogger(ctx).Warn("leaving sqs message out of before batch hook", "messageId", record.MessageId, "error", err.Error())
+			continue
+		}
+		hookRecords = append(hookRecords, record)
+	}
+	return hookRecords
+}
+
+// selectSQSRecords returns the items at the given indices
+func selectSQSRecords[E interface{}](items []E, indices []int) []E {
+	selected := make([]E, len(indices))
+	for n, i := range indices {
+		selected[n] = items[i]
+	}
+	return selected
+}
+
+// processSQSGroups processes the records of each message group sequentially (see WithFIFOOrdering) and returns whether
+// each record failed. Records that weren't processed because an earlier record in their group failed, or because the
+// group timed-out, are reported as failed
+func processSQSGroups(ctx context.Context, records []events.SQSMessage, process func(ctx context.Context, i int) bool) ([]bool, error) {
+	groups := [][]int{}
+	groupIndex := map[string]int{}
+	for i, record := range records {
+		groupID := record.Attributes["MessageGroupId"]
+		g, found := groupIndex[groupID]
+		if !found || groupID == "" {
+			g = len(groups)
+			groups = append(groups, []int{})
+			if groupID != "" {
+				groupIndex[groupID] = g
+			}
 		}
-		wg.Done()
-	case <-routine.TimeoutTimer.C:
-		GetLogger(ctx).Error("sqs message processing timed-out", "body", routine.Record.Body)
-		routine.timedOut = true
-		wg.Done()
+		groups[g] = append(groups[g], i)
 	}
+
+	//Records in timed-out groups may still succeed after the results have been collected, so access is synchronised
+	mu := sync.Mutex{}
+	succeeded := make([]bool, len(records))
+	_, err := processWithDeadline(ctx, len(groups), func(ctx context.Context, g int) bool {
+		for n, i := range groups[g] {
+			if !process(ctx, i) {
+				if skipped := len(groups[g]) - n - 1; skipped > 0 {
+					GetLogger(ctx).Warn("failing remaining sqs messages in group", "messageGroupId", records[i].Attributes["MessageGroupId"], "count", skipped)
+				}
+				return false
+			}
+			mu.Lock()
+			succeeded[i] = true
+			mu.Unlock()
+		}
+		return true
+	}, func(g int) {
+		GetLogger(ctx).Error("sqs message group processing timed-out", "messageGroupId", records[groups[g][0]].Attributes["MessageGroupId"])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	failed := make([]bool, len(records))
+	for i := range records {
+		failed[i] = !succeeded[i]
+	}
+	return failed, nil
 }
 
-type routineData struct {
-	SuccessChannel chan bool
-	Record         events.SQSMessage
-	//Need a timer for each goroutine because the channel only receives one value
-	TimeoutTimer *time.Timer
-	failed       bool
-	timedOut     bool
+// getPriorityLaneDeadlines returns the deadline for each record's lane. Lanes are the distinct priorities in descending
+// order, and lane k of n has until (n-k)/n of the time between now and deadline
+func getPriorityLaneDeadlines(now time.Time, deadline time.Time, priorities []int) []time.Time {
+	distinct := []int{}
+	seen := map[int]bool{}
+	for _, p := range priorities {
+		if !seen[p] {
+			seen[p] = true
+			distinct = append(distinct, p)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(distinct)))
+
+	remaining := deadline.Sub(now)
+	laneDeadline := map[int]time.Time{}
+	for k, p := range distinct {
+		laneDeadline[p] = now.Add(remaining * time.Duration(len(distinct)-k) / time.Duration(len(distinct)))
+	}
+
+	deadlines := make([]time.Time, len(priorities))
+	for i, p := range priorities {
+		deadlines[i] = laneDeadline[p]
+	}
+	return deadlines
+}
+
+// emitRecordDuration emits the time taken to process a record, with the handler (function) name and the outcome as
+// dimensions
+func emitRecordDuration(ctx context.Context, duration time.Duration, succeeded bool) {
+	outcome := "success"
+	if !succeeded {
+		outcome = "failure"
+	}
+	EmitMetric(ctx, "RecordDuration", float64(duration.Milliseconds()), UnitMilliseconds, map[string]string{
+		"Handler": os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+		"Outcome": outcome,
+	})
+}
+
+// logMessageExhausted logs a record that has failed for the last time before leaving the queue
+func logMessageExhausted(ctx context.Context, record events.SQSMessage) {
+	GetLogger(ctx).Error("sqs message failed on final attempt", "messageId", record.MessageId, "receiveCount", GetSQSReceiveCount(record), "body", maskLogBody(record.Body), "attributes", record.Attributes, "stages", getStagesLogValue(ctx))
+	EmitMetric(ctx, "MessageExhausted", 1, UnitCount, map[string]string{"Handler": os.Getenv("AWS_LAMBDA_FUNCTION_NAME")})
+}
+
+// isSQSMessageExpired returns true if the record was sent more than maxAge ago. Records without a valid SentTimestamp
+// are never treated as expired
+func isSQSMessageExpired(now time.Time, record events.SQSMessage, maxAge time.Duration) bool {
+	sent, err := strconv.ParseInt(record.Attributes["SentTimestamp"], 10, 64)
+	if err != nil {
+		return false
+	}
+	return now.Sub(time.UnixMilli(sent)) > maxAge
 }
 
 func SQSAllFail(event events.SQSEvent) events.SQSEventResponse {
@@ -2,11 +2,17 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,6 +26,7 @@ func TestGetSQSHandler(t *testing.T) {
 	testcases := []struct {
 		name          string
 		processRecord SQSRecordProcessor
+		options       []SQSOption
 		checkResult   func(t *testing.T, result events.SQSEventResponse)
 		event         events.SQSEvent
 	}{
@@ -68,7 +75,8 @@ func TestGetSQSHandler(t *testing.T) {
 		{
 			name: "Messages time-out",
 			processRecord: func(ctx context.Context, record events.SQSMessage) error {
-				time.Sleep(10 * time.Second)
+				GetClock(ctx).(*FakeClock).Advance(10 * time.Second)
+				<-ctx.Done()
 				return nil
 			},
 			checkResult: func(t *testing.T, result events.SQSEventResponse) {
@@ -83,20 +91,114 @@ func TestGetSQSHandler(t *testing.T) {
 		},
 		{
 			name: "One message time-out",
+			processRecord: func(ctx context.Context, record events.SQSMessage) error {
+				switch record.ReceiptHandle {
+				case "5a3e8884-4ff1-46f1-8617-b3f483a79956":
+					<-ctx.Done()
+				case "0b6d7f0e-7c55-4b6c-9d8e-3f1c2a4b5d6e":
+					//With two workers, this message only starts once the first message has finished and its result
+					//has been sent, as the second message never finishes
+					GetClock(ctx).(*FakeClock).Advance(10 * time.Second)
+					<-ctx.Done()
+				}
+				return nil
+			},
+			options: []SQSOption{WithMaxConcurrency(2)},
+			checkResult: func(t *testing.T, result events.SQSEventResponse) {
+				expected := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{
+					{ItemIdentifier: "5a3e8884-4ff1-46f1-8617-b3f483a79956"},
+					{ItemIdentifier: "0b6d7f0e-7c55-4b6c-9d8e-3f1c2a4b5d6e"},
+				}}
+				assert.Equal(t, expected, result)
+			},
+			event: events.SQSEvent{Records: []events.SQSMessage{
+				{ReceiptHandle: "2ecc59ae-ea1a-462a-8fca-d835858fc470"},
+				{ReceiptHandle: "5a3e8884-4ff1-46f1-8617-b3f483a79956"},
+				{ReceiptHandle: "0b6d7f0e-7c55-4b6c-9d8e-3f1c2a4b5d6e"},
+			}},
+		},
+		{
+			name: "One message time-out processing sequentially",
 			processRecord: func(ctx context.Context, record events.SQSMessage) error {
 				if record.ReceiptHandle == "5a3e8884-4ff1-46f1-8617-b3f483a79956" {
-					time.Sleep(10 * time.Second)
+					//The other message has already finished, as the records are processed one at a time
+					GetClock(ctx).(*FakeClock).Advance(10 * time.Second)
+					<-ctx.Done()
 					return nil
 				}
 				return nil
 			},
+			options: []SQSOption{WithMaxConcurrency(1)},
 			checkResult: func(t *testing.T, result events.SQSEventResponse) {
 				expected := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{
 					{ItemIdentifier: "5a3e8884-4ff1-46f1-8617-b3f483a79956"},
 				}}
 				assert.Equal(t, expected, result)
 			},
-			event: twoRecordEvent,
+			event: events.SQSEvent{Records: []events.SQSMessage{
+				{ReceiptHandle: "2ecc59ae-ea1a-462a-8fca-d835858fc470"},
+				{ReceiptHandle: "5a3e8884-4ff1-46f1-8617-b3f483a79956"},
+			}},
+		},
+		{
+			name: "Message processing exceeds deadline",
+			processRecord: func(ctx context.Context, record events.SQSMessage) error {
+				return fmt.Errorf("call api: %w", context.DeadlineExceeded)
+			},
+			checkResult: func(t *testing.T, result events.SQSEventResponse) {
+				expected := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{
+					{ItemIdentifier: "25209c2d-32e5-4117-9c09-dc4d3e954ade"},
+				}}
+				assert.Equal(t, expected, result)
+			},
+			event: events.SQSEvent{Records: []events.SQSMessage{
+				{ReceiptHandle: "25209c2d-32e5-4117-9c09-dc4d3e954ade"},
+			}},
+		},
+		{
+			name: "Message loop detected",
+			processRecord: func(ctx context.Context, record events.SQSMessage) error {
+				return errors.New("looping message should not be processed")
+			},
+			checkResult: func(t *testing.T, result events.SQSEventResponse) {
+				//Retrying would loop again, so the message is dropped as there isn't a dead-letter queue
+				expected := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+				assert.Equal(t, expected, result)
+			},
+			event: events.SQSEvent{Records: []events.SQSMessage{
+				{
+					ReceiptHandle: "25209c2d-32e5-4117-9c09-dc4d3e954ade",
+					MessageAttributes: map[string]events.SQSMessageAttribute{
+						HopCountAttribute: {StringValue: aws.String("11"), DataType: "Number"},
+					},
+				},
+			}},
+		},
+		{
+			name: "Expired message acknowledged without processing",
+			processRecord: func(ctx context.Context, record events.SQSMessage) error {
+				if record.ReceiptHandle == "5a3e8884-4ff1-46f1-8617-b3f483a79956" {
+					return errors.New("expired message should not be processed")
+				}
+				return nil
+			},
+			options: []SQSOption{WithMaxMessageAge(time.Minute, func(ctx context.Context, record events.SQSMessage) error {
+				return errors.New("callback failures are only logged")
+			})},
+			checkResult: func(t *testing.T, result events.SQSEventResponse) {
+				expected := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+				assert.Equal(t, expected, result)
+			},
+			event: events.SQSEvent{Records: []events.SQSMessage{
+				{
+					ReceiptHandle: "5a3e8884-4ff1-46f1-8617-b3f483a79956",
+					Attributes:    map[string]string{"SentTimestamp": strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)},
+				},
+				{
+					ReceiptHandle: "2ecc59ae-ea1a-462a-8fca-d835858fc470",
+					Attributes:    map[string]string{"SentTimestamp": strconv.FormatInt(time.Now().UnixMilli(), 10)},
+				},
+			}},
 		},
 		{
 			name: "invoke with single record",
@@ -116,8 +218,9 @@ func TestGetSQSHandler(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
 			defer cancel()
+			ctx = ContextWithClock(ctx, NewFakeClock(time.Now()))
 
-			handler := GetSQSHandler(tc.processRecord)
+			handler := GetSQSHandler(tc.processRecord, tc.options...)
 			logger := GetLogger(ctx)
 			logger.Info("Start test")
 			result, err := handler(ctx, tc.event)
@@ -127,3 +230,246 @@ func TestGetSQSHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestWithStartJitter(t *testing.T) {
+	mu := sync.Mutex{}
+	starts := []time.Time{}
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		starts = append(starts, time.Now())
+		return nil
+	}, WithStartJitter(200*time.Millisecond))
+
+	event := events.SQSEvent{}
+	for i := 0; i < 10; i++ {
+		event.Records = append(event.Records, events.SQSMessage{ReceiptHandle: strconv.Itoa(i)})
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, event)
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+	assert.Len(t, starts, 10)
+
+	first, last := starts[0], starts[0]
+	for _, start := range starts {
+		if start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+	assert.Greater(t, last.Sub(first), 10*time.Millisecond)
+}
+
+func TestWithPriorityLanes(t *testing.T) {
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		//Every record takes longer than the time given to the low priority lane
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(1200 * time.Millisecond):
+			return nil
+		}
+	}, WithPriorityLanes(func(record events.SQSMessage) int {
+		p, _ := strconv.Atoi(aws.ToString(record.MessageAttributes["priority"].StringValue))
+		return p
+	}))
+
+	event := events.SQSEvent{}
+	for i, priority := range []string{"1", "2", "1", "2"} {
+		event.Records = append(event.Records, events.SQSMessage{
+			ReceiptHandle:     strconv.Itoa(i),
+			MessageAttributes: map[string]events.SQSMessageAttribute{"priority": {DataType: "Number", StringValue: aws.String(priority)}},
+		})
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, event)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []events.SQSBatchItemFailure{{ItemIdentifier: "0"}, {ItemIdentifier: "2"}}, result.BatchItemFailures)
+}
+
+func TestGetPriorityLaneDeadlines(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	deadline := now.Add(3 * time.Second)
+
+	deadlines := getPriorityLaneDeadlines(now, deadline, []int{0, 5, 1, 5})
+	assert.Equal(t, []time.Time{now.Add(time.Second), deadline, now.Add(2 * time.Second), deadline}, deadlines)
+}
+
+func TestWithFIFOOrdering(t *testing.T) {
+	mu := sync.Mutex{}
+	processed := map[string][]string{}
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		group := record.Attributes["MessageGroupId"]
+		processed[group] = append(processed[group], record.ReceiptHandle)
+		if record.Body == "fail" {
+			return errors.New("something bad happened")
+		}
+		return nil
+	}, WithFIFOOrdering())
+
+	newRecord := func(receiptHandle string, group string, body string) events.SQSMessage {
+		return events.SQSMessage{ReceiptHandle: receiptHandle, Body: body, Attributes: map[string]string{"MessageGroupId": group}}
+	}
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		newRecord("a1", "a", "ok"),
+		newRecord("b1", "b", "ok"),
+		newRecord("a2", "a", "fail"),
+		newRecord("b2", "b", "ok"),
+		newRecord("a3", "a", "ok"),
+		newRecord("a4", "a", "ok"),
+	}}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, event)
+	assert.Nil(t, err)
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "a2"}, {ItemIdentifier: "a3"}, {ItemIdentifier: "a4"}}, result.BatchItemFailures)
+	assert.Equal(t, map[string][]string{"a": {"a1", "a2"}, "b": {"b1", "b2"}}, processed)
+}
+
+func TestWithMaxConcurrency(t *testing.T) {
+	mu := sync.Mutex{}
+	running, maxRunning := 0, 0
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}, WithMaxConcurrency(3))
+
+	event := events.SQSEvent{}
+	for i := 0; i < 20; i++ {
+		event.Records = append(event.Records, events.SQSMessage{ReceiptHandle: strconv.Itoa(i)})
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, event)
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+	assert.Equal(t, 3, maxRunning)
+}
+
+func TestWithRecordTimeout(t *testing.T) {
+	causes := make(chan error, 2)
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		if record.Body == "stuck" {
+			<-ctx.Done()
+			causes <- context.Cause(ctx)
+			return ctx.Err()
+		}
+		return nil
+	}, WithRecordTimeout(10*time.Millisecond))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(5*time.Second))
+	defer cancel()
+	start := time.Now()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1", Body: "ok"}, {ReceiptHandle: "2", Body: "stuck"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "2"}}, result.BatchItemFailures)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, ErrRecordTimeout, <-causes)
+}
+
+func TestWithRecordTimeoutNonRetryableError(t *testing.T) {
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		<-ctx.Done()
+		//The error isn't caused by the deadline, so it isn't retried
+		return NonRetryable(errors.New("foo must be odd"))
+	}, WithRecordTimeout(10*time.Millisecond))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1"}}})
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+}
+
+func TestWithFailWholeBatch(t *testing.T) {
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		if record.Body == "fail" {
+			return errors.New("oops")
+		}
+		return nil
+	}, WithFailWholeBatch())
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1", Body: "ok"}, {ReceiptHandle: "2", Body: "ok"}}})
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+
+	result, err = h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1", Body: "ok"}, {ReceiptHandle: "2", Body: "fail"}}})
+	assert.EqualError(t, err, "1 of 2 sqs messages failed")
+	assert.Empty(t, result.BatchItemFailures)
+}
+
+func TestEmitRecordDuration(t *testing.T) {
+	t.Setenv("METRIC_NAMESPACE", "MyService")
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "order-processor")
+	buf := captureMetrics(t)
+
+	emitRecordDuration(context.Background(), 1500*time.Millisecond, false)
+
+	entry := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, 1500.0, entry["RecordDuration"])
+	assert.Equal(t, "order-processor", entry["Handler"])
+	assert.Equal(t, "failure", entry["Outcome"])
+}
+
+func TestWithMaxReceiveCount(t *testing.T) {
+	t.Setenv("METRIC_NAMESPACE", "MyService")
+	buf := captureMetrics(t)
+
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		if record.Body == "fail" {
+			return errors.New("oops")
+		}
+		return nil
+	}, WithMaxReceiveCount(3))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	_, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "1", Body: "fail", Attributes: map[string]string{"ApproximateReceiveCount": "3"}},
+		{ReceiptHandle: "2", Body: "fail", Attributes: map[string]string{"ApproximateReceiveCount": "2"}},
+		{ReceiptHandle: "3", Body: "ok", Attributes: map[string]string{"ApproximateReceiveCount": "3"}},
+	}})
+	assert.Nil(t, err)
+
+	exhausted := 0
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		entry := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+		if _, found := entry["MessageExhausted"]; found {
+			exhausted++
+		}
+	}
+	assert.Equal(t, 1, exhausted)
+}
+
+func TestWithFailWholeBatchDeduplication(t *testing.T) {
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		return errors.New("oops")
+	}, WithFailWholeBatch(), WithDeduplication(SQSDeduplicationID))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	_, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "1", Attributes: map[string]string{"MessageDeduplicationId": "a"}},
+		{ReceiptHandle: "2", Attributes: map[string]string{"MessageDeduplicationId": "a"}},
+	}})
+	//Only the processed messages are counted
+	assert.EqualError(t, err, "1 of 1 sqs messages failed")
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// sqsBody is the body of a record after it has been transformed by the options that change it: the SNS envelope is
+// unwrapped (WithSNSEnvelope), then the payload is fetched from S3 (WithS3Payloads), then it is decompressed
+// (WithGzipBodies). The transformation runs at most once per record, so the deduplication key, the before batch hook
+// and the record processor all see the same body
+type sqsBody struct {
+	once   sync.Once
+	record events.SQSMessage
+	//stages are the stages of the transformation, added to the record's stages when it is processed
+	stages []string
+	//pointer is set if the body was fetched from S3
+	pointer *s3Pointer
+	err     error
+}
+
+// newSQSBodies returns an untransformed body for each record
+func newSQSBodies(records []events.SQSMessage) []*sqsBody {
+	bodies := make([]*sqsBody, len(records))
+	for i := range bodies {
+		bodies[i] = &sqsBody{}
+	}
+	return bodies
+}
+
+// get returns the record with its transformed body, transforming it if it hasn't been already. The stages of the
+// transformation aren't added to ctx (see load)
+func (b *sqsBody) get(ctx context.Context, options *sqsOptions, record events.SQSMessage) (events.SQSMessage, error) {
+	b.once.Do(func() {
+		stagesCtx := ContextWithStages(ctx)
+		b.record, b.pointer, b.err = transformSQSBody(stagesCtx, options, record)
+		b.stages = getStageDescriptions(stagesCtx)
+	})
+	return b.record, b.err
+}
+
+// load is like get, but adds the stages of the transformation to the record's context
+func (b *sqsBody) load(ctx context.Context, options *sqsOptions, record events.SQSMessage) (events.SQSMessage, error) {
+	record, err := b.get(ctx, options, record)
+	for _, stage := range b.stages {
+		AddStage(ctx, stage)
+	}
+	return record, err
+}
+
+// transformSQSBody applies the body transformations (see sqsBody) to the record
+func transformSQSBody(ctx context.Context, options *sqsOptions, record events.SQSMessage) (events.SQSMessage, *s3Pointer, error) {
+	if options.snsEnvelope {
+		if unwrapped, ok := unwrapSNSEnvelope(record); ok {
+			AddStage(ctx, "unwrap sns envelope")
+			record = unwrapped
+		}
+	}
+
+	var pointer *s3Pointer
+	if options.s3Payloads != nil {
+		if p, ok := parseS3Pointer(record.Body); ok {
+			body, err := fetchS3Payload(ctx, options.s3Payloads, p)
+			if err != nil {
+				return record, nil, err
+			}
+			record.Body = body
+			pointer = &p
+		}
+	}
+
+	if options.gzipMaxBytes > 0 {
+		if compressed, ok := getGzipBody(record.Body); ok {
+			body, err := gunzipBody(ctx, compressed, options.gzipMaxBytes)
+			if err != nil {
+				return record, pointer, NonRetryable(StageErr(ctx, "decompress body", err))
+			}
+			AddStage(ctx, "decompress body")
+			record.Body = body
+		}
+	}
+	return record, pointer, nil
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQSBody(t *testing.T) {
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write([]byte(`{"Foo":1}`))
+	_ = gz.Close()
+	client := &mockS3PayloadClient{content: buf.String()}
+	options := &sqsOptions{snsEnvelope: true, s3Payloads: client, gzipMaxBytes: 100}
+
+	pointer := `["software.amazon.payloadoffloading.PayloadS3Pointer",{"s3BucketName":"bucket","s3Key":"key"}]`
+	message, _ := json.Marshal(pointer)
+	record := events.SQSMessage{MessageId: "1", Body: `{"Type":"Notification","TopicArn":"arn:aws:sns:eu-west-1:123456789012:topic","Message":` + string(message) + `}`}
+
+	body := &sqsBody{}
+	transformed, err := body.get(context.Background(), options, record)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Foo":1}`, transformed.Body)
+	assert.Equal(t, &s3Pointer{Bucket: "bucket", Key: "key"}, body.pointer)
+
+	//The body is only transformed once, and loading it adds the stages to the record's context
+	ctx := ContextWithStages(context.Background())
+	transformed, err = body.load(ctx, options, record)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Foo":1}`, transformed.Body)
+	assert.Equal(t, 1, client.gets)
+	assert.Equal(t, []string{"unwrap sns envelope", "fetch s3 payload", "decompress body"}, getStageDescriptions(ctx))
+}
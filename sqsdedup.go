@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// WithDeduplication processes only the first record in a batch for each deduplication key. Later records with the same
+// key are treated as successes without being processed, and a "skip duplicate" stage is logged for each of them.
+// Records with an empty key are always processed. Use SQSDeduplicationID to deduplicate by MessageDeduplicationId, or
+// DeduplicateByBody to use a key from the message body
+func WithDeduplication(key func(ctx context.Context, record events.SQSMessage) string) SQSOption {
+	return func(o *sqsOptions) {
+		o.deduplicationKey = key
+	}
+}
+
+// SQSDeduplicationID returns the MessageDeduplicationId attribute of a FIFO queue message
+func SQSDeduplicationID(ctx context.Context, record events.SQSMessage) string {
+	return record.Attributes["MessageDeduplicationId"]
+}
+
+// DeduplicateByBody returns a deduplication key function which unmarshals the body of a record into T, with the
+// handler's codec (see WithCodec), and passes it to key. The body is the one the record will be processed with, after
+// WithSNSEnvelope, WithS3Payloads and WithGzipBodies have transformed it. Records with bodies that can't be transformed
+// or unmarshalled have an empty key, so they aren't deduplicated
+func DeduplicateByBody[T interface{}](key func(body T) string) func(ctx context.Context, record events.SQSMessage) string {
+	return func(ctx context.Context, record events.SQSMessage) string {
+		var body T
+		if err := getCodec(ctx).Unmarshal([]byte(record.Body), &body); err != nil {
+			return ""
+		}
+		return key(body)
+	}
+}
+
+// deduplicateSQSRecords returns the indices of the records without those that have the same key as an earlier record
+func deduplicateSQSRecords(ctx context.Context, records []events.SQSMessage, key func(i int) string) []int {
+	first := map[string]string{}
+	unique := make([]int, 0, len(records))
+	for i, record := range records {
+		k := key(i)
+		if k == "" {
+			unique = append(unique, i)
+			continue
+		}
+		if messageID, found := first[k]; found {
+			recordCtx := ContextWithStages(ctx)
+			AddStage(recordCtx, "skip duplicate")
+			GetLogger(ctx).Info("skipping duplicate sqs message", "messageId", record.MessageId, "duplicateOf", messageID, "stages", getStagesLogValue(recordCtx))
+			continue
+		}
+		first[k] = record.MessageId
+		unique = append(unique, i)
+	}
+	return unique
+}
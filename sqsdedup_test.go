@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDeduplication(t *testing.T) {
+	testcases := []struct {
+		name      string
+		key       func(ctx context.Context, record events.SQSMessage) string
+		options   []SQSOption
+		records   []events.SQSMessage
+		processed []string
+	}{
+		{
+			name: "MessageDeduplicationId",
+			key:  SQSDeduplicationID,
+			records: []events.SQSMessage{
+				{MessageId: "1", Attributes: map[string]string{"MessageDeduplicationId": "a"}},
+				{MessageId: "2", Attributes: map[string]string{"MessageDeduplicationId": "b"}},
+				{MessageId: "3", Attributes: map[string]string{"MessageDeduplicationId": "a"}},
+				{MessageId: "4"},
+				{MessageId: "5"},
+			},
+			processed: []string{"1", "2", "4", "5"},
+		},
+		{
+			name: "body",
+			key: DeduplicateByBody(func(body inputEvent) string {
+				return string(rune('0' + body.Foo))
+			}),
+			records: []events.SQSMessage{
+				{MessageId: "1", Body: `{"Foo":1}`},
+				{MessageId: "2", Body: `{"Foo":1}`},
+				{MessageId: "3", Body: `{"Foo":2}`},
+				{MessageId: "4", Body: `not json`},
+				{MessageId: "5", Body: `not json`},
+			},
+			processed: []string{"1", "3", "4", "5"},
+		},
+		{
+			name: "body decoded with codec",
+			key: DeduplicateByBody(func(body inputEvent) string {
+				return string(rune('0' + body.Foo))
+			}),
+			options: []SQSOption{WithCodec(Base64Codec(JSONCodec))},
+			records: []events.SQSMessage{
+				{MessageId: "1", Body: base64.StdEncoding.EncodeToString([]byte(`{"Foo":1}`))},
+				{MessageId: "2", Body: base64.StdEncoding.EncodeToString([]byte(`{"Foo":1}`))},
+				{MessageId: "3", Body: base64.StdEncoding.EncodeToString([]byte(`{"Foo":2}`))},
+			},
+			processed: []string{"1", "3"},
+		},
+		{
+			name: "body unwrapped from sns envelope",
+			key: DeduplicateByBody(func(body inputEvent) string {
+				return string(rune('0' + body.Foo))
+			}),
+			options: []SQSOption{WithSNSEnvelope()},
+			records: []events.SQSMessage{
+				{MessageId: "1", Body: `{"Type":"Notification","TopicArn":"arn:aws:sns:eu-west-1:123456789012:topic","Message":"{\"Foo\":1}"}`},
+				{MessageId: "2", Body: `{"Type":"Notification","TopicArn":"arn:aws:sns:eu-west-1:123456789012:topic","Message":"{\"Foo\":1}"}`},
+				{MessageId: "3", Body: `{"Type":"Notification","TopicArn":"arn:aws:sns:eu-west-1:123456789012:topic","Message":"{\"Foo\":2}"}`},
+			},
+			processed: []string{"1", "3"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu := sync.Mutex{}
+			processed := []string{}
+			h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+				mu.Lock()
+				defer mu.Unlock()
+				processed = append(processed, record.MessageId)
+				return nil
+			}, append(tc.options, WithDeduplication(tc.key))...)
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			result, err := h(ctx, events.SQSEvent{Records: tc.records})
+			assert.Nil(t, err)
+			assert.Empty(t, result.BatchItemFailures)
+			sort.Strings(processed)
+			assert.Equal(t, tc.processed, processed)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+// gzipMagic is the header at the start of gzip data
+const gzipMagic = "\x1f\x8b"
+
+// base64GzipPrefix is the base64 encoding of a gzip header with the deflate compression method
+const base64GzipPrefix = "H4sI"
+
+// WithGzipBodies decompresses record bodies that are gzip data, either raw or base64-encoded (standard encoding), before
+// they are processed. Other bodies are left unchanged. maxBytes limits the decompressed size, so that a small message
+// can't exhaust the lambda's memory. A body that can't be decompressed fails the record with a non-retryable error.
+// With WithS3Payloads, payloads fetched from S3 are decompressed too
+func WithGzipBodies(maxBytes int64) SQSOption {
+	return func(o *sqsOptions) {
+		o.gzipMaxBytes = maxBytes
+	}
+}
+
+// getGzipBody returns the gzip data of a body that is gzip-compressed, decoding it from base64 if required
+func getGzipBody(body string) ([]byte, bool) {
+	if strings.HasPrefix(body, gzipMagic) {
+		return []byte(body), true
+	}
+	if !strings.HasPrefix(body, base64GzipPrefix) {
+		return nil, false
+	}
+	b, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+func gunzipBody(ctx context.Context, compressed []byte, maxBytes int64) (string, error) {
+	r, err := GzipReader(ctx, bytes.NewReader(compressed), maxBytes)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
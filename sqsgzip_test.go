@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithGzipBodies(t *testing.T) {
+	compress := func(s string) string {
+		buf := bytes.Buffer{}
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write([]byte(s))
+		_ = gz.Close()
+		return buf.String()
+	}
+
+	testcases := []struct {
+		name          string
+		body          string
+		expectedBody  string
+		expectDropped bool
+	}{
+		{name: "Plain body", body: `{"Foo":1}`, expectedBody: `{"Foo":1}`},
+		{name: "Gzip body", body: compress(`{"Foo":1}`), expectedBody: `{"Foo":1}`},
+		{name: "Base64 gzip body", body: base64.StdEncoding.EncodeToString([]byte(compress(`{"Foo":1}`))), expectedBody: `{"Foo":1}`},
+		{name: "Decompressed size exceeded", body: compress(strings.Repeat("a", 200)), expectDropped: true},
+		{name: "Corrupt gzip body", body: "\x1f\x8bnot gzip", expectDropped: true},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			bodies := make(chan string, 1)
+			h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+				bodies <- record.Body
+				return nil
+			}, WithGzipBodies(100))
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1", Body: tc.body}}})
+			assert.Nil(t, err)
+			//A body that can't be decompressed is non-retryable, so it is acknowledged without being processed
+			assert.Empty(t, result.BatchItemFailures)
+			if tc.expectDropped {
+				assert.Len(t, bodies, 0)
+				return
+			}
+			assert.Equal(t, tc.expectedBody, <-bodies)
+		})
+	}
+}
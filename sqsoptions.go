@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const codecKey = "codec"
+
+// Codec encodes and decodes message bodies, e.g. as protobuf or msgpack. JSONCodec is used unless another codec is set
+// with WithCodec
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// JSONCodec encodes and decodes JSON using encoding/json
+var JSONCodec Codec = jsonCodec{}
+
+// Base64Codec returns a codec for base64-encoded payloads (standard encoding with padding), decoding the base64 before
+// passing the data to inner, e.g. Base64Codec(JSONCodec) or Base64Codec of a protobuf codec
+func Base64Codec(inner Codec) Codec {
+	return base64Codec{inner: inner}
+}
+
+type base64Codec struct {
+	inner Codec
+}
+
+func (c base64Codec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(data)), nil
+}
+
+func (c base64Codec) Unmarshal(data []byte, v interface{}) error {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return err
+	}
+	return c.inner.Unmarshal(decoded, v)
+}
+
+// getCodec returns the codec set on the context by the SQS handler, or JSONCodec
+func getCodec(ctx context.Context) Codec {
+	if codec, ok := ctx.Value(codecKey).(Codec); ok {
+		return codec
+	}
+	return JSONCodec
+}
+
+// SQSOptions configures the handler returned by GetSQSHandlerWithOptions. Zero values keep the defaults. Each field
+// can also be set with the functional option of the same name (e.g. WithMaxConcurrency)
+type SQSOptions struct {
+	//LoggerParams returns attributes that are added to the logger for each record (e.g. an order ID from the body)
+	LoggerParams func(record events.SQSMessage) []any
+	//MaxConcurrency limits how many records of a batch are processed at the same time
+	MaxConcurrency int
+	//TimeoutMargin is the time reserved before the invocation deadline to return the batch response (default 500ms)
+	TimeoutMargin time.Duration
+	//LogInputEvents logs the (masked) body and attributes of each record before it is processed
+	LogInputEvents bool
+	//Codec decodes the bodies of records for GetTypedSQSHandler and SQSRouter (default JSONCodec)
+	Codec Codec
+	//FailWholeBatch returns an error if any record fails, for event source mappings without ReportBatchItemFailures
+	FailWholeBatch bool
+	//DeduplicationKey skips records with the same key as an earlier record in the batch (see WithDeduplication)
+	DeduplicationKey func(ctx context.Context, record events.SQSMessage) string
+	//RecordTimeout limits the time each record can take to process, independently of the invocation deadline
+	RecordTimeout time.Duration
+	//MaxReceiveCount is the queue's maxReceiveCount, used to report records that fail on their final attempt
+	MaxReceiveCount int
+}
+
+// GetSQSHandlerWithOptions returns a lambda handler like GetSQSHandler, configured by options. Any opts are applied
+// after options
+func GetSQSHandlerWithOptions(processRecord SQSRecordProcessor, options SQSOptions, opts ...SQSOption) Handler[events.SQSEvent, events.SQSEventResponse] {
+	return GetSQSHandler(processRecord, append([]SQSOption{WithSQSOptions(options)}, opts...)...)
+}
+
+// WithSQSOptions applies the non-zero fields of options
+func WithSQSOptions(options SQSOptions) SQSOption {
+	return func(o *sqsOptions) {
+		if options.LoggerParams != nil {
+			o.loggerParams = options.LoggerParams
+		}
+		if options.MaxConcurrency > 0 {
+			o.maxConcurrency = options.MaxConcurrency
+		}
+		if options.TimeoutMargin > 0 {
+			o.timeoutMargin = options.TimeoutMargin
+		}
+		if options.LogInputEvents {
+			o.logInputEvents = true
+		}
+		if options.Codec != nil {
+			o.codec = options.Codec
+		}
+		if options.FailWholeBatch {
+			o.failWholeBatch = true
+		}
+		if options.DeduplicationKey != nil {
+			o.deduplicationKey = options.DeduplicationKey
+		}
+		if options.RecordTimeout > 0 {
+			o.recordTimeout = options.RecordTimeout
+		}
+		if options.MaxReceiveCount > 0 {
+			o.maxReceiveCount = options.MaxReceiveCount
+		}
+	}
+}
+
+// WithLoggerParams adds the attributes returned by params to the logger used while processing each record
+func WithLoggerParams(params func(record events.SQSMessage) []any) SQSOption {
+	return func(o *sqsOptions) {
+		o.loggerParams = params
+	}
+}
+
+// WithTimeoutMargin changes the time reserved before the invocation deadline to return the batch response. Records that
+// are still running once the margin is reached are cancelled and returned to the queue. Use a longer margin if the
+// handler needs time after the records have been processed, e.g. to flush buffered output
+func WithTimeoutMargin(margin time.Duration) SQSOption {
+	return func(o *sqsOptions) {
+		o.timeoutMargin = margin
+	}
+}
+
+// WithInputEventLogging logs the body (masked by LOG_MASK_PATHS) and attributes of each record before it is processed
+func WithInputEventLogging() SQSOption {
+	return func(o *sqsOptions) {
+		o.logInputEvents = true
+	}
+}
+
+// WithCodec sets the codec used to decode the bodies of records by GetTypedSQSHandler and SQSRouter
+func WithCodec(codec Codec) SQSOption {
+	return func(o *sqsOptions) {
+		o.codec = codec
+	}
+}
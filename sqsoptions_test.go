@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSQSHandlerWithOptions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(buf, nil)))
+	deadline := time.Now().Add(5 * time.Second)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	recordDeadlines := make(chan time.Time, 1)
+	h := GetSQSHandlerWithOptions(func(ctx context.Context, record events.SQSMessage) error {
+		recordDeadline, _ := ctx.Deadline()
+		recordDeadlines <- recordDeadline
+		GetLogger(ctx).Info("processing")
+		return nil
+	}, SQSOptions{
+		LoggerParams: func(record events.SQSMessage) []any {
+			return []any{"messageId", record.MessageId}
+		},
+		TimeoutMargin:  2 * time.Second,
+		LogInputEvents: true,
+	})
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{MessageId: "id-1", ReceiptHandle: "receipt-1", Body: `{"Foo":1}`}}})
+	assert.Nil(t, err)
+	assert.Len(t, result.BatchItemFailures, 0)
+	assert.Equal(t, deadline.Add(-2*time.Second), <-recordDeadlines)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	input := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &input))
+	assert.Equal(t, "processing sqs message", input["msg"])
+	assert.Equal(t, `{"Foo":1}`, input["body"])
+	processing := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &processing))
+	assert.Equal(t, "processing", processing["msg"])
+	assert.Equal(t, "id-1", processing["messageId"])
+}
+
+func TestWithCodec(t *testing.T) {
+	bodies := make(chan inputEvent, 1)
+	h := GetTypedSQSHandler(func(ctx context.Context, body inputEvent, record events.SQSMessage) error {
+		bodies <- body
+		return nil
+	}, WithCodec(mockCodec{}))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "receipt-1", Body: "not json"}}})
+	assert.Nil(t, err)
+	assert.Len(t, result.BatchItemFailures, 0)
+	assert.Equal(t, inputEvent{Foo: 8}, <-bodies)
+}
+
+func TestBase64Codec(t *testing.T) {
+	codec := Base64Codec(JSONCodec)
+	data, err := codec.Marshal(inputEvent{Foo: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "eyJGb28iOjF9", string(data))
+
+	var event inputEvent
+	assert.Nil(t, codec.Unmarshal(data, &event))
+	assert.Equal(t, inputEvent{Foo: 1}, event)
+	assert.Error(t, codec.Unmarshal([]byte(`{"Foo":1}`), &event))
+}
+
+type mockCodec struct{}
+
+func (mockCodec) Marshal(v interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+// Unmarshal sets Foo to the length of the data
+func (mockCodec) Unmarshal(data []byte, v interface{}) error {
+	v.(*inputEvent).Foo = len(data)
+	return nil
+}
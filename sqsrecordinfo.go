@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const sqsRecordInfoKey = "sqsRecordInfo"
+
+// SQSRecordInfo identifies the SQS record being processed, for correlation in processors and downstream libraries
+type SQSRecordInfo struct {
+	MessageID      string
+	ReceiptHandle  string
+	ReceiveCount   int
+	EventSourceARN string
+}
+
+// ContextWithSQSRecordInfo returns a context carrying the metadata of record. GetSQSHandler adds this to the context of
+// each record
+func ContextWithSQSRecordInfo(ctx context.Context, record events.SQSMessage) context.Context {
+	return context.WithValue(ctx, sqsRecordInfoKey, SQSRecordInfo{
+		MessageID:      record.MessageId,
+		ReceiptHandle:  record.ReceiptHandle,
+		ReceiveCount:   GetSQSReceiveCount(record),
+		EventSourceARN: record.EventSourceARN,
+	})
+}
+
+// GetSQSRecordInfo returns the metadata of the SQS record being processed, or false if the context isn't for an SQS
+// record
+func GetSQSRecordInfo(ctx context.Context) (SQSRecordInfo, bool) {
+	info, ok := ctx.Value(sqsRecordInfoKey).(SQSRecordInfo)
+	return info, ok
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSQSRecordInfo(t *testing.T) {
+	_, ok := GetSQSRecordInfo(context.Background())
+	assert.False(t, ok)
+
+	infos := make(chan SQSRecordInfo, 1)
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		info, ok := GetSQSRecordInfo(ctx)
+		assert.True(t, ok)
+		infos <- info
+		return nil
+	})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	_, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{{
+		MessageId:      "message-1",
+		ReceiptHandle:  "receipt-1",
+		EventSourceARN: "arn:aws:sqs:eu-west-2:123456789012:orders",
+		Attributes:     map[string]string{"ApproximateReceiveCount": "2"},
+	}}})
+	assert.Nil(t, err)
+	assert.Equal(t, SQSRecordInfo{
+		MessageID:      "message-1",
+		ReceiptHandle:  "receipt-1",
+		ReceiveCount:   2,
+		EventSourceARN: "arn:aws:sqs:eu-west-2:123456789012:orders",
+	}, <-infos)
+}
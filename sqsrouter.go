@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// SQSRouter routes SQS messages to typed processors by the value of a message attribute, for queues that carry several
+// message types. Register processors with AddSQSRoute, then use Handler to get the lambda handler
+type SQSRouter struct {
+	attribute string
+	routes    map[string]SQSRecordProcessor
+}
+
+// NewSQSRouter returns a router that picks the processor for each message using the string value of the named message
+// attribute (e.g. "type")
+func NewSQSRouter(attribute string) *SQSRouter {
+	return &SQSRouter{attribute: attribute, routes: map[string]SQSRecordProcessor{}}
+}
+
+// AddSQSRoute registers processRecord for messages whose routing attribute equals value. The JSON body of these messages
+// is unmarshalled into T and validated (as with GetTypedSQSHandler). Registering the same value twice panics
+func AddSQSRoute[T interface{}](router *SQSRouter, value string, processRecord TypedSQSRecordProcessor[T]) {
+	if _, found := router.routes[value]; found {
+		panic(fmt.Errorf("SQS route for %s '%s' has already been registered", router.attribute, value))
+	}
+	router.routes[value] = func(ctx context.Context, record events.SQSMessage) error {
+		body, err := decodeSQSBody[T](ctx, record)
+		if err != nil {
+			return err
+		}
+		return processRecord(ctx, body, record)
+	}
+}
+
+// Handler returns a lambda handler like GetSQSHandler which passes each message to the processor registered for its
+// routing attribute value. Messages without the attribute, or with a value that has no route, fail
+func (r *SQSRouter) Handler(opts ...SQSOption) Handler[events.SQSEvent, events.SQSEventResponse] {
+	return GetSQSHandler(r.route, opts...)
+}
+
+func (r *SQSRouter) route(ctx context.Context, record events.SQSMessage) error {
+	attr, found := record.MessageAttributes[r.attribute]
+	if !found || attr.StringValue == nil {
+		return StageErr(ctx, "route message", fmt.Errorf("message attribute '%s' is missing", r.attribute))
+	}
+	value := *attr.StringValue
+	processRecord, found := r.routes[value]
+	if !found {
+		return StageErr(ctx, "route message", fmt.Errorf("no route for %s '%s'", r.attribute, value))
+	}
+	AddStage(ctx, "route message: "+value)
+	return processRecord(ctx, record)
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQSRouter(t *testing.T) {
+	inputs := make(chan inputEvent, 10)
+	outputs := make(chan outputEvent, 10)
+	router := NewSQSRouter("type")
+	AddSQSRoute(router, "input", func(ctx context.Context, body inputEvent, record events.SQSMessage) error {
+		inputs <- body
+		return nil
+	})
+	AddSQSRoute(router, "output", func(ctx context.Context, body outputEvent, record events.SQSMessage) error {
+		outputs <- body
+		return nil
+	})
+	assert.PanicsWithError(t, "SQS route for type 'input' has already been registered", func() {
+		AddSQSRoute(router, "input", func(ctx context.Context, body inputEvent, record events.SQSMessage) error { return nil })
+	})
+
+	withType := func(id string, value string, body string) events.SQSMessage {
+		return events.SQSMessage{
+			MessageId:         id,
+			ReceiptHandle:     id,
+			Body:              body,
+			MessageAttributes: map[string]events.SQSMessageAttribute{"type": {StringValue: aws.String(value), DataType: "String"}},
+		}
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := router.Handler()(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		withType("1", "input", `{"Foo":1}`),
+		withType("2", "output", `{"Bar":2}`),
+		withType("3", "other", `{}`),
+		withType("4", "input", `not json`),
+		{MessageId: "5", ReceiptHandle: "5", Body: `{}`},
+	}})
+	assert.Nil(t, err)
+	//A body that can't be unmarshalled is non-retryable, so it is acknowledged rather than redelivered
+	assert.ElementsMatch(t, []events.SQSBatchItemFailure{
+		{ItemIdentifier: "3"},
+		{ItemIdentifier: "5"},
+	}, result.BatchItemFailures)
+	assert.Equal(t, inputEvent{Foo: 1}, <-inputs)
+	assert.Equal(t, outputEvent{Bar: 2}, <-outputs)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// SQSOrSNSEvent is an event from either an SQS event source mapping or an SNS subscription. Exactly one of SQS and SNS
+// is set after unmarshalling, depending on the event source of the records
+type SQSOrSNSEvent struct {
+	SQS *events.SQSEvent
+	SNS *events.SNSEvent
+}
+
+func (e *SQSOrSNSEvent) UnmarshalJSON(data []byte) error {
+	//SQS records have "eventSource" and SNS records "EventSource", which both match this field
+	var probe struct {
+		Records []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}
+	err := json.Unmarshal(data, &probe)
+	if err != nil {
+		return err
+	}
+
+	source := "aws:sqs"
+	if len(probe.Records) > 0 {
+		source = probe.Records[0].EventSource
+	}
+	switch source {
+	case "aws:sqs":
+		e.SQS = &events.SQSEvent{}
+		return json.Unmarshal(data, e.SQS)
+	case "aws:sns":
+		e.SNS = &events.SNSEvent{}
+		return json.Unmarshal(data, e.SNS)
+	default:
+		return fmt.Errorf("unsupported event source '%s'", source)
+	}
+}
+
+// QueueMessage is an SQS message or SNS notification with its body unmarshalled into T
+type QueueMessage[T interface{}] struct {
+	Payload T
+	//EventSource is "aws:sqs" or "aws:sns"
+	EventSource string
+	MessageID   string
+	//Attributes are the string values of the message attributes
+	Attributes map[string]string
+}
+
+type QueueMessageProcessor[T interface{}] func(ctx context.Context, message QueueMessage[T]) error
+
+// GetSQSOrSNSHandler returns a lambda handler for functions that are invoked by both an SQS queue and an SNS topic. The
+// event source is detected from the payload, and each record is normalised into a QueueMessage and passed to
+// processMessage. Both event sources are processed as by GetTypedSQSHandler with opts, so the records are decoded,
+// validated, deduplicated and timed-out in the same way. SQS events return the failed records in the batch response.
+// SNS has no partial batch response, so SNS events return an error if any record fails, and Lambda retries the whole
+// event. Options that change the visibility of a queue message (WithRetryAfterVisibility, WithReceiveCountBackoff and
+// WithVisibilityHeartbeat), WithQueueDepth and WithSNSEnvelope don't apply to SNS records, so they are ignored for SNS
+// events
+func GetSQSOrSNSHandler[T interface{}](processMessage QueueMessageProcessor[T], opts ...SQSOption) Handler[SQSOrSNSEvent, events.SQSEventResponse] {
+	process := func(ctx context.Context, body T, record events.SQSMessage) error {
+		attributes := make(map[string]string, len(record.MessageAttributes))
+		for k, v := range record.MessageAttributes {
+			if v.StringValue != nil {
+				attributes[k] = *v.StringValue
+			}
+		}
+		return processMessage(ctx, QueueMessage[T]{Payload: body, EventSource: record.EventSource, MessageID: record.MessageId, Attributes: attributes})
+	}
+	sqsHandler := GetTypedSQSHandler(process, opts...)
+	snsHandler := GetTypedSQSHandler(process, append(opts, withSNSRecords())...)
+
+	return func(ctx context.Context, event SQSOrSNSEvent) (events.SQSEventResponse, error) {
+		if event.SNS != nil {
+			records := make([]events.SQSMessage, len(event.SNS.Records))
+			for i, record := range event.SNS.Records {
+				records[i] = toSQSMessageFromSNS(record)
+			}
+			_, err := snsHandler(ctx, events.SQSEvent{Records: records})
+			return events.SQSEventResponse{}, err
+		}
+		if event.SQS != nil {
+			return sqsHandler(ctx, *event.SQS)
+		}
+		return events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}, nil
+	}
+}
+
+// withSNSRecords configures a handler for SNS records that have been converted into SQS messages (see
+// toSQSMessageFromSNS): the options that act on a queue are removed, and any failure fails the whole event
+func withSNSRecords() SQSOption {
+	return func(o *sqsOptions) {
+		o.visibilityClient = nil
+		o.backoffClient = nil
+		o.visibilityHeartbeat = nil
+		o.queueDepth = nil
+		o.snsEnvelope = false
+		o.failWholeBatch = true
+		o.sourceName = "sns"
+	}
+}
+
+// toSQSMessageFromSNS converts an SNS record into an SQS message, so that it can be processed by the SQS handler. The
+// message ID is used as the receipt handle, and the SNS timestamp (if set) as the SentTimestamp attribute
+func toSQSMessageFromSNS(record events.SNSEventRecord) events.SQSMessage {
+	attributes := make(map[string]events.SQSMessageAttribute, len(record.SNS.MessageAttributes))
+	for k, v := range record.SNS.MessageAttributes {
+		//Lambda SNS events have attributes of the form {"Type": "String", "Value": "..."}
+		attribute, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		dataType, _ := attribute["Type"].(string)
+		value, ok := attribute["Value"].(string)
+		if !ok {
+			continue
+		}
+		if dataType == "Binary" {
+			b, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				continue
+			}
+			attributes[k] = events.SQSMessageAttribute{DataType: dataType, BinaryValue: b}
+			continue
+		}
+		attributes[k] = events.SQSMessageAttribute{DataType: dataType, StringValue: &value}
+	}
+
+	systemAttributes := map[string]string{}
+	if !record.SNS.Timestamp.IsZero() {
+		systemAttributes["SentTimestamp"] = strconv.FormatInt(record.SNS.Timestamp.UnixMilli(), 10)
+	}
+	return events.SQSMessage{
+		MessageId:         record.SNS.MessageID,
+		ReceiptHandle:     record.SNS.MessageID,
+		Body:              record.SNS.Message,
+		Attributes:        systemAttributes,
+		MessageAttributes: attributes,
+		EventSource:       record.EventSource,
+		EventSourceARN:    record.SNS.TopicArn,
+	}
+}
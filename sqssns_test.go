@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSQSOrSNSHandler(t *testing.T) {
+	testcases := []struct {
+		name        string
+		payload     string
+		expected    QueueMessage[inputEvent]
+		checkResult func(t *testing.T, result events.SQSEventResponse, err error)
+	}{
+		{
+			name:     "SQS event",
+			payload:  `{"Records":[{"messageId":"m1","receiptHandle":"r1","body":"{\"Foo\":1}","eventSource":"aws:sqs","messageAttributes":{"type":{"stringValue":"order","dataType":"String"}}}]}`,
+			expected: QueueMessage[inputEvent]{Payload: inputEvent{Foo: 1}, EventSource: "aws:sqs", MessageID: "m1", Attributes: map[string]string{"type": "order"}},
+			checkResult: func(t *testing.T, result events.SQSEventResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "r1"}}, result.BatchItemFailures)
+			},
+		},
+		{
+			name:     "SNS event",
+			payload:  `{"Records":[{"EventSource":"aws:sns","Sns":{"MessageId":"m2","Message":"{\"Foo\":2}","MessageAttributes":{"type":{"Type":"String","Value":"order"}}}}]}`,
+			expected: QueueMessage[inputEvent]{Payload: inputEvent{Foo: 2}, EventSource: "aws:sns", MessageID: "m2", Attributes: map[string]string{"type": "order"}},
+			checkResult: func(t *testing.T, result events.SQSEventResponse, err error) {
+				assert.EqualError(t, err, "1 of 1 sns messages failed")
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages := make(chan QueueMessage[inputEvent], 1)
+			h := GetSQSOrSNSHandler(func(ctx context.Context, message QueueMessage[inputEvent]) error {
+				messages <- message
+				return errors.New("oops")
+			})
+
+			var event SQSOrSNSEvent
+			assert.Nil(t, json.Unmarshal([]byte(tc.payload), &event))
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+			result, err := h(ctx, event)
+			tc.checkResult(t, result, err)
+			assert.Equal(t, tc.expected, <-messages)
+		})
+	}
+}
+
+func TestGetSQSOrSNSHandlerOptions(t *testing.T) {
+	//Both event sources are decoded with the codec and validated with the same options
+	processed := make(chan QueueMessage[inputEvent], 4)
+	h := GetSQSOrSNSHandler(func(ctx context.Context, message QueueMessage[inputEvent]) error {
+		processed <- message
+		return nil
+	}, WithCodec(Base64Codec(JSONCodec)), WithBodyValidator(func(body inputEvent) error {
+		if body.Foo < 0 {
+			return errors.New("foo must not be negative")
+		}
+		return nil
+	}))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	for _, payload := range []string{
+		`{"Records":[{"messageId":"m1","receiptHandle":"r1","body":"eyJGb28iOjF9","eventSource":"aws:sqs"},{"messageId":"m2","receiptHandle":"r2","body":"eyJGb28iOi0xfQ==","eventSource":"aws:sqs"}]}`,
+		`{"Records":[{"EventSource":"aws:sns","Sns":{"MessageId":"m3","Message":"eyJGb28iOjF9"}},{"EventSource":"aws:sns","Sns":{"MessageId":"m4","Message":"eyJGb28iOi0xfQ=="}}]}`,
+	} {
+		var event SQSOrSNSEvent
+		assert.Nil(t, json.Unmarshal([]byte(payload), &event))
+		result, err := h(ctx, event)
+		assert.Nil(t, err)
+		assert.Empty(t, result.BatchItemFailures)
+	}
+	close(processed)
+	ids := []string{}
+	for message := range processed {
+		assert.Equal(t, inputEvent{Foo: 1}, message.Payload)
+		ids = append(ids, message.MessageID)
+	}
+	assert.ElementsMatch(t, []string{"m1", "m3"}, ids)
+}
+
+func TestSQSOrSNSEventUnmarshal(t *testing.T) {
+	var event SQSOrSNSEvent
+	assert.EqualError(t, json.Unmarshal([]byte(`{"Records":[{"eventSource":"aws:kinesis"}]}`), &event), "unsupported event source 'aws:kinesis'")
+
+	event = SQSOrSNSEvent{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"Records":[]}`), &event))
+	assert.NotNil(t, event.SQS)
+	assert.Nil(t, event.SNS)
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const bodyValidatorsKey = "bodyValidators"
+
+// ErrorCodeValidation is the code given to errors from validating a message body (see Validator)
+const ErrorCodeValidation = "ValidationError"
+
+// TypedSQSRecordProcessor processes an SQS message with its body unmarshalled into T. The record gives access to the
+// message's metadata, such as its ID and system attributes (see GetSQSReceiveCount)
+type TypedSQSRecordProcessor[T interface{}] func(ctx context.Context, body T, record events.SQSMessage) error
+
+// Validator is implemented by message body types that check their own content. Bodies are validated after they have been
+// unmarshalled and before they are processed
+type Validator interface {
+	Validate() error
+}
+
+// GetTypedSQSHandler returns a lambda handler like GetSQSHandler, but which unmarshals the JSON body of each message into
+// T (or decodes it with the codec set by WithCodec) before calling processRecord. A body that can't be unmarshalled
+// fails the record with a non-retryable error (see NonRetryable). If T implements Validator, or validators were added
+// with WithBodyValidator, a body that fails validation fails the record with a non-retryable ValidationError.
+// Non-retryable records are sent to the WithNonRetryableDeadLetterQueue queue if there is one, and otherwise logged with
+// their body and acknowledged, so they aren't redelivered
+func GetTypedSQSHandler[T interface{}](processRecord TypedSQSRecordProcessor[T], opts ...SQSOption) Handler[events.SQSEvent, events.SQSEventResponse] {
+	return GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		body, err := decodeSQSBody[T](ctx, record)
+		if err != nil {
+			return err
+		}
+		return processRecord(ctx, body, record)
+	}, opts...)
+}
+
+// WithBodyValidator adds a validator for message bodies of type T, used by GetTypedSQSHandler and SQSRouter after the
+// body has been unmarshalled. Validators for other types are ignored, so a router can have one for each message type
+func WithBodyValidator[T interface{}](validate func(body T) error) SQSOption {
+	return func(o *sqsOptions) {
+		o.bodyValidators = append(o.bodyValidators, func(body interface{}) error {
+			if typed, ok := body.(*T); ok {
+				return validate(*typed)
+			}
+			return nil
+		})
+	}
+}
+
+// WithTypedBeforeBatch is like WithBeforeBatch, but hook gets the bodies of the batch's records decoded and validated as
+// they are for the processor of GetTypedSQSHandler. Bodies that can't be decoded or fail validation are logged and left
+// out, as those records will fail when they are processed
+func WithTypedBeforeBatch[T interface{}](hook func(ctx context.Context, bodies []T) (context.Context, error)) SQSOption {
+	return WithBeforeBatch(func(ctx context.Context, records []events.SQSMessage) (context.Context, error) {
+		bodies := make([]T, 0, len(records))
+		for _, record := range records {
+			//The stages of decoding are added when the record is processed
+			body, err := decodeSQSBody[T](ContextWithStages(ctx), record)
+			if err != nil {
+				GetLogger(ctx).Warn("leaving sqs message out of before batch hook", "messageId", record.MessageId, "error", err.Error())
+				continue
+			}
+			bodies = append(bodies, body)
+		}
+		return hook(ctx, bodies)
+	})
+}
+
+// decodeSQSBody unmarshals the record's body into T and validates it
+func decodeSQSBody[T interface{}](ctx context.Context, record events.SQSMessage) (T, error) {
+	var body T
+	err := getCodec(ctx).Unmarshal([]byte(record.Body), &body)
+	if err != nil {
+		return body, NonRetryable(StageErr(ctx, "unmarshal body", err))
+	}
+	AddStage(ctx, "unmarshal body")
+
+	validators, _ := ctx.Value(bodyValidatorsKey).([]func(body interface{}) error)
+	if validator, ok := interface{}(&body).(Validator); ok {
+		validators = append([]func(body interface{}) error{func(interface{}) error { return validator.Validate() }}, validators...)
+	}
+	if len(validators) == 0 {
+		return body, nil
+	}
+	for _, validate := range validators {
+		err = validate(&body)
+		if err != nil {
+			return body, NonRetryable(NewHandlerError(ErrorCodeValidation, StageErr(ctx, "validate body", err)))
+		}
+	}
+	AddStage(ctx, "validate body")
+	return body, nil
+}
+
+// GetSQSReceiveCount returns the number of times the message has been received (the ApproximateReceiveCount system
+// attribute), or 0 if the attribute is missing
+func GetSQSReceiveCount(record events.SQSMessage) int {
+	count, _ := strconv.Atoi(record.Attributes["ApproximateReceiveCount"])
+	return count
+}
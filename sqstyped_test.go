@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTypedSQSHandler(t *testing.T) {
+
+	testcases := []struct {
+		name             string
+		record           events.SQSMessage
+		expectedFailures []events.SQSBatchItemFailure
+	}{
+		{
+			name: "Body unmarshalled with metadata",
+			record: events.SQSMessage{
+				MessageId:     "message-1",
+				ReceiptHandle: "r1",
+				Body:          `{"Foo": 1}`,
+				Attributes:    map[string]string{"ApproximateReceiveCount": "2"},
+			},
+			expectedFailures: []events.SQSBatchItemFailure{},
+		},
+		{
+			name:             "Body can't be unmarshalled is acknowledged",
+			record:           events.SQSMessage{MessageId: "message-1", ReceiptHandle: "r1", Body: "not json"},
+			expectedFailures: []events.SQSBatchItemFailure{},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+			defer cancel()
+
+			handler := GetTypedSQSHandler(func(ctx context.Context, body inputEvent, record events.SQSMessage) error {
+				assert.Equal(t, 1, body.Foo)
+				assert.Equal(t, "message-1", record.MessageId)
+				assert.Equal(t, 2, GetSQSReceiveCount(record))
+				return nil
+			})
+			result, err := handler(ctx, events.SQSEvent{Records: []events.SQSMessage{tc.record}})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedFailures, result.BatchItemFailures)
+		})
+	}
+}
+
+type validatedEvent struct {
+	Foo int
+}
+
+func (e validatedEvent) Validate() error {
+	if e.Foo < 0 {
+		return errors.New("foo must not be negative")
+	}
+	return nil
+}
+
+func TestGetTypedSQSHandlerValidation(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+
+	processed := make(chan int, 10)
+	client := &mockSQSClient{}
+	h := GetTypedSQSHandler(func(ctx context.Context, body validatedEvent, record events.SQSMessage) error {
+		processed <- body.Foo
+		return nil
+	}, WithBodyValidator(func(body validatedEvent) error {
+		if body.Foo > 100 {
+			return errors.New("foo is too big")
+		}
+		return nil
+	}), WithBodyValidator(func(body inputEvent) error {
+		return errors.New("not called for other types")
+	}), WithNonRetryableDeadLetterQueue(client, "https://dlq"), WithMaxConcurrency(1))
+
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "1", Body: `{"Foo":1}`},
+		{ReceiptHandle: "2", Body: `{"Foo":-1}`},
+		{ReceiptHandle: "3", Body: `{"Foo":101}`},
+	}})
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+	assert.Len(t, processed, 1)
+	assert.Equal(t, 1, <-processed)
+	assert.Len(t, client.sent, 2)
+	for _, input := range client.sent {
+		assert.Equal(t, ErrorCodeValidation, aws.ToString(input.MessageAttributes[FailureErrorCodeAttribute].StringValue))
+	}
+}
+
+func TestWithTypedBeforeBatch(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+
+	type prefetchedKey struct{}
+	h := GetTypedSQSHandler(func(ctx context.Context, body inputEvent, record events.SQSMessage) error {
+		if ctx.Value(prefetchedKey{}).(map[int]bool)[body.Foo] {
+			return nil
+		}
+		return errors.New("not prefetched")
+	}, WithTypedBeforeBatch(func(ctx context.Context, bodies []inputEvent) (context.Context, error) {
+		prefetched := map[int]bool{}
+		for _, body := range bodies {
+			if body.Foo == 99 {
+				return ctx, errors.New("prefetch failed")
+			}
+			prefetched[body.Foo] = true
+		}
+		return context.WithValue(ctx, prefetchedKey{}, prefetched), nil
+	}))
+
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "1", Body: `{"Foo":1}`},
+		{ReceiptHandle: "2", Body: `{"Foo":2}`},
+		{ReceiptHandle: "3", Body: `not json`},
+	}})
+	assert.Nil(t, err)
+	//The body that can't be unmarshalled is left out of the hook, and acknowledged as non-retryable
+	assert.Empty(t, result.BatchItemFailures)
+
+	//A failed hook fails the whole batch
+	result, err = h(ctx, events.SQSEvent{Records: []events.SQSMessage{{ReceiptHandle: "1", Body: `{"Foo":1}`}, {ReceiptHandle: "2", Body: `{"Foo":99}`}}})
+	assert.Nil(t, err)
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "1"}, {ItemIdentifier: "2"}}, result.BatchItemFailures)
+}
+
+func TestWithTypedBeforeBatchDeduplication(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+
+	h := GetTypedSQSHandler(func(ctx context.Context, body inputEvent, record events.SQSMessage) error {
+		return nil
+	}, WithDeduplication(SQSDeduplicationID), WithTypedBeforeBatch(func(ctx context.Context, bodies []inputEvent) (context.Context, error) {
+		return ctx, errors.New("prefetch failed")
+	}))
+
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "1", Body: `{"Foo":1}`, Attributes: map[string]string{"MessageDeduplicationId": "a"}},
+		{ReceiptHandle: "2", Body: `{"Foo":1}`, Attributes: map[string]string{"MessageDeduplicationId": "a"}},
+	}})
+	assert.Nil(t, err)
+	//The duplicate is a success even though the hook failed
+	assert.Equal(t, []events.SQSBatchItemFailure{{ItemIdentifier: "1"}}, result.BatchItemFailures)
+}
+
+func TestWithTypedBeforeBatchTransformedBodies(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+
+	var hookBodies []inputEvent
+	h := GetTypedSQSHandler(func(ctx context.Context, body inputEvent, record events.SQSMessage) error {
+		return nil
+	}, WithSNSEnvelope(), WithTypedBeforeBatch(func(ctx context.Context, bodies []inputEvent) (context.Context, error) {
+		hookBodies = bodies
+		return ctx, nil
+	}))
+
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "1", Body: `{"Type":"Notification","TopicArn":"arn:aws:sns:eu-west-1:123456789012:topic","Message":"{\"Foo\":1}"}`},
+		{ReceiptHandle: "2", Body: `{"Foo":2}`},
+	}})
+	assert.Nil(t, err)
+	assert.Empty(t, result.BatchItemFailures)
+	assert.Equal(t, []inputEvent{{Foo: 1}, {Foo: 2}}, hookBodies)
+}
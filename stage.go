@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const stagesKey = "stages"
+
+// Stage is a step in the processing of an invocation (or of a single record in a batch)
+type Stage struct {
+	Description string
+	Time        time.Time
+}
+
+type stageList struct {
+	mu     sync.Mutex
+	stages []Stage
+}
+
+// ContextWithStages attaches a new, empty stage list to the context
+func ContextWithStages(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stagesKey, &stageList{})
+}
+
+// AddStage records a stage on the context's stage list. The stages are included when a failure is logged
+func AddStage(ctx context.Context, description string) {
+	val := ctx.Value(stagesKey)
+	if val == nil {
+		return
+	}
+	list := val.(*stageList)
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	list.stages = append(list.stages, Stage{Description: description, Time: GetClock(ctx).Now()})
+}
+
+// StageErr records a stage and returns err wrapped with the same description, so that the logged stages and the error
+// chain stay in sync. It returns nil if err is nil
+func StageErr(ctx context.Context, description string, err error) error {
+	if err == nil {
+		return nil
+	}
+	AddStage(ctx, description)
+	return fmt.Errorf("%s: %w", description, err)
+}
+
+// GetStages returns the stages recorded on the context so far
+func GetStages(ctx context.Context) []Stage {
+	val := ctx.Value(stagesKey)
+	if val == nil {
+		return nil
+	}
+	list := val.(*stageList)
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	return append([]Stage{}, list.stages...)
+}
+
+func getStageDescriptions(ctx context.Context) []string {
+	descriptions := []string{}
+	for _, stage := range GetStages(ctx) {
+		descriptions = append(descriptions, stage.Description)
+	}
+	return descriptions
+}
+
+type stageLogEntry struct {
+	Description string    `json:"description"`
+	Time        time.Time `json:"time"`
+	DurationMs  int64     `json:"durationMs"`
+}
+
+// getStagesLogValue returns the stages in the shape selected by the STAGES_FORMAT environment variable: "array" (the
+// default) gives the descriptions, "joined" gives the descriptions as one string, and "detailed" gives objects with the
+// time of each stage and how long it lasted (until the next stage, or until now for the last one)
+func getStagesLogValue(ctx context.Context) interface{} {
+	switch os.Getenv("STAGES_FORMAT") {
+	case "joined":
+		return strings.Join(getStageDescriptions(ctx), " > ")
+	case "detailed":
+		stages := GetStages(ctx)
+		entries := make([]stageLogEntry, len(stages))
+		now := GetClock(ctx).Now()
+		for i, stage := range stages {
+			end := now
+			if i+1 < len(stages) {
+				end = stages[i+1].Time
+			}
+			entries[i] = stageLogEntry{Description: stage.Description, Time: stage.Time, DurationMs: end.Sub(stage.Time).Milliseconds()}
+		}
+		return entries
+	default:
+		return getStageDescriptions(ctx)
+	}
+}
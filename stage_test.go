@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageErr(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		err         error
+		checkResult func(t *testing.T, ctx context.Context, err error)
+	}{
+		{
+			name: "Error wrapped and stage recorded",
+			err:  errors.New("not found"),
+			checkResult: func(t *testing.T, ctx context.Context, err error) {
+				assert.EqualError(t, err, "load customer: not found")
+				assert.Equal(t, []string{"start", "load customer"}, getStageDescriptions(ctx))
+			},
+		},
+		{
+			name: "Nil error",
+			checkResult: func(t *testing.T, ctx context.Context, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"start"}, getStageDescriptions(ctx))
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := ContextWithStages(context.Background())
+			AddStage(ctx, "start")
+			err := StageErr(ctx, "load customer", tc.err)
+			tc.checkResult(t, ctx, err)
+		})
+	}
+}
+
+func TestGetStagesLogValue(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testcases := []struct {
+		name     string
+		format   string
+		expected interface{}
+	}{
+		{
+			name:     "Default",
+			expected: []string{"start", "load customer"},
+		},
+		{
+			name:     "Joined",
+			format:   "joined",
+			expected: "start > load customer",
+		},
+		{
+			name:   "Detailed",
+			format: "detailed",
+			expected: []stageLogEntry{
+				{Description: "start", Time: start, DurationMs: 250},
+				{Description: "load customer", Time: start.Add(250 * time.Millisecond), DurationMs: 1000},
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("STAGES_FORMAT", tc.format)
+			clock := NewFakeClock(start)
+			ctx := ContextWithClock(ContextWithStages(context.Background()), clock)
+			AddStage(ctx, "start")
+			clock.Advance(250 * time.Millisecond)
+			AddStage(ctx, "load customer")
+			clock.Advance(time.Second)
+			assert.Equal(t, tc.expected, getStagesLogValue(ctx))
+		})
+	}
+}
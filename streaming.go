@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// StreamWriter writes the body of a streamed Lambda function URL response. StatusCode and Headers must be set before the
+// first call to Write, because they are sent ahead of the body
+type StreamWriter struct {
+	StatusCode int
+	Headers    map[string]string
+
+	pipe    *io.PipeWriter
+	once    sync.Once
+	started chan struct{}
+}
+
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	s.once.Do(func() { close(s.started) })
+	return s.pipe.Write(p)
+}
+
+// StreamingHandler writes its response to w rather than returning it, so that large responses aren't buffered in memory
+type StreamingHandler[T interface{}] func(ctx context.Context, event T, w *StreamWriter) error
+
+// GetStreamingHandler adapts a StreamingHandler for a function URL with the RESPONSE_STREAM invoke mode. The response
+// is returned to the runtime as soon as the handler starts writing, and the rest of the body is streamed as it is
+// written. An error returned before anything is written fails the invocation; after that the stream is cut short
+func GetStreamingHandler[T interface{}](streamingHandler StreamingHandler[T]) Handler[T, *events.LambdaFunctionURLStreamingResponse] {
+	return func(ctx context.Context, event T) (*events.LambdaFunctionURLStreamingResponse, error) {
+		reader, writer := io.Pipe()
+		w := &StreamWriter{StatusCode: http.StatusOK, Headers: map[string]string{}, pipe: writer, started: make(chan struct{})}
+
+		done := make(chan error, 1)
+		go func() {
+			err := streamingHandler(ctx, event, w)
+			select {
+			case <-w.started:
+				if err != nil {
+					GetLogger(ctx).Error("streaming response failed", "error", err.Error(), "stages", getStagesLogValue(ctx))
+				}
+			default:
+			}
+			_ = writer.CloseWithError(err)
+			done <- err
+		}()
+
+		//Writes block until the runtime reads them, so the handler can't finish after starting to write until the
+		//response has been returned
+		select {
+		case <-w.started:
+		case err := <-done:
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &events.LambdaFunctionURLStreamingResponse{StatusCode: w.StatusCode, Headers: w.Headers, Body: reader}, nil
+	}
+}
+
+// BuildAndStartStreaming is BuildAndStart for a StreamingHandler
+func BuildAndStartStreaming[T interface{}](getHandler func(awsConfig aws.Config) StreamingHandler[T]) {
+	BuildAndStart(func(awsConfig aws.Config) Handler[T, *events.LambdaFunctionURLStreamingResponse] {
+		return GetStreamingHandler(getHandler(awsConfig))
+	})
+}
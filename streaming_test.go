@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStreamingHandler(t *testing.T) {
+
+	testcases := []struct {
+		name             string
+		streamingHandler StreamingHandler[events.LambdaFunctionURLRequest]
+		checkResult      func(t *testing.T, response *events.LambdaFunctionURLStreamingResponse, err error)
+	}{
+		{
+			name: "Body streamed",
+			streamingHandler: func(ctx context.Context, event events.LambdaFunctionURLRequest, w *StreamWriter) error {
+				w.Headers["Content-Type"] = "text/csv"
+				for i := 0; i < 3; i++ {
+					_, err := fmt.Fprintf(w, "row %d\n", i)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			checkResult: func(t *testing.T, response *events.LambdaFunctionURLStreamingResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 200, response.StatusCode)
+				assert.Equal(t, "text/csv", response.Headers["Content-Type"])
+				b, err := io.ReadAll(response.Body)
+				assert.Nil(t, err)
+				assert.Equal(t, "row 0\nrow 1\nrow 2\n", string(b))
+			},
+		},
+		{
+			name: "Error before writing",
+			streamingHandler: func(ctx context.Context, event events.LambdaFunctionURLRequest, w *StreamWriter) error {
+				return errors.New("something bad happened")
+			},
+			checkResult: func(t *testing.T, response *events.LambdaFunctionURLStreamingResponse, err error) {
+				assert.EqualError(t, err, "something bad happened")
+			},
+		},
+		{
+			name: "Error after writing",
+			streamingHandler: func(ctx context.Context, event events.LambdaFunctionURLRequest, w *StreamWriter) error {
+				_, _ = w.Write([]byte("partial"))
+				return errors.New("something bad happened")
+			},
+			checkResult: func(t *testing.T, response *events.LambdaFunctionURLStreamingResponse, err error) {
+				assert.Nil(t, err)
+				b, err := io.ReadAll(response.Body)
+				assert.Equal(t, "partial", string(b))
+				assert.EqualError(t, err, "something bad happened")
+			},
+		},
+		{
+			name: "Empty body",
+			streamingHandler: func(ctx context.Context, event events.LambdaFunctionURLRequest, w *StreamWriter) error {
+				w.StatusCode = 204
+				return nil
+			},
+			checkResult: func(t *testing.T, response *events.LambdaFunctionURLStreamingResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 204, response.StatusCode)
+				b, err := io.ReadAll(response.Body)
+				assert.Nil(t, err)
+				assert.Empty(t, b)
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := GetStreamingHandler(tc.streamingHandler)
+			response, err := handler(context.Background(), events.LambdaFunctionURLRequest{})
+			tc.checkResult(t, response, err)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"path"
+)
+
+// TraceConfig is the configuration document for WithKeyTracing, e.g. {"patterns": ["tenant-42", "order-1234*"]}.
+// Patterns use path.Match syntax
+type TraceConfig struct {
+	Patterns []string `json:"patterns"`
+}
+
+// TraceKeyExtractor returns the correlation keys of an event (e.g. the tenant, order and correlation IDs)
+type TraceKeyExtractor[T interface{}] func(event T) []string
+
+// WithKeyTracing enables debug-level logging and logs the input for invocations where one of the event's keys matches a
+// pattern in the watcher's TraceConfig, so that one problematic entity can be traced in depth across services. The
+// config is usually an SSM parameter (see NewSSMConfigSource), so tracing can be switched on and off without a
+// redeploy. If the config can't be loaded the invocation runs without tracing
+func WithKeyTracing[T interface{}, U interface{}](getKeys TraceKeyExtractor[T], watcher *ConfigWatcher[TraceConfig], handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		config, err := watcher.Get(ctx)
+		if err != nil {
+			GetLogger(ctx).Warn("failed to load trace config", "error", err.Error())
+			return handlerFunc(ctx, event)
+		}
+
+		key, traced := matchTraceKey(getKeys(event), config.Patterns)
+		if !traced {
+			return handlerFunc(ctx, event)
+		}
+
+		ctx = GetNewContextWithLogger(ctx, getDebugLogger(ctx).With("tracedKey", key))
+		AddStage(ctx, "tracing "+key)
+		GetLogger(ctx).Debug("traced invocation", "input", maskLogValue(event))
+		return handlerFunc(ctx, event)
+	}
+}
+
+// matchTraceKey returns the first key that matches one of the patterns. Malformed patterns never match
+func matchTraceKey(keys []string, patterns []string) (string, bool) {
+	for _, key := range keys {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, key); matched {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
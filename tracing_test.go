@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithKeyTracing(t *testing.T) {
+
+	testcases := []struct {
+		name     string
+		config   string
+		fetchErr error
+		keys     []string
+		expected bool
+	}{
+		{name: "Exact match", config: `{"patterns": ["tenant-42"]}`, keys: []string{"order-1", "tenant-42"}, expected: true},
+		{name: "Glob match", config: `{"patterns": ["order-12*"]}`, keys: []string{"order-1234"}, expected: true},
+		{name: "No match", config: `{"patterns": ["tenant-42"]}`, keys: []string{"tenant-4"}, expected: false},
+		{name: "No keys", config: `{"patterns": ["*"]}`, expected: false},
+		{name: "Config unavailable", fetchErr: errors.New("access denied"), keys: []string{"tenant-42"}, expected: false},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			watcher := NewConfigWatcher[TraceConfig](func(ctx context.Context) ([]byte, error) {
+				return []byte(tc.config), tc.fetchErr
+			}, time.Minute)
+
+			ctx := ContextWithStages(context.Background())
+			h := WithKeyTracing(func(event inputEvent) []string {
+				return tc.keys
+			}, watcher, func(ctx context.Context, event inputEvent) (outputEvent, error) {
+				assert.Equal(t, tc.expected, GetLogger(ctx).Enabled(ctx, slog.LevelDebug))
+				return outputEvent{}, nil
+			})
+			_, err := h(ctx, inputEvent{})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, len(getStageDescriptions(ctx)) > 0)
+		})
+	}
+}
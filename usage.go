@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"time"
+)
+
+// resourceUsage records the state at the start of an invocation so that memory and duration can be reported at the end
+type resourceUsage struct {
+	start   time.Time
+	numGC   uint32
+	enabled bool
+}
+
+// ResourceUsage is the memory and duration reported for an invocation when REPORT_RESOURCE_USAGE is "true"
+type ResourceUsage struct {
+	DurationMs     int64  `json:"durationMs"`
+	HeapAllocBytes uint64 `json:"heapAllocBytes"`
+	SysBytes       uint64 `json:"sysBytes"`
+	GCCount        uint32 `json:"gcCount"`
+}
+
+// startResourceUsage reads the initial state if REPORT_RESOURCE_USAGE is "true". Reading runtime.MemStats briefly stops
+// the world, so it is opt-in
+func startResourceUsage(ctx context.Context) resourceUsage {
+	if os.Getenv("REPORT_RESOURCE_USAGE") != "true" {
+		return resourceUsage{}
+	}
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	return resourceUsage{start: GetClock(ctx).Now(), numGC: stats.NumGC, enabled: true}
+}
+
+// report logs the invocation's duration and memory usage and emits them as metrics, so that the lambda memory size can
+// be tuned
+func (u resourceUsage) report(ctx context.Context) {
+	if !u.enabled {
+		return
+	}
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	usage := ResourceUsage{
+		DurationMs:     GetClock(ctx).Now().Sub(u.start).Milliseconds(),
+		HeapAllocBytes: stats.HeapAlloc,
+		SysBytes:       stats.Sys,
+		GCCount:        stats.NumGC - u.numGC,
+	}
+
+	GetLogger(ctx).Info("resource usage", "usage", usage, "stages", getStagesLogValue(ctx))
+	EmitMetric(ctx, "HandlerDuration", float64(usage.DurationMs), UnitMilliseconds, nil)
+	EmitMetric(ctx, "HeapAlloc", float64(usage.HeapAllocBytes), UnitBytes, nil)
+	EmitMetric(ctx, "SysMemory", float64(usage.SysBytes), UnitBytes, nil)
+	EmitMetric(ctx, "GCCount", float64(usage.GCCount), UnitCount, nil)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceUsageReport(t *testing.T) {
+
+	testcases := []struct {
+		name        string
+		enabled     string
+		checkResult func(t *testing.T, logs *bytes.Buffer, metrics *bytes.Buffer)
+	}{
+		{
+			name:    "Usage reported",
+			enabled: "true",
+			checkResult: func(t *testing.T, logs *bytes.Buffer, metrics *bytes.Buffer) {
+				entry := map[string]interface{}{}
+				assert.Nil(t, json.Unmarshal(logs.Bytes(), &entry))
+				usage := entry["usage"].(map[string]interface{})
+				assert.Equal(t, 1500.0, usage["durationMs"])
+				assert.Greater(t, usage["heapAllocBytes"], 0.0)
+				assert.Greater(t, usage["sysBytes"], 0.0)
+				assert.Len(t, strings.Split(strings.TrimSpace(metrics.String()), "\n"), 4)
+			},
+		},
+		{
+			name: "Usage not reported by default",
+			checkResult: func(t *testing.T, logs *bytes.Buffer, metrics *bytes.Buffer) {
+				assert.Empty(t, logs.String())
+				assert.Empty(t, metrics.String())
+			},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("REPORT_RESOURCE_USAGE", tc.enabled)
+			t.Setenv("METRIC_NAMESPACE", "MyService")
+			metrics := captureMetrics(t)
+
+			logs := &bytes.Buffer{}
+			clock := NewFakeClock(time.Now())
+			ctx := GetNewContextWithLogger(context.Background(), slog.New(slog.NewJSONHandler(logs, nil)))
+			ctx = ContextWithClock(ctx, clock)
+
+			usage := startResourceUsage(ctx)
+			clock.Advance(1500 * time.Millisecond)
+			usage.report(ctx)
+			tc.checkResult(t, logs, metrics)
+		})
+	}
+}
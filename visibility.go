@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type visibilityHeartbeat struct {
+	client    SQSChangeMessageVisibilityAPI
+	interval  time.Duration
+	extension time.Duration
+}
+
+// WithVisibilityHeartbeat extends the visibility timeout of records that are still being processed, so that slow
+// records aren't redelivered to another consumer part way through. Every interval, the visibility timeout of each
+// in-flight record is set to extension from now. interval should be comfortably shorter than both extension and the
+// queue's visibility timeout
+func WithVisibilityHeartbeat(client SQSChangeMessageVisibilityAPI, interval time.Duration, extension time.Duration) SQSOption {
+	return func(o *sqsOptions) {
+		o.visibilityHeartbeat = &visibilityHeartbeat{client: client, interval: interval, extension: extension}
+	}
+}
+
+// start extends the record's visibility every interval until the returned function is called
+func (h *visibilityHeartbeat) start(ctx context.Context, record events.SQSMessage) func() {
+	clock := GetClock(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			timer := clock.NewTimer(h.interval)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C():
+				err := changeMessageVisibility(ctx, h.client, record, h.extension)
+				if err != nil {
+					GetLogger(ctx).Warn("failed to extend message visibility", "error", err.Error(), "messageId", record.MessageId)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
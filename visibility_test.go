@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisibilityHeartbeat(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	ctx := ContextWithClock(context.Background(), clock)
+	client := &mockSQSHeartbeatClient{inputs: make(chan *sqs.ChangeMessageVisibilityInput, 10)}
+	heartbeat := &visibilityHeartbeat{client: client, interval: 20 * time.Second, extension: time.Minute}
+
+	stop := heartbeat.start(ctx, events.SQSMessage{MessageId: "id-1", ReceiptHandle: "receipt-1", EventSourceARN: "arn:aws:sqs:eu-west-2:123456789012:orders"})
+	for i := 0; i < 2; i++ {
+		for clock.PendingTimers() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(20 * time.Second)
+		input := <-client.inputs
+		assert.Equal(t, "https://sqs.eu-west-2.amazonaws.com/123456789012/orders", aws.ToString(input.QueueUrl))
+		assert.Equal(t, "receipt-1", aws.ToString(input.ReceiptHandle))
+		assert.Equal(t, int32(60), input.VisibilityTimeout)
+	}
+	stop()
+	assert.Equal(t, 0, clock.PendingTimers())
+	assert.Len(t, client.inputs, 0)
+}
+
+func TestWithVisibilityHeartbeat(t *testing.T) {
+	client := &mockSQSHeartbeatClient{inputs: make(chan *sqs.ChangeMessageVisibilityInput, 10)}
+	h := GetSQSHandler(func(ctx context.Context, record events.SQSMessage) error {
+		return nil
+	}, WithVisibilityHeartbeat(client, 20*time.Second, time.Minute))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(2*time.Second))
+	defer cancel()
+	result, err := h(ctx, events.SQSEvent{Records: []events.SQSMessage{
+		{ReceiptHandle: "receipt-1", EventSourceARN: "arn:aws:sqs:eu-west-2:123456789012:orders"},
+	}})
+	assert.Nil(t, err)
+	assert.Len(t, result.BatchItemFailures, 0)
+	//Records that finish within the interval don't have their visibility changed
+	assert.Len(t, client.inputs, 0)
+}
+
+type mockSQSHeartbeatClient struct {
+	inputs chan *sqs.ChangeMessageVisibilityInput
+}
+
+func (m *mockSQSHeartbeatClient) ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	m.inputs <- params
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+const inFlightRecordsKey = "inFlightRecords"
+
+// startWatchdog logs a "still running" line every WATCHDOG_INTERVAL (a Go duration, e.g. "10s") until the returned
+// function is called, so that a hung invocation can be identified from its logs rather than only from the timeout in
+// the REPORT line. Each line includes the elapsed time and the most recent stage of the invocation. Batch handlers
+// record stages per record rather than on the invocation, so the line also lists the records still being processed
+// (see trackRecord) with their most recent stage. It does nothing if WATCHDOG_INTERVAL is not set
+func startWatchdog(ctx context.Context) (context.Context, func()) {
+	interval, err := time.ParseDuration(os.Getenv("WATCHDOG_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return ctx, func() {}
+	}
+
+	records := &inFlightRecords{}
+	ctx = context.WithValue(ctx, inFlightRecordsKey, records)
+	clock := GetClock(ctx)
+	start := clock.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			timer := clock.NewTimer(interval)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C():
+				attrs := []any{"elapsedSeconds", int64(clock.Now().Sub(start).Seconds()), "currentStage", getCurrentStage(ctx)}
+				if inFlight := records.logValue(); len(inFlight) > 0 {
+					attrs = append(attrs, "inFlightRecords", inFlight)
+				}
+				GetLogger(ctx).Warn("still running", attrs...)
+			}
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// getCurrentStage returns the description of the most recent stage, or an empty string if there are no stages
+func getCurrentStage(ctx context.Context) string {
+	stages := GetStages(ctx)
+	if len(stages) == 0 {
+		return ""
+	}
+	return stages[len(stages)-1].Description
+}
+
+type inFlightRecord struct {
+	//ctx is the record's context, which has its own stages
+	ctx   context.Context
+	attrs []any
+}
+
+// inFlightRecords are the batch records being processed during an invocation with a watchdog
+type inFlightRecords struct {
+	mu      sync.Mutex
+	records []*inFlightRecord
+}
+
+// trackRecord adds a batch record to the records listed by the watchdog until the returned function is called. ctx is
+// the record's context (with its own stages) and attrs are the log attributes identifying it. It does nothing if the
+// invocation has no watchdog
+func trackRecord(ctx context.Context, attrs ...any) func() {
+	records, ok := ctx.Value(inFlightRecordsKey).(*inFlightRecords)
+	if !ok {
+		return func() {}
+	}
+	record := &inFlightRecord{ctx: ctx, attrs: attrs}
+	records.mu.Lock()
+	records.records = append(records.records, record)
+	records.mu.Unlock()
+	return func() {
+		records.mu.Lock()
+		defer records.mu.Unlock()
+		for i, r := range records.records {
+			if r == record {
+				records.records = append(records.records[:i], records.records[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// logValue returns the identifying attributes and the most recent stage of each record, in the order they started
+func (r *inFlightRecords) logValue() []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := []map[string]any{}
+	for _, record := range r.records {
+		value := map[string]any{"currentStage": getCurrentStage(record.ctx)}
+		for i := 0; i+1 < len(record.attrs); i += 2 {
+			if key, ok := record.attrs[i].(string); ok {
+				value[key] = record.attrs[i+1]
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
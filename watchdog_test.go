@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartWatchdog(t *testing.T) {
+	t.Setenv("WATCHDOG_INTERVAL", "10s")
+	buf := &bytes.Buffer{}
+	clock := NewFakeClock(time.Now())
+	ctx := ContextWithClock(context.Background(), clock)
+	ctx = ContextWithStages(GetNewContextWithLogger(ctx, slog.New(slog.NewJSONHandler(buf, nil))))
+	AddStage(ctx, "fetch item")
+
+	waitForTimer := func() {
+		for clock.PendingTimers() != 1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	ctx, stop := startWatchdog(ctx)
+	recordCtx := ContextWithStages(ctx)
+	AddStage(recordCtx, "call api")
+	untrack := trackRecord(recordCtx, "messageId", "m-1")
+	finished := ContextWithStages(ctx)
+	trackRecord(finished, "messageId", "m-2")()
+	waitForTimer()
+	clock.Advance(10 * time.Second)
+	//The next timer is only created once the line has been logged
+	waitForTimer()
+	stop()
+	untrack()
+	assert.Equal(t, 0, clock.PendingTimers())
+
+	line := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "still running", line["msg"])
+	assert.Equal(t, float64(10), line["elapsedSeconds"])
+	assert.Equal(t, "fetch item", line["currentStage"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"messageId": "m-1", "currentStage": "call api"}}, line["inFlightRecords"])
+}
+
+func TestStartWatchdogDisabled(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	ctx, stop := startWatchdog(ContextWithClock(context.Background(), clock))
+	trackRecord(ctx, "messageId", "m-1")()
+	stop()
+	assert.Equal(t, 0, clock.PendingTimers())
+}
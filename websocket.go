@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// WebSocketConnection is a connected WebSocket client. Attributes are set when the client connects (e.g. the user ID)
+// and can be used to filter broadcasts
+type WebSocketConnection struct {
+	ConnectionID string
+	Attributes   map[string]string
+}
+
+// DynamoDBConnectionAPI is the subset of the DynamoDB client used by ConnectionStore
+type DynamoDBConnectionAPI interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// ConnectionStore is a registry of WebSocket connections in a DynamoDB table with a string partition key named
+// "connectionId"
+type ConnectionStore struct {
+	client DynamoDBConnectionAPI
+	table  string
+}
+
+func NewConnectionStore(client DynamoDBConnectionAPI, table string) *ConnectionStore {
+	return &ConnectionStore{client: client, table: table}
+}
+
+func (s *ConnectionStore) Add(ctx context.Context, connection WebSocketConnection) error {
+	item := map[string]ddbtypes.AttributeValue{"connectionId": &ddbtypes.AttributeValueMemberS{Value: connection.ConnectionID}}
+	if len(connection.Attributes) > 0 {
+		attributes := map[string]ddbtypes.AttributeValue{}
+		for k, v := range connection.Attributes {
+			attributes[k] = &ddbtypes.AttributeValueMemberS{Value: v}
+		}
+		item["attributes"] = &ddbtypes.AttributeValueMemberM{Value: attributes}
+	}
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(s.table), Item: item})
+	return err
+}
+
+func (s *ConnectionStore) Remove(ctx context.Context, connectionID string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]ddbtypes.AttributeValue{"connectionId": &ddbtypes.AttributeValueMemberS{Value: connectionID}},
+	})
+	return err
+}
+
+// List returns every registered connection
+func (s *ConnectionStore) List(ctx context.Context) ([]WebSocketConnection, error) {
+	connections := []WebSocketConnection{}
+	var startKey map[string]ddbtypes.AttributeValue
+	for {
+		output, err := s.client.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String(s.table), ExclusiveStartKey: startKey})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			connection := WebSocketConnection{Attributes: map[string]string{}}
+			if id, ok := item["connectionId"].(*ddbtypes.AttributeValueMemberS); ok {
+				connection.ConnectionID = id.Value
+			}
+			if attributes, ok := item["attributes"].(*ddbtypes.AttributeValueMemberM); ok {
+				for k, v := range attributes.Value {
+					if s, ok := v.(*ddbtypes.AttributeValueMemberS); ok {
+						connection.Attributes[k] = s.Value
+					}
+				}
+			}
+			connections = append(connections, connection)
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return connections, nil
+		}
+		startKey = output.LastEvaluatedKey
+	}
+}
+
+// GetWebSocketConnectionHandler returns a handler for the $connect and $disconnect routes of a WebSocket API that
+// registers and removes connections in the store. getAttributes (which may be nil) returns the attributes to store for
+// a new connection, e.g. from the authorizer context
+func GetWebSocketConnectionHandler(store *ConnectionStore, getAttributes func(request events.APIGatewayWebsocketProxyRequest) map[string]string) Handler[events.APIGatewayWebsocketProxyRequest, events.APIGatewayProxyResponse] {
+	return func(ctx context.Context, request events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+		connectionID := request.RequestContext.ConnectionID
+		switch request.RequestContext.RouteKey {
+		case "$connect":
+			connection := WebSocketConnection{ConnectionID: connectionID}
+			if getAttributes != nil {
+				connection.Attributes = getAttributes(request)
+			}
+			err := store.Add(ctx, connection)
+			if err != nil {
+				return events.APIGatewayProxyResponse{}, StageErr(ctx, "add connection", err)
+			}
+			AddStage(ctx, "add connection")
+		case "$disconnect":
+			err := store.Remove(ctx, connectionID)
+			if err != nil {
+				return events.APIGatewayProxyResponse{}, StageErr(ctx, "remove connection", err)
+			}
+			AddStage(ctx, "remove connection")
+		default:
+			return events.APIGatewayProxyResponse{}, fmt.Errorf("unexpected route %s", request.RequestContext.RouteKey)
+		}
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+}
+
+// WebSocketPoster sends data to a WebSocket connection. It is usually a call to the API Gateway Management API
+// PostToConnection operation, whose GoneException is recognised by Broadcast
+type WebSocketPoster func(ctx context.Context, connectionID string, data []byte) error
+
+// Broadcast marshals payload to JSON and sends it in parallel to every connection in the store that matches filter (or
+// to every connection if filter is nil). Connections that have gone away are removed from the store. It returns the
+// number of connections the payload was sent to, and the other send failures joined into one error
+func Broadcast(ctx context.Context, post WebSocketPoster, store *ConnectionStore, filter func(connection WebSocketConnection) bool, payload interface{}) (int, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	connections, err := store.List(ctx)
+	if err != nil {
+		return 0, StageErr(ctx, "list connections", err)
+	}
+	targets := []WebSocketConnection{}
+	for _, connection := range connections {
+		if filter == nil || filter(connection) {
+			targets = append(targets, connection)
+		}
+	}
+
+	gone := make([]bool, len(targets))
+	errs := runParallel(ctx, len(targets), func(ctx context.Context, i int) error {
+		connectionID := targets[i].ConnectionID
+		err := post(ctx, connectionID, data)
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "GoneException" {
+			gone[i] = true
+			GetLogger(ctx).Info("removing gone websocket connection", "connectionId", connectionID)
+			return store.Remove(ctx, connectionID)
+		}
+		return err
+	})
+
+	sent := 0
+	failed := []error{}
+	for i, err := range errs {
+		if err == nil {
+			if !gone[i] {
+				sent++
+			}
+			continue
+		}
+		failed = append(failed, fmt.Errorf("connection %s: %w", targets[i].ConnectionID, err))
+	}
+	AddStage(ctx, fmt.Sprintf("broadcast to %d connections", sent))
+	return sent, errors.Join(failed...)
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketConnections(t *testing.T) {
+	client := &mockDynamoDBConnectionClient{items: map[string]map[string]ddbtypes.AttributeValue{}}
+	store := NewConnectionStore(client, "connections")
+
+	h := GetWebSocketConnectionHandler(store, func(request events.APIGatewayWebsocketProxyRequest) map[string]string {
+		return map[string]string{"userId": request.QueryStringParameters["user"]}
+	})
+	for _, c := range []struct{ id, user string }{{"a", "alice"}, {"b", "bob"}, {"c", "alice"}, {"d", "carol"}} {
+		response, err := h(context.Background(), events.APIGatewayWebsocketProxyRequest{
+			RequestContext:        events.APIGatewayWebsocketProxyRequestContext{RouteKey: "$connect", ConnectionID: c.id},
+			QueryStringParameters: map[string]string{"user": c.user},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+	}
+	_, err := h(context.Background(), events.APIGatewayWebsocketProxyRequest{
+		RequestContext: events.APIGatewayWebsocketProxyRequestContext{RouteKey: "$disconnect", ConnectionID: "d"},
+	})
+	assert.Nil(t, err)
+
+	mu := sync.Mutex{}
+	posted := map[string]string{}
+	post := func(ctx context.Context, connectionID string, data []byte) error {
+		if connectionID == "c" {
+			return &smithy.GenericAPIError{Code: "GoneException"}
+		}
+		if connectionID == "b" {
+			return errors.New("something bad happened")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		posted[connectionID] = string(data)
+		return nil
+	}
+
+	sent, err := Broadcast(context.Background(), post, store, func(connection WebSocketConnection) bool {
+		return connection.Attributes["userId"] == "alice"
+	}, outputEvent{Bar: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, map[string]string{"a": `{"Bar":1}`}, posted)
+	assert.NotContains(t, client.items, "c")
+
+	sent, err = Broadcast(context.Background(), post, store, nil, outputEvent{Bar: 2})
+	assert.EqualError(t, err, "connection b: something bad happened")
+	assert.Equal(t, 1, sent)
+}
+
+type mockDynamoDBConnectionClient struct {
+	mu    sync.Mutex
+	items map[string]map[string]ddbtypes.AttributeValue
+}
+
+func (m *mockDynamoDBConnectionClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[params.Item["connectionId"].(*ddbtypes.AttributeValueMemberS).Value] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockDynamoDBConnectionClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, params.Key["connectionId"].(*ddbtypes.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *mockDynamoDBConnectionClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	output := &dynamodb.ScanOutput{}
+	for _, item := range m.items {
+		output.Items = append(output.Items, item)
+	}
+	output.Count = int32(len(output.Items))
+	return output, nil
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// X-Ray allows at most 50 annotations per segment
+const maxXRayAnnotations = 50
+
+// WithXRayStages mirrors the stages recorded during each invocation into an X-Ray subsegment named "stages" when the
+// handler returns. Each stage is added as an annotation (e.g. stage_load_customer=true) so that traces can be found
+// with filter expressions, and the full list of stages with timestamps is added as metadata
+func WithXRayStages[T interface{}, U interface{}](handlerFunc Handler[T, U]) Handler[T, U] {
+	return func(ctx context.Context, event T) (U, error) {
+		if ctx.Value(stagesKey) == nil {
+			ctx = ContextWithStages(ctx)
+		}
+
+		response, err := handlerFunc(ctx, event)
+		recordStagesInXRay(ctx, err)
+		return response, err
+	}
+}
+
+func recordStagesInXRay(ctx context.Context, err error) {
+	stages := GetStages(ctx)
+	if len(stages) == 0 {
+		return
+	}
+
+	_, seg := xray.BeginSubsegment(ctx, "stages")
+	if seg == nil {
+		return
+	}
+
+	annotations := 0
+	for _, stage := range stages {
+		if annotations >= maxXRayAnnotations-1 {
+			break
+		}
+		if seg.AddAnnotation(getXRayAnnotationKey(stage.Description), true) == nil {
+			annotations++
+		}
+	}
+	_ = seg.AddAnnotation("last_stage", stages[len(stages)-1].Description)
+	_ = seg.AddMetadata("stages", stages)
+	seg.Close(err)
+}
+
+// getXRayAnnotationKey converts a stage description to a valid annotation key (alphanumeric characters and underscores)
+func getXRayAnnotationKey(description string) string {
+	key := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(description))
+	return "stage_" + key
+}
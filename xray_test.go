@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithXRayStages(t *testing.T) {
+	ctx, seg := xray.BeginSegment(context.Background(), "test")
+
+	h := WithXRayStages(func(ctx context.Context, event inputEvent) (outputEvent, error) {
+		AddStage(ctx, "load customer")
+		AddStage(ctx, "Send Email!")
+		return outputEvent{}, nil
+	})
+	_, err := h(ctx, inputEvent{})
+	assert.Nil(t, err)
+
+	//Subsegments are serialized when the parent segment is closed
+	seg.Close(nil)
+	assert.Len(t, seg.Subsegments, 1)
+	subsegment := string(seg.Subsegments[0])
+	assert.Contains(t, subsegment, `"stage_load_customer":true`)
+	assert.Contains(t, subsegment, `"last_stage":"Send Email!"`)
+}
+
+func TestGetXRayAnnotationKey(t *testing.T) {
+	assert.Equal(t, "stage_send_email_", getXRayAnnotationKey("Send Email!"))
+}